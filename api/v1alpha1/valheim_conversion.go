@@ -0,0 +1,218 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/robwittman/gamely/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+const (
+	// AnnotationConversion holds the v1alpha2 fields of a Valheim that v1alpha1
+	// cannot express, so they survive a round trip through v1alpha1
+	AnnotationConversion = "gamely.io/v1alpha2-fields"
+	// AnnotationWorldModifiers holds the v1alpha1 world modifiers of a Valheim
+	// that v1alpha2 cannot express, such as a hammerMode of "false", so they
+	// survive a round trip through v1alpha2
+	AnnotationWorldModifiers = "gamely.io/v1alpha1-world-modifiers"
+)
+
+// conversionFields are the v1alpha2 fields kept in AnnotationConversion
+type conversionFields struct {
+	Preset             v1alpha2.ValheimWorldPreset           `json:"preset,omitempty"`
+	Keys               []v1alpha2.ValheimWorldKey            `json:"keys,omitempty"`
+	PasswordKey        string                                `json:"passwordKey,omitempty"`
+	PasswordPolicy     *v1alpha2.ValheimPasswordPolicy       `json:"passwordPolicy,omitempty"`
+	PasswordRotation   *v1alpha2.ValheimPasswordRotationSpec `json:"passwordRotation,omitempty"`
+	PlayerListSelector *metav1.LabelSelector                 `json:"playerListSelector,omitempty"`
+
+	PasswordSecretRef *v1alpha2.ValheimSecretKeySelector `json:"passwordSecretRef,omitempty"`
+}
+
+var _ conversion.Convertible = &Valheim{}
+
+// ConvertTo converts this Valheim to the Hub version (v1alpha2)
+func (src *Valheim) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha2.Valheim)
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	// Everything but the world modifiers and password has the same shape in
+	// both versions
+	if err := convertJSON(src.Spec, &dst.Spec); err != nil {
+		return err
+	}
+	if err := convertJSON(src.Status, &dst.Status); err != nil {
+		return err
+	}
+
+	fields := conversionFields{}
+	if data, ok := dst.Annotations[AnnotationConversion]; ok {
+		if err := json.Unmarshal([]byte(data), &fields); err != nil {
+			return err
+		}
+		delete(dst.Annotations, AnnotationConversion)
+	}
+
+	modifiers := src.Spec.WorldModifiers
+	dst.Spec.WorldModifiers = hubWorldModifiers(modifiers)
+	dst.Spec.WorldModifiers.Preset = fields.Preset
+	dst.Spec.WorldModifiers.Keys = append(dst.Spec.WorldModifiers.Keys, fields.Keys...)
+	delete(dst.Annotations, AnnotationWorldModifiers)
+	if converted, _ := spokeWorldModifiers(hubWorldModifiers(modifiers)); converted != modifiers {
+		data, err := json.Marshal(modifiers)
+		if err != nil {
+			return err
+		}
+		if dst.Annotations == nil {
+			dst.Annotations = map[string]string{}
+		}
+		dst.Annotations[AnnotationWorldModifiers] = string(data)
+	}
+	if len(dst.Annotations) == 0 {
+		dst.Annotations = nil
+	}
+
+	dst.Spec.Server.Password = nil
+	if password := src.Spec.Server.Password; password != nil {
+		key := fields.PasswordKey
+		if key == "" {
+			key = v1alpha2.DefaultPasswordKey
+		}
+		dst.Spec.Server.Password = &v1alpha2.ValheimSecretKeySelector{
+			Name:      password.Name,
+			Key:       key,
+			Namespace: password.Namespace,
+		}
+	}
+	dst.Spec.Server.PasswordPolicy = fields.PasswordPolicy
+	dst.Spec.Server.PasswordRotation = fields.PasswordRotation
+	dst.Spec.Access.PlayerListSelector = fields.PlayerListSelector
+	dst.Status.PasswordSecretRef = fields.PasswordSecretRef
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1alpha2) to this version
+func (dst *Valheim) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha2.Valheim)
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	if err := convertJSON(src.Spec, &dst.Spec); err != nil {
+		return err
+	}
+	if err := convertJSON(src.Status, &dst.Status); err != nil {
+		return err
+	}
+
+	fields := conversionFields{Preset: src.Spec.WorldModifiers.Preset}
+	dst.Spec.WorldModifiers, fields.Keys = spokeWorldModifiers(src.Spec.WorldModifiers)
+	if data, ok := dst.Annotations[AnnotationWorldModifiers]; ok {
+		delete(dst.Annotations, AnnotationWorldModifiers)
+		if len(dst.Annotations) == 0 {
+			dst.Annotations = nil
+		}
+		// The original modifiers are only restored while the v1alpha2 ones
+		// still say the same, so changes made through v1alpha2 win
+		original := ValheimWorldModifiersSpec{}
+		if err := json.Unmarshal([]byte(data), &original); err != nil {
+			return err
+		}
+		if converted, _ := spokeWorldModifiers(hubWorldModifiers(original)); converted == dst.Spec.WorldModifiers {
+			dst.Spec.WorldModifiers = original
+		}
+	}
+
+	dst.Spec.Server.Password = nil
+	if password := src.Spec.Server.Password; password != nil {
+		dst.Spec.Server.Password = &v1.SecretReference{
+			Name:      password.Name,
+			Namespace: password.Namespace,
+		}
+		if password.Key != v1alpha2.DefaultPasswordKey {
+			fields.PasswordKey = password.Key
+		}
+	}
+
+	fields.PasswordPolicy = src.Spec.Server.PasswordPolicy
+	fields.PasswordRotation = src.Spec.Server.PasswordRotation
+	fields.PlayerListSelector = src.Spec.Access.PlayerListSelector
+	fields.PasswordSecretRef = src.Status.PasswordSecretRef
+
+	if fields.Preset != "" || len(fields.Keys) > 0 || fields.PasswordKey != "" || fields.PasswordPolicy != nil || fields.PasswordRotation != nil || fields.PlayerListSelector != nil || fields.PasswordSecretRef != nil {
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		if dst.Annotations == nil {
+			dst.Annotations = map[string]string{}
+		}
+		dst.Annotations[AnnotationConversion] = string(data)
+	}
+	return nil
+}
+
+// hubWorldModifiers converts v1alpha1 world modifiers to v1alpha2, where
+// hammer mode is the nobuildcost key
+func hubWorldModifiers(modifiers ValheimWorldModifiersSpec) v1alpha2.ValheimWorldModifiersSpec {
+	hub := v1alpha2.ValheimWorldModifiersSpec{
+		Combat:       v1alpha2.ValheimCombatModifier(modifiers.Combat),
+		DeathPenalty: v1alpha2.ValheimDeathPenaltyModifier(modifiers.DeathPenalty),
+		Raids:        v1alpha2.ValheimRaidsModifier(modifiers.Raids),
+		Resources:    v1alpha2.ValheimResourcesModifier(modifiers.ResourceRate),
+		Portals:      v1alpha2.ValheimPortalsModifier(modifiers.Portals),
+	}
+	if hammerMode, _ := strconv.ParseBool(modifiers.HammerMode); hammerMode {
+		hub.Keys = []v1alpha2.ValheimWorldKey{v1alpha2.WorldKeyNoBuildCost}
+	}
+	return hub
+}
+
+// spokeWorldModifiers converts v1alpha2 world modifiers to v1alpha1, returning
+// the keys other than the nobuildcost key of hammer mode. The preset is left
+// to the caller.
+func spokeWorldModifiers(modifiers v1alpha2.ValheimWorldModifiersSpec) (ValheimWorldModifiersSpec, []v1alpha2.ValheimWorldKey) {
+	spoke := ValheimWorldModifiersSpec{
+		Combat:       string(modifiers.Combat),
+		DeathPenalty: string(modifiers.DeathPenalty),
+		Raids:        string(modifiers.Raids),
+		ResourceRate: string(modifiers.Resources),
+		Portals:      string(modifiers.Portals),
+	}
+	var keys []v1alpha2.ValheimWorldKey
+	for _, key := range modifiers.Keys {
+		if key == v1alpha2.WorldKeyNoBuildCost && spoke.HammerMode == "" {
+			spoke.HammerMode = "true"
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return spoke, keys
+}
+
+// convertJSON copies src into dst through their JSON, for types with the same
+// shape in both versions
+func convertJSON(src interface{}, dst interface{}) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/robwittman/gamely/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Valheim conversion", func() {
+	It("converts v1alpha1 to v1alpha2", func() {
+		valheim := &Valheim{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: ValheimSpec{
+				Server: ValheimServerSpec{
+					Name:     "Vikings Only",
+					Password: &v1.SecretReference{Name: "test", Namespace: "default"},
+				},
+				WorldModifiers: ValheimWorldModifiersSpec{
+					Combat:       "hard",
+					DeathPenalty: "casual",
+					ResourceRate: "more",
+					HammerMode:   "true",
+				},
+				Storage: ValheimStorageSpec{Size: "2Gi"},
+			},
+			Status: ValheimStatus{Phase: ValheimPhaseRunning},
+		}
+
+		hub := &v1alpha2.Valheim{}
+		Expect(valheim.ConvertTo(hub)).To(Succeed())
+		Expect(hub.Name).To(Equal("test"))
+		Expect(hub.Spec.Server.Name).To(Equal("Vikings Only"))
+		Expect(hub.Spec.Server.Password).To(Equal(&v1alpha2.ValheimSecretKeySelector{Name: "test", Key: "password", Namespace: "default"}))
+		Expect(hub.Spec.WorldModifiers).To(Equal(v1alpha2.ValheimWorldModifiersSpec{
+			Combat:       "hard",
+			DeathPenalty: "casual",
+			Resources:    "more",
+			Keys:         []v1alpha2.ValheimWorldKey{v1alpha2.WorldKeyNoBuildCost},
+		}))
+		Expect(hub.Spec.Storage.Size).To(Equal("2Gi"))
+		Expect(hub.Status.Phase).To(Equal(v1alpha2.ValheimPhaseRunning))
+	})
+
+	It("round trips fields v1alpha1 cannot express", func() {
+		hub := &v1alpha2.Valheim{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: v1alpha2.ValheimSpec{
+				Server: v1alpha2.ValheimServerSpec{
+					Password:       &v1alpha2.ValheimSecretKeySelector{Name: "shared", Key: "valheim", Namespace: "secrets"},
+					PasswordPolicy: &v1alpha2.ValheimPasswordPolicy{Length: 16, Charset: v1alpha2.PasswordCharsetNumeric},
+				},
+				Access: v1alpha2.ValheimAccessSpec{
+					PlayerListSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"community": "vikings"}},
+				},
+				WorldModifiers: v1alpha2.ValheimWorldModifiersSpec{
+					Preset: "hardcore",
+					Raids:  "none",
+					Keys:   []v1alpha2.ValheimWorldKey{v1alpha2.WorldKeyNoBuildCost, v1alpha2.WorldKeyNoMap},
+				},
+			},
+			Status: v1alpha2.ValheimStatus{
+				PasswordSecretRef: &v1alpha2.ValheimSecretKeySelector{Name: "shared", Key: "valheim", Namespace: "secrets"},
+			},
+		}
+
+		valheim := &Valheim{}
+		Expect(valheim.ConvertFrom(hub)).To(Succeed())
+		Expect(valheim.Spec.WorldModifiers.HammerMode).To(Equal("true"))
+		Expect(valheim.Spec.Server.Password).To(Equal(&v1.SecretReference{Name: "shared", Namespace: "secrets"}))
+		Expect(valheim.Annotations).To(HaveKey(AnnotationConversion))
+		Expect(hub.Annotations).NotTo(HaveKey(AnnotationConversion))
+
+		converted := &v1alpha2.Valheim{}
+		Expect(valheim.ConvertTo(converted)).To(Succeed())
+		Expect(converted.Spec).To(Equal(hub.Spec))
+		Expect(converted.Status).To(Equal(hub.Status))
+		Expect(converted.Annotations).To(BeEmpty())
+	})
+
+	DescribeTable("round trips world modifiers v1alpha2 cannot express",
+		func(modifiers ValheimWorldModifiersSpec) {
+			valheim := &Valheim{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec:       ValheimSpec{WorldModifiers: modifiers},
+			}
+			hub := &v1alpha2.Valheim{}
+			Expect(valheim.ConvertTo(hub)).To(Succeed())
+			Expect(hub.Spec.WorldModifiers.Keys).To(BeEmpty())
+
+			converted := &Valheim{}
+			Expect(converted.ConvertFrom(hub)).To(Succeed())
+			Expect(converted.Spec.WorldModifiers).To(Equal(modifiers))
+			Expect(converted.Annotations).To(BeEmpty())
+		},
+		Entry("with hammer mode off", ValheimWorldModifiersSpec{HammerMode: "false"}),
+		Entry("with hammer mode off as zero", ValheimWorldModifiersSpec{HammerMode: "0", Combat: "hard"}),
+		Entry("with hammer mode unparsable", ValheimWorldModifiersSpec{HammerMode: "no"}),
+		Entry("without modifiers", ValheimWorldModifiersSpec{}),
+	)
+
+	It("round trips hammer mode written other than as true", func() {
+		valheim := &Valheim{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec:       ValheimSpec{WorldModifiers: ValheimWorldModifiersSpec{HammerMode: "1"}},
+		}
+		hub := &v1alpha2.Valheim{}
+		Expect(valheim.ConvertTo(hub)).To(Succeed())
+		Expect(hub.Spec.WorldModifiers.Keys).To(Equal([]v1alpha2.ValheimWorldKey{v1alpha2.WorldKeyNoBuildCost}))
+
+		converted := &Valheim{}
+		Expect(converted.ConvertFrom(hub)).To(Succeed())
+		Expect(converted.Spec.WorldModifiers.HammerMode).To(Equal("1"))
+	})
+
+	It("prefers world modifiers changed through v1alpha2", func() {
+		valheim := &Valheim{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec:       ValheimSpec{WorldModifiers: ValheimWorldModifiersSpec{HammerMode: "false"}},
+		}
+		hub := &v1alpha2.Valheim{}
+		Expect(valheim.ConvertTo(hub)).To(Succeed())
+		Expect(hub.Annotations).To(HaveKey(AnnotationWorldModifiers))
+		hub.Spec.WorldModifiers.Keys = []v1alpha2.ValheimWorldKey{v1alpha2.WorldKeyNoBuildCost}
+
+		converted := &Valheim{}
+		Expect(converted.ConvertFrom(hub)).To(Succeed())
+		Expect(converted.Spec.WorldModifiers.HammerMode).To(Equal("true"))
+		Expect(converted.Annotations).NotTo(HaveKey(AnnotationWorldModifiers))
+	})
+})
@@ -29,8 +29,11 @@ import (
 type ValheimSpec struct {
 	Resources GameServerResourceSpec `json:"resources,omitempty"`
 
-	Image          ValheimImageSpec          `json:"image,omitempty"`
-	Server         ValheimServerSpec         `json:"server,omitempty"`
+	// +kubebuilder:default={}
+	Image ValheimImageSpec `json:"image,omitempty"`
+	// +kubebuilder:default={}
+	Server ValheimServerSpec `json:"server,omitempty"`
+	// +kubebuilder:default={}
 	Service        ValheimServiceSpec        `json:"service,omitempty"`
 	WorldModifiers ValheimWorldModifiersSpec `json:"worldModifiers,omitempty"`
 	Access         ValheimAccessSpec         `json:"access,omitempty"`
@@ -40,8 +43,27 @@ type ValheimSpec struct {
 	Hooks          ValheimHooksSpec          `json:"hooks,omitempty"`
 	Mods           ValheimModsSpec           `json:"mods,omitempty"`
 	//Tasks          []ValheimTaskSpec         `json:"tasks,omitempty"`
+
+	// DeletionPolicy controls what happens to the world when the Valheim is deleted
+	// +kubebuilder:default=Delete
+	DeletionPolicy ValheimDeletionPolicy `json:"deletionPolicy,omitempty"`
 }
 
+// ValheimDeletionPolicy controls what happens to a server's world on deletion
+// +kubebuilder:validation:Enum=Delete;Retain;BackupThenDelete
+type ValheimDeletionPolicy string
+
+const (
+	// DeletionPolicyDelete removes all storage along with the Valheim
+	DeletionPolicyDelete ValheimDeletionPolicy = "Delete"
+	// DeletionPolicyRetain orphans the world and backup volumes so they outlive the Valheim
+	DeletionPolicyRetain ValheimDeletionPolicy = "Retain"
+	// DeletionPolicyBackupThenDelete takes a final backup of the world before
+	// removing storage. Without a bucket to upload it to, the backup volume
+	// is retained to keep the final backup.
+	DeletionPolicyBackupThenDelete ValheimDeletionPolicy = "BackupThenDelete"
+)
+
 type ValheimHooksSpec struct {
 	PreSupervisorHook       string `json:"preSupervisorHook,omitempty"`
 	PreBootstrapHook        string `json:"preBootstrapHook,omitempty"`
@@ -64,21 +86,26 @@ type ValheimHooksSpec struct {
 	PostBepinexConfigHook   string `json:"postBepinexConfigHook,omitempty"`
 }
 type ValheimImageSpec struct {
-	Repository string        `json:"repository,omitempty"`
+	// +kubebuilder:default="ghcr.io/lloesche/valheim-server"
+	Repository string `json:"repository,omitempty"`
+	// +kubebuilder:default=latest
 	Version    string        `json:"version"`
 	PullPolicy v1.PullPolicy `json:"pullPolicy,omitempty"`
 }
 
 type ValheimServerSpec struct {
-	Name            string              `json:"name,omitempty"`
-	Password        *v1.SecretReference `json:"password,omitempty"`
-	WorldNameOrSeed string              `json:"worldNameOrSeed,omitempty"`
-	Public          bool                `json:"public,omitempty"`
-	AdditionalArgs  []string            `json:"additionalArgs,omitempty"`
-	AdditionalEnv   map[string]string   `json:"additionalEnv,omitempty"`
+	// +kubebuilder:default="Hosted by Gamely"
+	Name     string              `json:"name,omitempty"`
+	Password *v1.SecretReference `json:"password,omitempty"`
+	// +kubebuilder:default=Dedicated
+	WorldNameOrSeed string            `json:"worldNameOrSeed,omitempty"`
+	Public          bool              `json:"public,omitempty"`
+	AdditionalArgs  []string          `json:"additionalArgs,omitempty"`
+	AdditionalEnv   map[string]string `json:"additionalEnv,omitempty"`
 }
 
 type ValheimServiceSpec struct {
+	// +kubebuilder:default=ClusterIP
 	Type string `json:"type,omitempty"`
 }
 
@@ -98,11 +125,127 @@ type ValheimWorldModifiersSpec struct {
 }
 
 type ValheimBackupSpec struct {
-	Schedule     string              `json:"schedule,omitempty"`
+	Schedule string `json:"schedule,omitempty"`
+	// Mode is how backups are taken on the schedule. archive zips the world
+	// from inside the server, volumeSnapshot takes CSI snapshots of the world volume.
+	// +kubebuilder:default=archive
+	Mode ValheimBackupMode `json:"mode,omitempty"`
+	// VolumeSnapshotClassName is the class of snapshots taken in volumeSnapshot mode
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+	// MaxCount is how many backups to keep, both on the backups volume and in
+	// the bucket. Zero keeps every backup. Defaults to 5.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=5
+	MaxCount *int32 `json:"maxCount,omitempty"`
+	// MaxAgeDays is how many days backups are kept for, both on the backups
+	// volume and in the bucket. Defaults to 3.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=3
+	MaxAgeDays *int32 `json:"maxAgeDays,omitempty"`
+	// IfIdle keeps taking scheduled backups while no players are connected.
+	// Defaults to true.
+	// +kubebuilder:default=true
+	IfIdle *bool `json:"ifIdle,omitempty"`
+	// Compression is the archive format backups are written in
+	// +kubebuilder:default=zip
+	Compression ValheimBackupCompression `json:"compression,omitempty"`
+	// Directory is where the backups volume is mounted in the server
+	// +kubebuilder:validation:Pattern=`^/`
+	// +kubebuilder:default="/config/backups"
+	Directory string `json:"directory,omitempty"`
+	// SecretKeyRef names a secret in the server's namespace holding the
+	// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY used to upload backups
 	SecretKeyRef *v1.SecretReference `json:"secretKeyRef,omitempty"`
-	Endpoint     string              `json:"endpoint,omitempty"`
-	Bucket       string              `json:"bucket"`
-	Storage      ValheimStorageSpec  `json:"storage"`
+	// Endpoint is the URL of an S3 compatible service, defaulting to AWS. The
+	// scheme is required, so a plain http service such as a local MinIO is
+	// reached as given.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	Endpoint string `json:"endpoint,omitempty"`
+	// Bucket backups are uploaded to, under a <namespace>/<name>/ prefix.
+	// Backups are only kept on the backups volume when it is empty.
+	Bucket string `json:"bucket"`
+	// UploadSchedule is the cron schedule new backups are uploaded on
+	UploadSchedule string             `json:"uploadSchedule,omitempty"`
+	Storage        ValheimStorageSpec `json:"storage"`
+}
+
+// ValheimBackupMode is how scheduled backups are taken
+// +kubebuilder:validation:Enum=archive;volumeSnapshot
+type ValheimBackupMode string
+
+const (
+	BackupModeArchive        ValheimBackupMode = "archive"
+	BackupModeVolumeSnapshot ValheimBackupMode = "volumeSnapshot"
+)
+
+// ValheimBackupCompression is the archive format of backups
+// +kubebuilder:validation:Enum=zip;tar.gz
+type ValheimBackupCompression string
+
+const (
+	BackupCompressionZip   ValheimBackupCompression = "zip"
+	BackupCompressionTarGz ValheimBackupCompression = "tar.gz"
+)
+
+const (
+	// DefaultBackupsMaxCount is how many backups are kept when no maxCount is set
+	DefaultBackupsMaxCount = 5
+	// DefaultBackupsMaxAgeDays is how many days backups are kept for when no maxAgeDays is set
+	DefaultBackupsMaxAgeDays = 3
+	// DefaultBackupsDirectory is where the backups volume is mounted when no directory is set
+	DefaultBackupsDirectory = "/config/backups"
+)
+
+const (
+	// DefaultServerName is the name servers are listed under when no name is set
+	DefaultServerName = "Hosted by Gamely"
+	// DefaultWorldName is the world a server runs when no world name or seed is set
+	DefaultWorldName = "Dedicated"
+	// DefaultImageRepository and DefaultImageVersion are the server image run
+	// when no image is set
+	DefaultImageRepository = "ghcr.io/lloesche/valheim-server"
+	DefaultImageVersion    = "latest"
+	// DefaultServiceType is how servers are exposed when no service type is set
+	DefaultServiceType = "ClusterIP"
+)
+
+func (b ValheimBackupSpec) GetMode() ValheimBackupMode {
+	if b.Mode == "" {
+		return BackupModeArchive
+	}
+	return b.Mode
+}
+
+func (b ValheimBackupSpec) GetMaxCount() int32 {
+	if b.MaxCount == nil {
+		return DefaultBackupsMaxCount
+	}
+	return *b.MaxCount
+}
+
+func (b ValheimBackupSpec) GetMaxAgeDays() int32 {
+	if b.MaxAgeDays == nil {
+		return DefaultBackupsMaxAgeDays
+	}
+	return *b.MaxAgeDays
+}
+
+func (b ValheimBackupSpec) GetIfIdle() bool {
+	return b.IfIdle == nil || *b.IfIdle
+}
+
+func (b ValheimBackupSpec) GetCompression() ValheimBackupCompression {
+	if b.Compression == "" {
+		return BackupCompressionZip
+	}
+	return b.Compression
+}
+
+func (b ValheimBackupSpec) GetDirectory() string {
+	if b.Directory == "" {
+		return DefaultBackupsDirectory
+	}
+	return b.Directory
 }
 
 type ValheimStorageSpec struct {
@@ -111,15 +254,87 @@ type ValheimStorageSpec struct {
 }
 
 type ValheimModsSpec struct {
-	Enabled   bool                      `json:"enabled"`
-	Framework string                    `json:"framework"`
-	Storage   ValheimStorageSpec        `json:"storage"`
-	Packages  map[string]ValheimModSpec `json:"packages"`
+	Enabled   bool               `json:"enabled"`
+	Framework string             `json:"framework"`
+	Storage   ValheimStorageSpec `json:"storage"`
+	// Cache is a ReadWriteMany volume of downloaded mod archives, shared by
+	// every Valheim in the namespace so each archive is only downloaded once.
+	// The first server to create it decides its size and class. Without it
+	// archives are downloaded on every start.
+	Cache    *ValheimStorageSpec       `json:"cache,omitempty"`
+	Packages map[string]ValheimModSpec `json:"packages"`
+	// ValheimPlus is rendered into valheim_plus.cfg when the framework is
+	// ValheimPlus
+	ValheimPlus *ValheimPlusSpec `json:"valheimPlus,omitempty"`
+}
+
+// ValheimPlusSpec configures ValheimPlus
+type ValheimPlusSpec struct {
+	// Config maps sections of valheim_plus.cfg to their settings, as in
+	// {"Server": {"enabled": "true", "maxPlayers": "20"}}. Only sections and
+	// settings ValheimPlus knows are accepted. Anything left out keeps its
+	// ValheimPlus default. ValheimPlus only reads its config on start, so
+	// changes restart the server.
+	Config map[string]map[string]string `json:"config,omitempty"`
 }
 
 type ValheimModSpec struct {
 	Version string `json:"version,omitempty"`
-	Config  string `json:"config,omitempty"`
+	// Config is the raw contents of the BepInEx config file of the plugin.
+	// Exclusive with Settings.
+	Config string `json:"config,omitempty"`
+	// Settings maps sections of the BepInEx config file of the plugin to their
+	// settings, rendered by the operator. Exclusive with Config.
+	Settings map[string]map[string]string `json:"settings,omitempty"`
+	// ConfigFile is the name of the config file the plugin reads from
+	// BepInEx/config, which is usually its GUID, as in
+	// com.example.plugin.cfg. Defaults to the package name with the / replaced
+	// by a dot, as in Owner.Name.cfg.
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z0-9]+\.cfg$`
+	ConfigFile string `json:"configFile,omitempty"`
+	// SHA256 is the checksum the archive of Version must have. Without it the
+	// checksum of the first download is locked in status.mods.lock. Required
+	// for url sources.
+	// +kubebuilder:validation:Pattern=`^[a-f0-9]{64}$`
+	SHA256 string `json:"sha256,omitempty"`
+	// Source installs the package from somewhere other than Thunderstore
+	Source *ValheimModSource `json:"source,omitempty"`
+}
+
+// ValheimModSource is where a package that is not on Thunderstore is
+// installed from. Exactly one source is set. Sources hold a mod archive, or a
+// single DLL that is installed as a plugin.
+// +kubebuilder:validation:MinProperties=1
+// +kubebuilder:validation:MaxProperties=1
+type ValheimModSource struct {
+	// URL downloads the package over HTTP(S)
+	URL *ValheimModURLSource `json:"url,omitempty"`
+	// OCI pulls the package from an OCI artifact
+	OCI *ValheimModOCISource `json:"oci,omitempty"`
+	// ConfigMap reads the package from a key of a ConfigMap
+	ConfigMap *v1.ConfigMapKeySelector `json:"configMap,omitempty"`
+	// Secret reads the package from a key of a Secret
+	Secret *v1.SecretKeySelector `json:"secret,omitempty"`
+	// Volume reads the package from a file on an existing persistent volume claim
+	Volume *ValheimModVolumeSource `json:"volume,omitempty"`
+}
+
+type ValheimModURLSource struct {
+	// +kubebuilder:validation:Pattern=`^https?://[^\s]+$`
+	URL string `json:"url"`
+}
+
+type ValheimModOCISource struct {
+	// Reference is the artifact to pull, as in registry.example.com/mods/mymod:1.0.0
+	Reference string `json:"reference"`
+	// PullSecret names a kubernetes.io/dockerconfigjson Secret to authenticate with
+	PullSecret string `json:"pullSecret,omitempty"`
+}
+
+type ValheimModVolumeSource struct {
+	ClaimName string `json:"claimName"`
+	// Path is the file on the volume to install
+	Path string `json:"path"`
 }
 
 //type ValheimTaskSpec struct {
@@ -132,13 +347,111 @@ type ValheimStatus struct {
 	// Important: Run "make" to regenerate code after modifying this file
 	WorldStorage string `json:"worldStorage,omitempty"`
 
+	Phase   ValheimPhase `json:"phase,omitempty"`
+	Address string       `json:"address,omitempty"`
+	// Players, MaxPlayers and Version are what the server last reported over
+	// its query port, at LastSeen
+	Players    int32        `json:"players,omitempty"`
+	MaxPlayers int32        `json:"maxPlayers,omitempty"`
+	Version    string       `json:"version,omitempty"`
+	LastSeen   *metav1.Time `json:"lastSeen,omitempty"`
+	// LastQueried is when the server was last queried, whether or not it
+	// answered
+	LastQueried *metav1.Time `json:"lastQueried,omitempty"`
+
+	Backups ValheimBackupsStatus `json:"backups,omitempty"`
+	Mods    ValheimModsStatus    `json:"mods,omitempty"`
+
 	Conditions         []metav1.Condition `json:"conditions,omitempty"`
 	Ready              bool               `json:"ready,omitempty"`
 	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
 }
 
+// ValheimBackupsStatus describes the backups shipped off the backups volume
+type ValheimBackupsStatus struct {
+	// LastUploadTime is when backups were last uploaded to the bucket successfully
+	LastUploadTime *metav1.Time `json:"lastUploadTime,omitempty"`
+	// LastIndexTime is when the backups volume was last checked for
+	// scheduled backups to record as ValheimBackups
+	LastIndexTime *metav1.Time `json:"lastIndexTime,omitempty"`
+	// LastSnapshotTime is when a volume snapshot was last taken on the schedule
+	LastSnapshotTime *metav1.Time `json:"lastSnapshotTime,omitempty"`
+}
+
+// ValheimModsStatus describes the mod packages resolved for the server
+type ValheimModsStatus struct {
+	// Lock is every package the mod downloader installs, the requested
+	// packages and their dependencies, locked to exact versions
+	Lock []ValheimLockedMod `json:"lock,omitempty"`
+	// ResolvedGeneration is the generation of the Valheim the lock was resolved for
+	ResolvedGeneration int64 `json:"resolvedGeneration,omitempty"`
+	// FailedGeneration is the generation of the Valheim resolution last
+	// failed for. It is retried with a backoff until it succeeds or the spec changes.
+	FailedGeneration int64 `json:"failedGeneration,omitempty"`
+	// Failures counts the failed attempts at resolving FailedGeneration
+	Failures int32 `json:"failures,omitempty"`
+	// LastFailureTime is when resolution last failed
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+	// FailureMessage is why resolution last failed
+	FailureMessage string `json:"failureMessage,omitempty"`
+}
+
+// ValheimLockedMod is a package locked to the version to install
+type ValheimLockedMod struct {
+	// Package is the Thunderstore package, as in Owner/Name
+	Package     string `json:"package"`
+	Version     string `json:"version"`
+	DownloadURL string `json:"downloadURL,omitempty"`
+	// SHA256 is the checksum the archive is verified against
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// ValheimPhase summarises the conditions of a Valheim server
+// +kubebuilder:validation:Enum=Pending;Starting;Running;Paused;Restoring;Degraded;Terminating
+type ValheimPhase string
+
+const (
+	// ValheimPhasePending means the server is waiting on its storage
+	ValheimPhasePending ValheimPhase = "Pending"
+	// ValheimPhaseStarting means the server pod is installing mods or booting
+	ValheimPhaseStarting ValheimPhase = "Starting"
+	// ValheimPhaseRunning means the server is up and accepting players
+	ValheimPhaseRunning ValheimPhase = "Running"
+	// ValheimPhasePaused means spec.paused has scaled the server down
+	ValheimPhasePaused ValheimPhase = "Paused"
+	// ValheimPhaseDegraded means something is failing and needs attention
+	ValheimPhaseDegraded ValheimPhase = "Degraded"
+	// ValheimPhaseRestoring means the server is stopped while a ValheimRestore replaces its world
+	ValheimPhaseRestoring ValheimPhase = "Restoring"
+	// ValheimPhaseTerminating means the Valheim is being deleted
+	ValheimPhaseTerminating ValheimPhase = "Terminating"
+)
+
+const (
+	// ConditionPaused is true while spec.paused has scaled the server down
+	ConditionPaused = "Paused"
+	// ConditionStorageReady is true once every persistent volume claim is bound
+	ConditionStorageReady = "StorageReady"
+	// ConditionStorageResized is true once every persistent volume claim has
+	// the size requested in the spec
+	ConditionStorageResized = "StorageResized"
+	// ConditionModsReady is true once the mod downloader has installed all packages
+	ConditionModsReady = "ModsReady"
+	// ConditionServerListening is true while the server is accepting connections
+	ConditionServerListening = "ServerListening"
+	// ConditionBackupHealthy is true while backups can be written
+	ConditionBackupHealthy = "BackupHealthy"
+	// ConditionDegraded is true when any part of the server is failing
+	ConditionDegraded = "Degraded"
+)
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:deprecatedversion:warning="server.gamely.io/v1alpha1 Valheim is deprecated, use server.gamely.io/v1alpha2"
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Address",type=string,JSONPath=`.status.address`
+//+kubebuilder:printcolumn:name="Players",type=integer,JSONPath=`.status.players`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Valheim is the Schema for the valheims API
 type Valheim struct {
@@ -164,14 +477,14 @@ func init() {
 
 func (v *Valheim) GetServerName() string {
 	if v.Spec.Server.Name == "" {
-		return "Hosted by Gamely"
+		return DefaultServerName
 	}
 	return v.Spec.Server.Name
 }
 
 func (v *Valheim) GetWorldName() string {
 	if v.Spec.Server.WorldNameOrSeed == "" {
-		return "Dedicated"
+		return DefaultWorldName
 	}
 	return v.Spec.Server.WorldNameOrSeed
 }
@@ -179,15 +492,26 @@ func (v *Valheim) GetWorldName() string {
 func (v *Valheim) GetImage() string {
 	repo := v.Spec.Image.Repository
 	if repo == "" {
-		repo = "ghcr.io/lloesche/valheim-server"
+		repo = DefaultImageRepository
 	}
 	tag := v.Spec.Image.Version
 	if tag == "" {
-		tag = "latest"
+		tag = DefaultImageVersion
 	}
 	return repo + ":" + tag
 }
 
+// Restoring returns the name of the ValheimRestore replacing the world, if any
+func (v *Valheim) Restoring() string {
+	return v.Annotations[AnnotationRestoring]
+}
+
+// Stopped reports whether the server should be scaled down, either because
+// it is paused or because a restore is replacing its world
+func (v *Valheim) Stopped() bool {
+	return v.Spec.Paused || v.Restoring() != ""
+}
+
 func (v *Valheim) NamespacedName() types.NamespacedName {
 	return types.NamespacedName{
 		Namespace: v.Namespace,
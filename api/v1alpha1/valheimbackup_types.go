@@ -0,0 +1,107 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LabelScheduledBackup marks ValheimBackups recorded for backups the server
+// took on spec.backups.schedule, rather than ones that were asked for
+const LabelScheduledBackup = "gamely.io/scheduled-backup"
+
+// ValheimWorldBackupSpec defines the desired state of ValheimBackup. The
+// Valheim kind already uses ValheimBackupSpec for its backup settings.
+type ValheimWorldBackupSpec struct {
+	// ValheimRef names the Valheim in the same namespace to back up
+	ValheimRef v1.LocalObjectReference `json:"valheimRef"`
+	// Archive records an existing archive on the backups volume instead of
+	// taking a new backup. Scheduled backups are recorded this way.
+	Archive string `json:"archive,omitempty"`
+}
+
+// ValheimWorldBackupStatus defines the observed state of ValheimBackup
+type ValheimWorldBackupStatus struct {
+	Phase   ValheimBackupPhase `json:"phase,omitempty"`
+	Message string             `json:"message,omitempty"`
+
+	// Archive is the file name of the backup on the backups volume
+	Archive string `json:"archive,omitempty"`
+	// Location is where the archive is stored in the cluster
+	Location string `json:"location,omitempty"`
+	// RemoteLocation is where the archive has been uploaded to. It is left
+	// empty until the upload of the archive to the bucket is confirmed.
+	RemoteLocation string `json:"remoteLocation,omitempty"`
+	// Size of the archive in bytes
+	Size int64 `json:"size,omitempty"`
+	// Checksum of the archive, as sha256:<hex>
+	Checksum string `json:"checksum,omitempty"`
+
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// ValheimBackupPhase is the progress of a ValheimBackup
+// +kubebuilder:validation:Enum=Pending;Running;Completed;Failed
+type ValheimBackupPhase string
+
+const (
+	// ValheimBackupPhasePending means the backup is waiting on its Valheim
+	ValheimBackupPhasePending ValheimBackupPhase = "Pending"
+	// ValheimBackupPhaseRunning means the backup job is running
+	ValheimBackupPhaseRunning ValheimBackupPhase = "Running"
+	// ValheimBackupPhaseCompleted means the archive is on the backups volume
+	ValheimBackupPhaseCompleted ValheimBackupPhase = "Completed"
+	// ValheimBackupPhaseFailed means the backup could not be taken
+	ValheimBackupPhaseFailed ValheimBackupPhase = "Failed"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Valheim",type=string,JSONPath=`.spec.valheimRef.name`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Archive",type=string,JSONPath=`.status.archive`
+//+kubebuilder:printcolumn:name="Size",type=integer,JSONPath=`.status.size`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ValheimBackup is the Schema for the valheimbackups API
+type ValheimBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ValheimWorldBackupSpec   `json:"spec,omitempty"`
+	Status ValheimWorldBackupStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ValheimBackupList contains a list of ValheimBackup
+type ValheimBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ValheimBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ValheimBackup{}, &ValheimBackupList{})
+}
+
+// Finished reports whether the backup has completed or failed for good
+func (b *ValheimBackup) Finished() bool {
+	return b.Status.Phase == ValheimBackupPhaseCompleted || b.Status.Phase == ValheimBackupPhaseFailed
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AnnotationRestoring is set on a Valheim to the name of the ValheimRestore
+// replacing its world, keeping the server scaled down until it is removed
+const AnnotationRestoring = "gamely.io/restoring"
+
+// ValheimRestoreSpec defines the desired state of ValheimRestore
+type ValheimRestoreSpec struct {
+	// ValheimRef names the Valheim in the same namespace to restore
+	ValheimRef v1.LocalObjectReference `json:"valheimRef"`
+	// BackupRef names a completed ValheimBackup of the Valheim to restore
+	BackupRef *v1.LocalObjectReference `json:"backupRef,omitempty"`
+	// Archive names the archive to restore when no BackupRef is given
+	Archive string `json:"archive,omitempty"`
+	// VolumeSnapshot names a VolumeSnapshot of the world volume to restore. A
+	// new world volume is provisioned from it in place of the current one.
+	VolumeSnapshot string `json:"volumeSnapshot,omitempty"`
+	// Source is where the archive is read from
+	// +kubebuilder:default=Volume
+	Source ValheimRestoreSource `json:"source,omitempty"`
+}
+
+// ValheimRestoreSource is where an archive is restored from
+// +kubebuilder:validation:Enum=Volume;Bucket
+type ValheimRestoreSource string
+
+const (
+	// RestoreSourceVolume restores an archive from the backups volume
+	RestoreSourceVolume ValheimRestoreSource = "Volume"
+	// RestoreSourceBucket downloads the archive from the backup bucket
+	RestoreSourceBucket ValheimRestoreSource = "Bucket"
+)
+
+// ValheimRestoreStatus defines the observed state of ValheimRestore
+type ValheimRestoreStatus struct {
+	Phase   ValheimRestorePhase `json:"phase,omitempty"`
+	Message string              `json:"message,omitempty"`
+
+	// Archive is the archive or volume snapshot being restored
+	Archive string `json:"archive,omitempty"`
+	// SafetyArchive is the copy of the world taken before it was replaced
+	SafetyArchive string `json:"safetyArchive,omitempty"`
+	// SafetySnapshot is the snapshot of the world volume taken before it was
+	// replaced by a volume snapshot restore
+	SafetySnapshot string `json:"safetySnapshot,omitempty"`
+
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// ValheimRestorePhase is the progress of a ValheimRestore
+// +kubebuilder:validation:Enum=Pending;Stopping;Restoring;Starting;Completed;Failed
+type ValheimRestorePhase string
+
+const (
+	// ValheimRestorePhasePending means the restore is waiting to start
+	ValheimRestorePhasePending ValheimRestorePhase = "Pending"
+	// ValheimRestorePhaseStopping means the server is being scaled down
+	ValheimRestorePhaseStopping ValheimRestorePhase = "Stopping"
+	// ValheimRestorePhaseRestoring means the restore job or volume snapshot is replacing the world
+	ValheimRestorePhaseRestoring ValheimRestorePhase = "Restoring"
+	// ValheimRestorePhaseStarting means the server is coming back up
+	ValheimRestorePhaseStarting ValheimRestorePhase = "Starting"
+	// ValheimRestorePhaseCompleted means the server is running the restored world
+	ValheimRestorePhaseCompleted ValheimRestorePhase = "Completed"
+	// ValheimRestorePhaseFailed means the restore could not be carried out
+	ValheimRestorePhaseFailed ValheimRestorePhase = "Failed"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Valheim",type=string,JSONPath=`.spec.valheimRef.name`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Archive",type=string,JSONPath=`.status.archive`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ValheimRestore is the Schema for the valheimrestores API
+type ValheimRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ValheimRestoreSpec   `json:"spec,omitempty"`
+	Status ValheimRestoreStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ValheimRestoreList contains a list of ValheimRestore
+type ValheimRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ValheimRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ValheimRestore{}, &ValheimRestoreList{})
+}
+
+// Finished reports whether the restore has completed or failed for good
+func (r *ValheimRestore) Finished() bool {
+	return r.Status.Phase == ValheimRestorePhaseCompleted || r.Status.Phase == ValheimRestorePhaseFailed
+}
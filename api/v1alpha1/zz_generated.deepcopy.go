@@ -113,9 +113,83 @@ func (in *ValheimAccessSpec) DeepCopy() *ValheimAccessSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimBackup) DeepCopyInto(out *ValheimBackup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimBackup.
+func (in *ValheimBackup) DeepCopy() *ValheimBackup {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimBackup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ValheimBackup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimBackupList) DeepCopyInto(out *ValheimBackupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ValheimBackup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimBackupList.
+func (in *ValheimBackupList) DeepCopy() *ValheimBackupList {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimBackupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ValheimBackupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ValheimBackupSpec) DeepCopyInto(out *ValheimBackupSpec) {
 	*out = *in
+	if in.MaxCount != nil {
+		in, out := &in.MaxCount, &out.MaxCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxAgeDays != nil {
+		in, out := &in.MaxAgeDays, &out.MaxAgeDays
+		*out = new(int32)
+		**out = **in
+	}
+	if in.IfIdle != nil {
+		in, out := &in.IfIdle, &out.IfIdle
+		*out = new(bool)
+		**out = **in
+	}
 	if in.SecretKeyRef != nil {
 		in, out := &in.SecretKeyRef, &out.SecretKeyRef
 		*out = new(v1.SecretReference)
@@ -134,6 +208,33 @@ func (in *ValheimBackupSpec) DeepCopy() *ValheimBackupSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimBackupsStatus) DeepCopyInto(out *ValheimBackupsStatus) {
+	*out = *in
+	if in.LastUploadTime != nil {
+		in, out := &in.LastUploadTime, &out.LastUploadTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastIndexTime != nil {
+		in, out := &in.LastIndexTime, &out.LastIndexTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSnapshotTime != nil {
+		in, out := &in.LastSnapshotTime, &out.LastSnapshotTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimBackupsStatus.
+func (in *ValheimBackupsStatus) DeepCopy() *ValheimBackupsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimBackupsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ValheimHooksSpec) DeepCopyInto(out *ValheimHooksSpec) {
 	*out = *in
@@ -196,9 +297,101 @@ func (in *ValheimList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimLockedMod) DeepCopyInto(out *ValheimLockedMod) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimLockedMod.
+func (in *ValheimLockedMod) DeepCopy() *ValheimLockedMod {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimLockedMod)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimModOCISource) DeepCopyInto(out *ValheimModOCISource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimModOCISource.
+func (in *ValheimModOCISource) DeepCopy() *ValheimModOCISource {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimModOCISource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimModSource) DeepCopyInto(out *ValheimModSource) {
+	*out = *in
+	if in.URL != nil {
+		in, out := &in.URL, &out.URL
+		*out = new(ValheimModURLSource)
+		**out = **in
+	}
+	if in.OCI != nil {
+		in, out := &in.OCI, &out.OCI
+		*out = new(ValheimModOCISource)
+		**out = **in
+	}
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Volume != nil {
+		in, out := &in.Volume, &out.Volume
+		*out = new(ValheimModVolumeSource)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimModSource.
+func (in *ValheimModSource) DeepCopy() *ValheimModSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimModSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ValheimModSpec) DeepCopyInto(out *ValheimModSpec) {
 	*out = *in
+	if in.Settings != nil {
+		in, out := &in.Settings, &out.Settings
+		*out = make(map[string]map[string]string, len(*in))
+		for key, val := range *in {
+			var outVal map[string]string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.Source != nil {
+		in, out := &in.Source, &out.Source
+		*out = new(ValheimModSource)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimModSpec.
@@ -211,17 +404,57 @@ func (in *ValheimModSpec) DeepCopy() *ValheimModSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimModURLSource) DeepCopyInto(out *ValheimModURLSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimModURLSource.
+func (in *ValheimModURLSource) DeepCopy() *ValheimModURLSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimModURLSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimModVolumeSource) DeepCopyInto(out *ValheimModVolumeSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimModVolumeSource.
+func (in *ValheimModVolumeSource) DeepCopy() *ValheimModVolumeSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimModVolumeSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ValheimModsSpec) DeepCopyInto(out *ValheimModsSpec) {
 	*out = *in
 	out.Storage = in.Storage
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(ValheimStorageSpec)
+		**out = **in
+	}
 	if in.Packages != nil {
 		in, out := &in.Packages, &out.Packages
 		*out = make(map[string]ValheimModSpec, len(*in))
 		for key, val := range *in {
-			(*out)[key] = val
+			(*out)[key] = *val.DeepCopy()
 		}
 	}
+	if in.ValheimPlus != nil {
+		in, out := &in.ValheimPlus, &out.ValheimPlus
+		*out = new(ValheimPlusSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimModsSpec.
@@ -234,6 +467,165 @@ func (in *ValheimModsSpec) DeepCopy() *ValheimModsSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimModsStatus) DeepCopyInto(out *ValheimModsStatus) {
+	*out = *in
+	if in.Lock != nil {
+		in, out := &in.Lock, &out.Lock
+		*out = make([]ValheimLockedMod, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastFailureTime != nil {
+		in, out := &in.LastFailureTime, &out.LastFailureTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimModsStatus.
+func (in *ValheimModsStatus) DeepCopy() *ValheimModsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimModsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimPlusSpec) DeepCopyInto(out *ValheimPlusSpec) {
+	*out = *in
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = make(map[string]map[string]string, len(*in))
+		for key, val := range *in {
+			var outVal map[string]string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimPlusSpec.
+func (in *ValheimPlusSpec) DeepCopy() *ValheimPlusSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimPlusSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimRestore) DeepCopyInto(out *ValheimRestore) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimRestore.
+func (in *ValheimRestore) DeepCopy() *ValheimRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ValheimRestore) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimRestoreList) DeepCopyInto(out *ValheimRestoreList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ValheimRestore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimRestoreList.
+func (in *ValheimRestoreList) DeepCopy() *ValheimRestoreList {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimRestoreList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ValheimRestoreList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimRestoreSpec) DeepCopyInto(out *ValheimRestoreSpec) {
+	*out = *in
+	out.ValheimRef = in.ValheimRef
+	if in.BackupRef != nil {
+		in, out := &in.BackupRef, &out.BackupRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimRestoreSpec.
+func (in *ValheimRestoreSpec) DeepCopy() *ValheimRestoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimRestoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimRestoreStatus) DeepCopyInto(out *ValheimRestoreStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimRestoreStatus.
+func (in *ValheimRestoreStatus) DeepCopy() *ValheimRestoreStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimRestoreStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ValheimServerSpec) DeepCopyInto(out *ValheimServerSpec) {
 	*out = *in
@@ -309,6 +701,16 @@ func (in *ValheimSpec) DeepCopy() *ValheimSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ValheimStatus) DeepCopyInto(out *ValheimStatus) {
 	*out = *in
+	if in.LastSeen != nil {
+		in, out := &in.LastSeen, &out.LastSeen
+		*out = (*in).DeepCopy()
+	}
+	if in.LastQueried != nil {
+		in, out := &in.LastQueried, &out.LastQueried
+		*out = (*in).DeepCopy()
+	}
+	in.Backups.DeepCopyInto(&out.Backups)
+	in.Mods.DeepCopyInto(&out.Mods)
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]metav1.Condition, len(*in))
@@ -343,6 +745,45 @@ func (in *ValheimStorageSpec) DeepCopy() *ValheimStorageSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimWorldBackupSpec) DeepCopyInto(out *ValheimWorldBackupSpec) {
+	*out = *in
+	out.ValheimRef = in.ValheimRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimWorldBackupSpec.
+func (in *ValheimWorldBackupSpec) DeepCopy() *ValheimWorldBackupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimWorldBackupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimWorldBackupStatus) DeepCopyInto(out *ValheimWorldBackupStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimWorldBackupStatus.
+func (in *ValheimWorldBackupStatus) DeepCopy() *ValheimWorldBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimWorldBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ValheimWorldModifiersSpec) DeepCopyInto(out *ValheimWorldModifiersSpec) {
 	*out = *in
@@ -0,0 +1,810 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"strings"
+)
+
+// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+// ValheimSpec defines the desired state of Valheim
+type ValheimSpec struct {
+	Resources GameServerResourceSpec `json:"resources,omitempty"`
+
+	// +kubebuilder:default={}
+	Image ValheimImageSpec `json:"image,omitempty"`
+	// +kubebuilder:default={}
+	Server ValheimServerSpec `json:"server,omitempty"`
+	// +kubebuilder:default={}
+	Service        ValheimServiceSpec        `json:"service,omitempty"`
+	WorldModifiers ValheimWorldModifiersSpec `json:"worldModifiers,omitempty"`
+	Access         ValheimAccessSpec         `json:"access,omitempty"`
+	Backups        ValheimBackupSpec         `json:"backups,omitempty"`
+	Paused         bool                      `json:"paused,omitempty"`
+	Storage        ValheimStorageSpec        `json:"storage"`
+	Hooks          ValheimHooksSpec          `json:"hooks,omitempty"`
+	Mods           ValheimModsSpec           `json:"mods,omitempty"`
+	//Tasks          []ValheimTaskSpec         `json:"tasks,omitempty"`
+
+	// DeletionPolicy controls what happens to the world when the Valheim is deleted
+	// +kubebuilder:default=Delete
+	DeletionPolicy ValheimDeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// ValheimDeletionPolicy controls what happens to a server's world on deletion
+// +kubebuilder:validation:Enum=Delete;Retain;BackupThenDelete
+type ValheimDeletionPolicy string
+
+const (
+	// DeletionPolicyDelete removes all storage along with the Valheim
+	DeletionPolicyDelete ValheimDeletionPolicy = "Delete"
+	// DeletionPolicyRetain orphans the world and backup volumes so they outlive the Valheim
+	DeletionPolicyRetain ValheimDeletionPolicy = "Retain"
+	// DeletionPolicyBackupThenDelete takes a final backup of the world before
+	// removing storage. Without a bucket to upload it to, the backup volume
+	// is retained to keep the final backup.
+	DeletionPolicyBackupThenDelete ValheimDeletionPolicy = "BackupThenDelete"
+)
+
+type ValheimHooksSpec struct {
+	PreSupervisorHook       string `json:"preSupervisorHook,omitempty"`
+	PreBootstrapHook        string `json:"preBootstrapHook,omitempty"`
+	PostBootstrapHook       string `json:"postBootstrapHook,omitempty"`
+	PreBackupHook           string `json:"preBackupHook,omitempty"`
+	PostBackupHook          string `json:"postBackupHook,omitempty"`
+	PreUpdateCheckHook      string `json:"preUpdateCheckHook,omitempty"`
+	PostUpdateCheckHook     string `json:"postUpdateCheckHook,omitempty"`
+	PreStartHook            string `json:"preStartHook,omitempty"`
+	PostStartHook           string `json:"postStartHook,omitempty"`
+	PreRestartHook          string `json:"preRestartHook,omitempty"`
+	PreServerListeningHook  string `json:"preServerListeningHook,omitempty"`
+	PostServerListeningHook string `json:"postServerListeningHook,omitempty"`
+	PostRestartHook         string `json:"postRestartHook,omitempty"`
+	PreServerRunHook        string `json:"preServerRunHook,omitempty"`
+	PostServerRunHook       string `json:"postServerRunHook,omitempty"`
+	PreServerShutdownHook   string `json:"preServerShutdownHook,omitempty"`
+	PostServerShutdownHook  string `json:"postServerShutdownHook,omitempty"`
+	PreBepinexConfigHook    string `json:"preBepinexConfigHook,omitempty"`
+	PostBepinexConfigHook   string `json:"postBepinexConfigHook,omitempty"`
+}
+type ValheimImageSpec struct {
+	// +kubebuilder:default="ghcr.io/lloesche/valheim-server"
+	Repository string `json:"repository,omitempty"`
+	// +kubebuilder:default=latest
+	Version    string        `json:"version"`
+	PullPolicy v1.PullPolicy `json:"pullPolicy,omitempty"`
+}
+
+// +kubebuilder:validation:XValidation:rule="!has(self.password) || !has(self.passwordRotation)",message="only generated passwords are rotated, unset password or rotate its secret yourself"
+type ValheimServerSpec struct {
+	// +kubebuilder:default="Hosted by Gamely"
+	Name string `json:"name,omitempty"`
+	// Password selects the key of a Secret holding the server password. One
+	// is generated when it is not set, and reported in status.passwordSecretRef.
+	Password *ValheimSecretKeySelector `json:"password,omitempty"`
+	// PasswordPolicy is how passwords are generated, both the first one and
+	// those replacing it on rotation
+	PasswordPolicy *ValheimPasswordPolicy `json:"passwordPolicy,omitempty"`
+	// PasswordRotation replaces the password on a schedule. Only generated
+	// passwords are rotated, so it cannot be combined with Password.
+	PasswordRotation *ValheimPasswordRotationSpec `json:"passwordRotation,omitempty"`
+	// +kubebuilder:default=Dedicated
+	WorldNameOrSeed string            `json:"worldNameOrSeed,omitempty"`
+	Public          bool              `json:"public,omitempty"`
+	AdditionalArgs  []string          `json:"additionalArgs,omitempty"`
+	AdditionalEnv   map[string]string `json:"additionalEnv,omitempty"`
+}
+
+// ValheimPasswordPolicy is how server passwords are generated. Valheim only
+// accepts passwords of at least 5 characters that are not part of the server
+// name, which generated passwords always satisfy.
+type ValheimPasswordPolicy struct {
+	// +kubebuilder:validation:Minimum=5
+	// +kubebuilder:validation:Maximum=64
+	// +kubebuilder:default=12
+	Length int32 `json:"length,omitempty"`
+	// +kubebuilder:default=alphanumeric
+	Charset ValheimPasswordCharset `json:"charset,omitempty"`
+}
+
+// ValheimPasswordCharset is the characters generated passwords are made of
+// +kubebuilder:validation:Enum=alphanumeric;alphabetic;numeric
+type ValheimPasswordCharset string
+
+const (
+	PasswordCharsetAlphanumeric ValheimPasswordCharset = "alphanumeric"
+	PasswordCharsetAlphabetic   ValheimPasswordCharset = "alphabetic"
+	PasswordCharsetNumeric      ValheimPasswordCharset = "numeric"
+)
+
+// Characters returns the characters of the charset
+func (c ValheimPasswordCharset) Characters() string {
+	letters := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digits := "0123456789"
+	switch c {
+	case PasswordCharsetAlphabetic:
+		return letters
+	case PasswordCharsetNumeric:
+		return digits
+	default:
+		return letters + digits
+	}
+}
+
+func (p *ValheimPasswordPolicy) GetLength() int {
+	if p == nil || p.Length == 0 {
+		return DefaultPasswordLength
+	}
+	return int(p.Length)
+}
+
+func (p *ValheimPasswordPolicy) GetCharset() ValheimPasswordCharset {
+	if p == nil || p.Charset == "" {
+		return PasswordCharsetAlphanumeric
+	}
+	return p.Charset
+}
+
+// ValheimPasswordRotationSpec replaces the server password on a schedule
+type ValheimPasswordRotationSpec struct {
+	// Schedule is the cron schedule the password is replaced on. The server
+	// restarts to pick up the new password, disconnecting every player, so
+	// pick a quiet hour.
+	Schedule string `json:"schedule"`
+}
+
+// ValheimSecretKeySelector selects a key of a Secret
+type ValheimSecretKeySelector struct {
+	Name string `json:"name"`
+	// +kubebuilder:default=password
+	Key string `json:"key,omitempty"`
+	// Namespace of the Secret, when it is not the namespace of the server.
+	// The key is copied into a Secret next to the server, which pods can
+	// only read Secrets of their own namespace. The Secret must allow the
+	// namespace of the server in AnnotationPasswordNamespaces.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// AnnotationPasswordNamespaces is set on a Secret to the comma separated
+// namespaces whose Valheims may read their password from it, or * for every
+// namespace. Without it, Secrets are only used by servers of their own
+// namespace, as the key is copied to wherever the server runs.
+const AnnotationPasswordNamespaces = "gamely.io/password-namespaces"
+
+// PasswordSecretAllowed reports whether a Valheim in namespace may read its
+// password from secret
+func PasswordSecretAllowed(secret *v1.Secret, namespace string) bool {
+	if secret.Namespace == namespace {
+		return true
+	}
+	for _, allowed := range strings.Split(secret.Annotations[AnnotationPasswordNamespaces], ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "*" || allowed == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func (s ValheimSecretKeySelector) GetKey() string {
+	if s.Key == "" {
+		return DefaultPasswordKey
+	}
+	return s.Key
+}
+
+type ValheimServiceSpec struct {
+	// +kubebuilder:default=ClusterIP
+	Type string `json:"type,omitempty"`
+}
+
+// ValheimAccessSpec lists players by SteamID. The lists are synced into the
+// config directory of the running server, so changes apply without a restart.
+// They are only synced when they change here or in the selected player
+// lists, so players banned, unbanned or permitted in game stay that way until
+// then. The next change replaces the lists in game with these.
+type ValheimAccessSpec struct {
+	Admins    []string `json:"admins,omitempty"`
+	Banned    []string `json:"banned,omitempty"`
+	Permitted []string `json:"permitted,omitempty"`
+	// PlayerListSelector selects ValheimPlayerLists in the namespace of the
+	// server, whose players are added to the lists above
+	PlayerListSelector *metav1.LabelSelector `json:"playerListSelector,omitempty"`
+}
+
+// ValheimWorldModifiersSpec adjusts the difficulty of the world. They are
+// passed to the server as -preset, -modifier and -setkey arguments.
+type ValheimWorldModifiersSpec struct {
+	// Preset is the world preset the modifiers are applied on top of
+	Preset       ValheimWorldPreset          `json:"preset,omitempty"`
+	Combat       ValheimCombatModifier       `json:"combat,omitempty"`
+	DeathPenalty ValheimDeathPenaltyModifier `json:"deathPenalty,omitempty"`
+	Raids        ValheimRaidsModifier        `json:"raids,omitempty"`
+	Resources    ValheimResourcesModifier    `json:"resources,omitempty"`
+	Portals      ValheimPortalsModifier      `json:"portals,omitempty"`
+	// Keys are global keys set on the world
+	Keys []ValheimWorldKey `json:"keys,omitempty"`
+}
+
+// ValheimCombatModifier is how hard enemies hit and how much they take
+// +kubebuilder:validation:Enum=veryeasy;easy;hard;veryhard
+type ValheimCombatModifier string
+
+// ValheimDeathPenaltyModifier is what players lose when they die
+// +kubebuilder:validation:Enum=casual;veryeasy;easy;hard;hardcore
+type ValheimDeathPenaltyModifier string
+
+// ValheimRaidsModifier is how often bases are raided
+// +kubebuilder:validation:Enum=none;muchless;less;more;muchmore
+type ValheimRaidsModifier string
+
+// ValheimResourcesModifier is how many resources are dropped
+// +kubebuilder:validation:Enum=muchless;less;more;muchmore;most
+type ValheimResourcesModifier string
+
+// ValheimPortalsModifier is what may be carried through portals
+// +kubebuilder:validation:Enum=casual;hard;veryhard
+type ValheimPortalsModifier string
+
+// ValheimWorldPreset is a world preset of the game
+// +kubebuilder:validation:Enum=normal;casual;easy;hard;hardcore;immersive;hammer
+type ValheimWorldPreset string
+
+// ValheimWorldKey is a global key of the world. nobuildcost removes the cost
+// of building, playerevents bases raids on the progress of the players
+// nearby, passivemobs stops enemies attacking unprovoked and nomap disables
+// the map.
+// +kubebuilder:validation:Enum=nobuildcost;playerevents;passivemobs;nomap
+type ValheimWorldKey string
+
+const (
+	WorldKeyNoBuildCost  ValheimWorldKey = "nobuildcost"
+	WorldKeyPlayerEvents ValheimWorldKey = "playerevents"
+	WorldKeyPassiveMobs  ValheimWorldKey = "passivemobs"
+	WorldKeyNoMap        ValheimWorldKey = "nomap"
+)
+
+// WorldModifierValues are the values the game accepts for each -modifier
+var WorldModifierValues = map[string][]string{
+	"combat":       {"veryeasy", "easy", "hard", "veryhard"},
+	"deathpenalty": {"casual", "veryeasy", "easy", "hard", "hardcore"},
+	"raids":        {"none", "muchless", "less", "more", "muchmore"},
+	"resources":    {"muchless", "less", "more", "muchmore", "most"},
+	"portals":      {"casual", "hard", "veryhard"},
+}
+
+// WorldPresets are the values the game accepts for -preset
+var WorldPresets = []ValheimWorldPreset{"normal", "casual", "easy", "hard", "hardcore", "immersive", "hammer"}
+
+// WorldKeys are the values the game accepts for -setkey
+var WorldKeys = []ValheimWorldKey{WorldKeyNoBuildCost, WorldKeyPlayerEvents, WorldKeyPassiveMobs, WorldKeyNoMap}
+
+// Modifiers returns the -modifier settings of the world, keyed by the name
+// the game knows them by
+func (m ValheimWorldModifiersSpec) Modifiers() map[string]string {
+	modifiers := map[string]string{}
+	for name, value := range map[string]string{
+		"combat":       string(m.Combat),
+		"deathpenalty": string(m.DeathPenalty),
+		"raids":        string(m.Raids),
+		"resources":    string(m.Resources),
+		"portals":      string(m.Portals),
+	} {
+		if value != "" {
+			modifiers[name] = value
+		}
+	}
+	return modifiers
+}
+
+type ValheimBackupSpec struct {
+	Schedule string `json:"schedule,omitempty"`
+	// Mode is how backups are taken on the schedule. archive zips the world
+	// from inside the server, volumeSnapshot takes CSI snapshots of the world volume.
+	// +kubebuilder:default=archive
+	Mode ValheimBackupMode `json:"mode,omitempty"`
+	// VolumeSnapshotClassName is the class of snapshots taken in volumeSnapshot mode
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+	// MaxCount is how many backups to keep, both on the backups volume and in
+	// the bucket. Zero keeps every backup. Defaults to 5.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=5
+	MaxCount *int32 `json:"maxCount,omitempty"`
+	// MaxAgeDays is how many days backups are kept for, both on the backups
+	// volume and in the bucket. Defaults to 3.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=3
+	MaxAgeDays *int32 `json:"maxAgeDays,omitempty"`
+	// IfIdle keeps taking scheduled backups while no players are connected.
+	// Defaults to true.
+	// +kubebuilder:default=true
+	IfIdle *bool `json:"ifIdle,omitempty"`
+	// Compression is the archive format backups are written in
+	// +kubebuilder:default=zip
+	Compression ValheimBackupCompression `json:"compression,omitempty"`
+	// Directory is where the backups volume is mounted in the server
+	// +kubebuilder:validation:Pattern=`^/`
+	// +kubebuilder:default="/config/backups"
+	Directory string `json:"directory,omitempty"`
+	// SecretKeyRef names a secret in the server's namespace holding the
+	// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY used to upload backups
+	SecretKeyRef *v1.SecretReference `json:"secretKeyRef,omitempty"`
+	// Endpoint is the URL of an S3 compatible service, defaulting to AWS. The
+	// scheme is required, so a plain http service such as a local MinIO is
+	// reached as given.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	Endpoint string `json:"endpoint,omitempty"`
+	// Bucket backups are uploaded to, under a <namespace>/<name>/ prefix.
+	// Backups are only kept on the backups volume when it is empty.
+	Bucket string `json:"bucket"`
+	// UploadSchedule is the cron schedule new backups are uploaded on
+	UploadSchedule string             `json:"uploadSchedule,omitempty"`
+	Storage        ValheimStorageSpec `json:"storage"`
+}
+
+// ValheimBackupMode is how scheduled backups are taken
+// +kubebuilder:validation:Enum=archive;volumeSnapshot
+type ValheimBackupMode string
+
+const (
+	BackupModeArchive        ValheimBackupMode = "archive"
+	BackupModeVolumeSnapshot ValheimBackupMode = "volumeSnapshot"
+)
+
+// ValheimBackupCompression is the archive format of backups
+// +kubebuilder:validation:Enum=zip;tar.gz
+type ValheimBackupCompression string
+
+const (
+	BackupCompressionZip   ValheimBackupCompression = "zip"
+	BackupCompressionTarGz ValheimBackupCompression = "tar.gz"
+)
+
+const (
+	// DefaultBackupsMaxCount is how many backups are kept when no maxCount is set
+	DefaultBackupsMaxCount = 5
+	// DefaultBackupsMaxAgeDays is how many days backups are kept for when no maxAgeDays is set
+	DefaultBackupsMaxAgeDays = 3
+	// DefaultBackupsDirectory is where the backups volume is mounted when no directory is set
+	DefaultBackupsDirectory = "/config/backups"
+)
+
+const (
+	// DefaultPasswordKey is the key of the password Secret holding the
+	// password when no key is set
+	DefaultPasswordKey = "password"
+	// DefaultPasswordLength is how long generated passwords are when no
+	// policy sets a length
+	DefaultPasswordLength = 12
+	// MinPasswordLength is the shortest password Valheim accepts
+	MinPasswordLength = 5
+	// DefaultServerName is the name servers are listed under when no name is set
+	DefaultServerName = "Hosted by Gamely"
+	// DefaultWorldName is the world a server runs when no world name or seed is set
+	DefaultWorldName = "Dedicated"
+	// DefaultImageRepository and DefaultImageVersion are the server image run
+	// when no image is set
+	DefaultImageRepository = "ghcr.io/lloesche/valheim-server"
+	DefaultImageVersion    = "latest"
+	// DefaultServiceType is how servers are exposed when no service type is set
+	DefaultServiceType = "ClusterIP"
+)
+
+func (b ValheimBackupSpec) GetMode() ValheimBackupMode {
+	if b.Mode == "" {
+		return BackupModeArchive
+	}
+	return b.Mode
+}
+
+func (b ValheimBackupSpec) GetMaxCount() int32 {
+	if b.MaxCount == nil {
+		return DefaultBackupsMaxCount
+	}
+	return *b.MaxCount
+}
+
+func (b ValheimBackupSpec) GetMaxAgeDays() int32 {
+	if b.MaxAgeDays == nil {
+		return DefaultBackupsMaxAgeDays
+	}
+	return *b.MaxAgeDays
+}
+
+func (b ValheimBackupSpec) GetIfIdle() bool {
+	return b.IfIdle == nil || *b.IfIdle
+}
+
+func (b ValheimBackupSpec) GetCompression() ValheimBackupCompression {
+	if b.Compression == "" {
+		return BackupCompressionZip
+	}
+	return b.Compression
+}
+
+func (b ValheimBackupSpec) GetDirectory() string {
+	if b.Directory == "" {
+		return DefaultBackupsDirectory
+	}
+	return b.Directory
+}
+
+// ValheimStorageSpec is the size and class of a volume. Volumes cannot move to
+// another class, which holds even when the webhook is not running.
+// +kubebuilder:validation:XValidation:rule="has(self.class) == has(oldSelf.class) && (!has(self.class) || self.class == oldSelf.class)",message="the storage class of a volume cannot be changed"
+type ValheimStorageSpec struct {
+	Size  string `json:"size"`
+	Class string `json:"class,omitempty"`
+}
+
+type ValheimModsSpec struct {
+	Enabled   bool               `json:"enabled"`
+	Framework string             `json:"framework"`
+	Storage   ValheimStorageSpec `json:"storage"`
+	// Cache is a ReadWriteMany volume of downloaded mod archives, shared by
+	// every Valheim in the namespace so each archive is only downloaded once.
+	// The first server to create it decides its size and class. Without it
+	// archives are downloaded on every start.
+	Cache    *ValheimStorageSpec       `json:"cache,omitempty"`
+	Packages map[string]ValheimModSpec `json:"packages"`
+	// ValheimPlus is rendered into valheim_plus.cfg when the framework is
+	// ValheimPlus
+	ValheimPlus *ValheimPlusSpec `json:"valheimPlus,omitempty"`
+}
+
+// ValheimPlusSpec configures ValheimPlus
+type ValheimPlusSpec struct {
+	// Config maps sections of valheim_plus.cfg to their settings, as in
+	// {"Server": {"enabled": "true", "maxPlayers": "20"}}. Only sections and
+	// settings ValheimPlus knows are accepted. Anything left out keeps its
+	// ValheimPlus default. ValheimPlus only reads its config on start, so
+	// changes restart the server.
+	Config map[string]map[string]string `json:"config,omitempty"`
+}
+
+type ValheimModSpec struct {
+	Version string `json:"version,omitempty"`
+	// Config is the raw contents of the BepInEx config file of the plugin.
+	// Exclusive with Settings.
+	Config string `json:"config,omitempty"`
+	// Settings maps sections of the BepInEx config file of the plugin to their
+	// settings, rendered by the operator. Exclusive with Config.
+	Settings map[string]map[string]string `json:"settings,omitempty"`
+	// ConfigFile is the name of the config file the plugin reads from
+	// BepInEx/config, which is usually its GUID, as in
+	// com.example.plugin.cfg. Defaults to the package name with the / replaced
+	// by a dot, as in Owner.Name.cfg.
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z0-9]+\.cfg$`
+	ConfigFile string `json:"configFile,omitempty"`
+	// SHA256 is the checksum the archive of Version must have. Without it the
+	// checksum of the first download is locked in status.mods.lock. Required
+	// for url sources.
+	// +kubebuilder:validation:Pattern=`^[a-f0-9]{64}$`
+	SHA256 string `json:"sha256,omitempty"`
+	// Source installs the package from somewhere other than Thunderstore
+	Source *ValheimModSource `json:"source,omitempty"`
+}
+
+// ValheimModSource is where a package that is not on Thunderstore is
+// installed from. Exactly one source is set. Sources hold a mod archive, or a
+// single DLL that is installed as a plugin.
+// +kubebuilder:validation:MinProperties=1
+// +kubebuilder:validation:MaxProperties=1
+type ValheimModSource struct {
+	// URL downloads the package over HTTP(S)
+	URL *ValheimModURLSource `json:"url,omitempty"`
+	// OCI pulls the package from an OCI artifact
+	OCI *ValheimModOCISource `json:"oci,omitempty"`
+	// ConfigMap reads the package from a key of a ConfigMap
+	ConfigMap *v1.ConfigMapKeySelector `json:"configMap,omitempty"`
+	// Secret reads the package from a key of a Secret
+	Secret *v1.SecretKeySelector `json:"secret,omitempty"`
+	// Volume reads the package from a file on an existing persistent volume claim
+	Volume *ValheimModVolumeSource `json:"volume,omitempty"`
+}
+
+type ValheimModURLSource struct {
+	// +kubebuilder:validation:Pattern=`^https?://[^\s]+$`
+	URL string `json:"url"`
+}
+
+type ValheimModOCISource struct {
+	// Reference is the artifact to pull, as in registry.example.com/mods/mymod:1.0.0
+	Reference string `json:"reference"`
+	// PullSecret names a kubernetes.io/dockerconfigjson Secret to authenticate with
+	PullSecret string `json:"pullSecret,omitempty"`
+}
+
+type ValheimModVolumeSource struct {
+	ClaimName string `json:"claimName"`
+	// Path is the file on the volume to install
+	Path string `json:"path"`
+}
+
+//type ValheimTaskSpec struct {
+//	Schedule string `json:"schedule"`
+//}
+
+// ValheimStatus defines the observed state of Valheim
+type ValheimStatus struct {
+	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
+	// Important: Run "make" to regenerate code after modifying this file
+	WorldStorage string `json:"worldStorage,omitempty"`
+
+	Phase   ValheimPhase `json:"phase,omitempty"`
+	Address string       `json:"address,omitempty"`
+	// Players, MaxPlayers and Version are what the server last reported over
+	// its query port, at LastSeen
+	Players    int32        `json:"players,omitempty"`
+	MaxPlayers int32        `json:"maxPlayers,omitempty"`
+	Version    string       `json:"version,omitempty"`
+	LastSeen   *metav1.Time `json:"lastSeen,omitempty"`
+	// LastQueried is when the server was last queried, whether or not it
+	// answered
+	LastQueried *metav1.Time `json:"lastQueried,omitempty"`
+
+	// PasswordSecretRef is the Secret key holding the server password, either
+	// the one of spec.server.password or the one generated when it is not set
+	PasswordSecretRef *ValheimSecretKeySelector `json:"passwordSecretRef,omitempty"`
+
+	Backups ValheimBackupsStatus `json:"backups,omitempty"`
+	Mods    ValheimModsStatus    `json:"mods,omitempty"`
+
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+	Ready              bool               `json:"ready,omitempty"`
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+}
+
+// ValheimBackupsStatus describes the backups shipped off the backups volume
+type ValheimBackupsStatus struct {
+	// LastUploadTime is when backups were last uploaded to the bucket successfully
+	LastUploadTime *metav1.Time `json:"lastUploadTime,omitempty"`
+	// LastIndexTime is when the backups volume was last checked for
+	// scheduled backups to record as ValheimBackups
+	LastIndexTime *metav1.Time `json:"lastIndexTime,omitempty"`
+	// LastSnapshotTime is when a volume snapshot was last taken on the schedule
+	LastSnapshotTime *metav1.Time `json:"lastSnapshotTime,omitempty"`
+}
+
+// ValheimModsStatus describes the mod packages resolved for the server
+type ValheimModsStatus struct {
+	// Lock is every package the mod downloader installs, the requested
+	// packages and their dependencies, locked to exact versions
+	Lock []ValheimLockedMod `json:"lock,omitempty"`
+	// ResolvedGeneration is the generation of the Valheim the lock was resolved for
+	ResolvedGeneration int64 `json:"resolvedGeneration,omitempty"`
+	// FailedGeneration is the generation of the Valheim resolution last
+	// failed for. It is retried with a backoff until it succeeds or the spec changes.
+	FailedGeneration int64 `json:"failedGeneration,omitempty"`
+	// Failures counts the failed attempts at resolving FailedGeneration
+	Failures int32 `json:"failures,omitempty"`
+	// LastFailureTime is when resolution last failed
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+	// FailureMessage is why resolution last failed
+	FailureMessage string `json:"failureMessage,omitempty"`
+}
+
+// ValheimLockedMod is a package locked to the version to install
+type ValheimLockedMod struct {
+	// Package is the Thunderstore package, as in Owner/Name
+	Package     string `json:"package"`
+	Version     string `json:"version"`
+	DownloadURL string `json:"downloadURL,omitempty"`
+	// SHA256 is the checksum the archive is verified against
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// ValheimPhase summarises the conditions of a Valheim server
+// +kubebuilder:validation:Enum=Pending;Starting;Running;Paused;Restoring;Degraded;Terminating
+type ValheimPhase string
+
+const (
+	// ValheimPhasePending means the server is waiting on its storage
+	ValheimPhasePending ValheimPhase = "Pending"
+	// ValheimPhaseStarting means the server pod is installing mods or booting
+	ValheimPhaseStarting ValheimPhase = "Starting"
+	// ValheimPhaseRunning means the server is up and accepting players
+	ValheimPhaseRunning ValheimPhase = "Running"
+	// ValheimPhasePaused means spec.paused has scaled the server down
+	ValheimPhasePaused ValheimPhase = "Paused"
+	// ValheimPhaseDegraded means something is failing and needs attention
+	ValheimPhaseDegraded ValheimPhase = "Degraded"
+	// ValheimPhaseRestoring means the server is stopped while a ValheimRestore replaces its world
+	ValheimPhaseRestoring ValheimPhase = "Restoring"
+	// ValheimPhaseTerminating means the Valheim is being deleted
+	ValheimPhaseTerminating ValheimPhase = "Terminating"
+)
+
+const (
+	// ConditionPaused is true while spec.paused has scaled the server down
+	ConditionPaused = "Paused"
+	// ConditionStorageReady is true once every persistent volume claim is bound
+	ConditionStorageReady = "StorageReady"
+	// ConditionStorageResized is true once every persistent volume claim has
+	// the size requested in the spec
+	ConditionStorageResized = "StorageResized"
+	// ConditionModsReady is true once the mod downloader has installed all packages
+	ConditionModsReady = "ModsReady"
+	// ConditionServerListening is true while the server is accepting connections
+	ConditionServerListening = "ServerListening"
+	// ConditionBackupHealthy is true while backups can be written
+	ConditionBackupHealthy = "BackupHealthy"
+	// ConditionPasswordReady is true while the Secret key holding the server
+	// password exists
+	ConditionPasswordReady = "PasswordReady"
+	// ConditionDegraded is true when any part of the server is failing
+	ConditionDegraded = "Degraded"
+)
+
+// AnnotationRestoring is set on a Valheim to the name of the ValheimRestore
+// replacing its world, keeping the server scaled down until it is removed
+const AnnotationRestoring = "gamely.io/restoring"
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Address",type=string,JSONPath=`.status.address`
+//+kubebuilder:printcolumn:name="Players",type=integer,JSONPath=`.status.players`
+//+kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.version`,priority=1
+//+kubebuilder:printcolumn:name="Last Seen",type=date,JSONPath=`.status.lastSeen`,priority=1
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Valheim is the Schema for the valheims API
+type Valheim struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ValheimSpec   `json:"spec,omitempty"`
+	Status ValheimStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ValheimList contains a list of Valheim
+type ValheimList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Valheim `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Valheim{}, &ValheimList{})
+}
+
+func (v *Valheim) GetServerName() string {
+	if v.Spec.Server.Name == "" {
+		return DefaultServerName
+	}
+	return v.Spec.Server.Name
+}
+
+func (v *Valheim) GetWorldName() string {
+	if v.Spec.Server.WorldNameOrSeed == "" {
+		return DefaultWorldName
+	}
+	return v.Spec.Server.WorldNameOrSeed
+}
+
+func (v *Valheim) GetImage() string {
+	repo := v.Spec.Image.Repository
+	if repo == "" {
+		repo = DefaultImageRepository
+	}
+	tag := v.Spec.Image.Version
+	if tag == "" {
+		tag = DefaultImageVersion
+	}
+	return repo + ":" + tag
+}
+
+// Restoring returns the name of the ValheimRestore replacing the world, if any
+func (v *Valheim) Restoring() string {
+	return v.Annotations[AnnotationRestoring]
+}
+
+// Stopped reports whether the server should be scaled down, either because
+// it is paused or because a restore is replacing its world
+func (v *Valheim) Stopped() bool {
+	return v.Spec.Paused || v.Restoring() != ""
+}
+
+func (v *Valheim) NamespacedName() types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: v.Namespace,
+		Name:      v.Name,
+	}
+}
+
+func (v *Valheim) GetServiceType() v1.ServiceType {
+	switch v.Spec.Service.Type {
+	case "LoadBalancer":
+		return v1.ServiceTypeLoadBalancer
+	case "NodePort":
+		return v1.ServiceTypeNodePort
+	default:
+		return v1.ServiceTypeClusterIP
+	}
+}
+
+func (v *Valheim) FilteredHooksMap() map[string]string {
+	hooks := v.Spec.Hooks
+	data := map[string]string{}
+	if hooks.PreSupervisorHook != "" {
+		data["PRE_SUPERVISOR_HOOK"] = hooks.PreSupervisorHook
+	}
+	if hooks.PreBootstrapHook != "" {
+		data["PRE_BOOTSTRAP_HOOK"] = hooks.PreBootstrapHook
+	}
+	if hooks.PostBootstrapHook != "" {
+		data["POST_BOOTSTRAP_HOOK"] = hooks.PostBootstrapHook
+	}
+	if hooks.PreBackupHook != "" {
+		data["PRE_BACKUP_HOOK"] = hooks.PreBackupHook
+	}
+	if hooks.PostBackupHook != "" {
+		data["POST_BACKUP_HOOK"] = hooks.PostBackupHook
+	}
+	if hooks.PreUpdateCheckHook != "" {
+		data["PRE_UPDATE_CHECK_HOOK"] = hooks.PreUpdateCheckHook
+	}
+	if hooks.PostUpdateCheckHook != "" {
+		data["POST_UPDATE_CHECK_HOOK"] = hooks.PostUpdateCheckHook
+	}
+	if hooks.PreStartHook != "" {
+		data["PRE_START_HOOK"] = hooks.PreStartHook
+	}
+	if hooks.PostStartHook != "" {
+		data["POST_START_HOOK"] = hooks.PostStartHook
+	}
+	if hooks.PreRestartHook != "" {
+		data["PRE_RESTART_HOOK"] = hooks.PreRestartHook
+	}
+	if hooks.PreServerListeningHook != "" {
+		data["PRE_SERVER_LISTENING_HOOK"] = hooks.PreServerListeningHook
+	}
+	if hooks.PostServerListeningHook != "" {
+		data["POST_SERVER_LISTENING_HOOK"] = hooks.PostServerListeningHook
+	}
+	if hooks.PostRestartHook != "" {
+		data["POST_RESTART_HOOK"] = hooks.PostRestartHook
+	}
+	if hooks.PreServerRunHook != "" {
+		data["PRE_SERVER_RUN_HOOK"] = hooks.PreServerRunHook
+	}
+	if hooks.PostServerRunHook != "" {
+		data["POST_SERVER_RUN_HOOK"] = hooks.PostServerRunHook
+	}
+	if hooks.PreServerShutdownHook != "" {
+		data["PRE_SERVER_SHUTDOWN_HOOK"] = hooks.PreServerShutdownHook
+	}
+	if hooks.PostServerShutdownHook != "" {
+		data["POST_SERVER_SHUTDOWN_HOOK"] = hooks.PostServerShutdownHook
+	}
+	if hooks.PreBepinexConfigHook != "" {
+		data["PRE_BEPINEX_CONFIG_HOOK"] = hooks.PreBepinexConfigHook
+	}
+	if hooks.PostBepinexConfigHook != "" {
+		data["POST_BEPINEX_CONFIG_HOOK"] = hooks.PostBepinexConfigHook
+	}
+	return data
+}
@@ -0,0 +1,351 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/robfig/cron/v3"
+	"github.com/robwittman/gamely/internal/valheimplus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var valheimlog = logf.Log.WithName("valheim-resource")
+
+// steamIDPattern matches the SteamID64s the server lists access by
+var steamIDPattern = regexp.MustCompile(`^[0-9]+$`)
+
+// passwordSecretReader reads the Secrets servers ask to read their password
+// from in other namespaces. Such passwords are rejected while it is nil.
+var passwordSecretReader client.Reader
+
+func (r *Valheim) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	passwordSecretReader = mgr.GetAPIReader()
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-server-gamely-io-v1alpha2-valheim,mutating=true,failurePolicy=fail,sideEffects=None,groups=server.gamely.io,resources=valheims,verbs=create;update,versions=v1alpha2,name=mvalheim.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &Valheim{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the
+// type. Defaults are persisted so the object shows what the server runs with.
+func (r *Valheim) Default() {
+	valheimlog.Info("default", "name", r.Name)
+
+	if r.Spec.Image.Repository == "" {
+		r.Spec.Image.Repository = DefaultImageRepository
+	}
+	if r.Spec.Image.Version == "" {
+		r.Spec.Image.Version = DefaultImageVersion
+	}
+	if r.Spec.Server.Name == "" {
+		r.Spec.Server.Name = DefaultServerName
+	}
+	if r.Spec.Server.WorldNameOrSeed == "" {
+		r.Spec.Server.WorldNameOrSeed = DefaultWorldName
+	}
+	if r.Spec.Server.Password != nil && r.Spec.Server.Password.Key == "" {
+		r.Spec.Server.Password.Key = DefaultPasswordKey
+	}
+	if r.Spec.Service.Type == "" {
+		r.Spec.Service.Type = DefaultServiceType
+	}
+	if r.Spec.DeletionPolicy == "" {
+		r.Spec.DeletionPolicy = DeletionPolicyDelete
+	}
+
+	backups := &r.Spec.Backups
+	if backups.Mode == "" {
+		backups.Mode = BackupModeArchive
+	}
+	if backups.MaxCount == nil {
+		maxCount := int32(DefaultBackupsMaxCount)
+		backups.MaxCount = &maxCount
+	}
+	if backups.MaxAgeDays == nil {
+		maxAgeDays := int32(DefaultBackupsMaxAgeDays)
+		backups.MaxAgeDays = &maxAgeDays
+	}
+	if backups.IfIdle == nil {
+		ifIdle := true
+		backups.IfIdle = &ifIdle
+	}
+	if backups.Compression == "" {
+		backups.Compression = BackupCompressionZip
+	}
+	if backups.Directory == "" {
+		backups.Directory = DefaultBackupsDirectory
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-server-gamely-io-v1alpha2-valheim,mutating=false,failurePolicy=fail,sideEffects=None,groups=server.gamely.io,resources=valheims,verbs=create;update,versions=v1alpha2,name=vvalheim.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &Valheim{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Valheim) ValidateCreate() error {
+	valheimlog.Info("validate create", "name", r.Name)
+
+	return r.invalid(r.validateSpec())
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Valheim) ValidateUpdate(old runtime.Object) error {
+	valheimlog.Info("validate update", "name", r.Name)
+
+	errs := r.validateSpec()
+	if previous, ok := old.(*Valheim); ok {
+		errs = append(errs, r.validateSpecUpdate(previous)...)
+	}
+	return r.invalid(errs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Valheim) ValidateDelete() error {
+	return nil
+}
+
+func (r *Valheim) invalid(errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Valheim").GroupKind(), r.Name, errs)
+}
+
+func (r *Valheim) validateSpec() field.ErrorList {
+	spec := field.NewPath("spec")
+	errs := field.ErrorList{}
+
+	errs = append(errs, validateQuantity(spec.Child("storage", "size"), r.Spec.Storage.Size)...)
+	errs = append(errs, validateQuantity(spec.Child("backups", "storage", "size"), r.Spec.Backups.Storage.Size)...)
+
+	switch r.Spec.Service.Type {
+	case "", "ClusterIP", "NodePort", "LoadBalancer":
+	default:
+		errs = append(errs, field.NotSupported(spec.Child("service", "type"), r.Spec.Service.Type, []string{"ClusterIP", "NodePort", "LoadBalancer"}))
+	}
+
+	errs = append(errs, validateSchedule(spec.Child("backups", "schedule"), r.Spec.Backups.Schedule)...)
+	errs = append(errs, validateSchedule(spec.Child("backups", "uploadSchedule"), r.Spec.Backups.UploadSchedule)...)
+
+	errs = append(errs, r.validateWorldModifiers(spec.Child("worldModifiers"))...)
+	errs = append(errs, r.validatePasswordNamespace(spec.Child("server", "password", "namespace"))...)
+	errs = append(errs, r.validatePasswordRotation(spec.Child("server", "passwordRotation"))...)
+
+	access := spec.Child("access")
+	errs = append(errs, validateSteamIDs(access.Child("admins"), r.Spec.Access.Admins)...)
+	errs = append(errs, validateSteamIDs(access.Child("banned"), r.Spec.Access.Banned)...)
+	errs = append(errs, validateSteamIDs(access.Child("permitted"), r.Spec.Access.Permitted)...)
+	if selector := r.Spec.Access.PlayerListSelector; selector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(selector); err != nil {
+			errs = append(errs, field.Invalid(access.Child("playerListSelector"), selector, err.Error()))
+		}
+	}
+
+	mods := spec.Child("mods")
+	switch r.Spec.Mods.Framework {
+	case "", "bepinex", "valheimplus":
+	default:
+		errs = append(errs, field.NotSupported(mods.Child("framework"), r.Spec.Mods.Framework, []string{"bepinex", "valheimplus"}))
+	}
+	if r.Spec.Mods.Enabled {
+		errs = append(errs, validateQuantity(mods.Child("storage", "size"), r.Spec.Mods.Storage.Size)...)
+		if r.Spec.Mods.Cache != nil {
+			errs = append(errs, validateQuantity(mods.Child("cache", "size"), r.Spec.Mods.Cache.Size)...)
+		}
+	}
+	if r.Spec.Mods.ValheimPlus != nil {
+		if err := valheimplus.Validate(r.Spec.Mods.ValheimPlus.Config); err != nil {
+			errs = append(errs, field.Invalid(mods.Child("valheimPlus", "config"), r.Spec.Mods.ValheimPlus.Config, err.Error()))
+		}
+	}
+	return errs
+}
+
+// validateWorldModifiers checks the modifiers against the values the game
+// accepts. The CRD schema does the same, but only for requests made in v1alpha2.
+func (r *Valheim) validateWorldModifiers(path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	modifiers := r.Spec.WorldModifiers
+	if modifiers.Preset != "" && !contains(WorldPresets, modifiers.Preset) {
+		errs = append(errs, field.NotSupported(path.Child("preset"), modifiers.Preset, toStrings(WorldPresets)))
+	}
+	fields := map[string]string{
+		"combat":       "combat",
+		"deathpenalty": "deathPenalty",
+		"raids":        "raids",
+		"resources":    "resources",
+		"portals":      "portals",
+	}
+	values := modifiers.Modifiers()
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := values[name]
+		if !contains(WorldModifierValues[name], value) {
+			errs = append(errs, field.NotSupported(path.Child(fields[name]), value, WorldModifierValues[name]))
+		}
+	}
+	for i, key := range modifiers.Keys {
+		if !contains(WorldKeys, key) {
+			errs = append(errs, field.NotSupported(path.Child("keys").Index(i), key, toStrings(WorldKeys)))
+		}
+	}
+	return errs
+}
+
+// validatePasswordNamespace only lets a server read its password from a
+// Secret of another namespace that allows it, since the key is copied into
+// the namespace of the server. Secrets that are missing are rejected the same
+// as those that do not allow it, so the webhook cannot be used to find out
+// which Secrets exist.
+func (r *Valheim) validatePasswordNamespace(path *field.Path) field.ErrorList {
+	password := r.Spec.Server.Password
+	if password == nil || password.Namespace == "" || password.Namespace == r.Namespace {
+		return nil
+	}
+	forbidden := field.ErrorList{field.Forbidden(path, fmt.Sprintf("secret %s/%s must allow namespace %s in annotation %s", password.Namespace, password.Name, r.Namespace, AnnotationPasswordNamespaces))}
+	if passwordSecretReader == nil {
+		return forbidden
+	}
+	secret := &v1.Secret{}
+	if err := passwordSecretReader.Get(context.Background(), types.NamespacedName{Namespace: password.Namespace, Name: password.Name}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return forbidden
+		}
+		return field.ErrorList{field.InternalError(path, err)}
+	}
+	if !PasswordSecretAllowed(secret, r.Namespace) {
+		return forbidden
+	}
+	return nil
+}
+
+// validatePasswordRotation checks the rotation schedule, and that the password
+// is one the operator generated. Secrets supplied by the user belong to them,
+// and are rotated by whatever manages them.
+func (r *Valheim) validatePasswordRotation(path *field.Path) field.ErrorList {
+	rotation := r.Spec.Server.PasswordRotation
+	if rotation == nil {
+		return nil
+	}
+	if rotation.Schedule == "" {
+		return field.ErrorList{field.Required(path.Child("schedule"), "a schedule is needed to rotate the password")}
+	}
+	errs := validateSchedule(path.Child("schedule"), rotation.Schedule)
+	if r.Spec.Server.Password != nil {
+		errs = append(errs, field.Forbidden(path, "only generated passwords are rotated, unset spec.server.password or rotate its secret yourself"))
+	}
+	return errs
+}
+
+func contains[T comparable](values []T, value T) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func toStrings[T ~string](values []T) []string {
+	strs := make([]string, 0, len(values))
+	for _, v := range values {
+		strs = append(strs, string(v))
+	}
+	return strs
+}
+
+// validateSpecUpdate forbids changes the volumes of the server cannot follow
+func (r *Valheim) validateSpecUpdate(old *Valheim) field.ErrorList {
+	spec := field.NewPath("spec")
+	errs := field.ErrorList{}
+	errs = append(errs, validateStorageUpdate(spec.Child("storage"), r.Spec.Storage, old.Spec.Storage)...)
+	errs = append(errs, validateStorageUpdate(spec.Child("backups", "storage"), r.Spec.Backups.Storage, old.Spec.Backups.Storage)...)
+	if r.Spec.Mods.Enabled && old.Spec.Mods.Enabled {
+		errs = append(errs, validateStorageUpdate(spec.Child("mods", "storage"), r.Spec.Mods.Storage, old.Spec.Mods.Storage)...)
+	}
+	return errs
+}
+
+// validateStorageUpdate forbids shrinking a volume or moving it to another class
+func validateStorageUpdate(path *field.Path, storage ValheimStorageSpec, old ValheimStorageSpec) field.ErrorList {
+	errs := field.ErrorList{}
+	if storage.Class != old.Class {
+		errs = append(errs, field.Forbidden(path.Child("class"), "the storage class of a volume cannot be changed"))
+	}
+	size, err := resource.ParseQuantity(storage.Size)
+	if err != nil {
+		return errs
+	}
+	// Sizes that never parsed have no volume to protect
+	if oldSize, err := resource.ParseQuantity(old.Size); err == nil && size.Cmp(oldSize) < 0 {
+		errs = append(errs, field.Forbidden(path.Child("size"), "volumes cannot shrink below "+old.Size))
+	}
+	return errs
+}
+
+func validateQuantity(path *field.Path, value string) field.ErrorList {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, value, err.Error())}
+	}
+	if quantity.Sign() <= 0 {
+		return field.ErrorList{field.Invalid(path, value, "must be greater than zero")}
+	}
+	return nil
+}
+
+func validateSchedule(path *field.Path, schedule string) field.ErrorList {
+	if schedule == "" {
+		return nil
+	}
+	if _, err := cron.ParseStandard(schedule); err != nil {
+		return field.ErrorList{field.Invalid(path, schedule, err.Error())}
+	}
+	return nil
+}
+
+func validateSteamIDs(path *field.Path, ids []string) field.ErrorList {
+	errs := field.ErrorList{}
+	for i, id := range ids {
+		if !steamIDPattern.MatchString(id) {
+			errs = append(errs, field.Invalid(path.Index(i), id, "must be a numeric SteamID64"))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Valheim webhook", func() {
+	var valheim *Valheim
+
+	BeforeEach(func() {
+		valheim = &Valheim{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: ValheimSpec{
+				Storage: ValheimStorageSpec{Size: "2Gi", Class: "standard"},
+				Backups: ValheimBackupSpec{
+					Schedule: "0 * * * *",
+					Storage:  ValheimStorageSpec{Size: "6Gi"},
+				},
+				Access: ValheimAccessSpec{Admins: []string{"76561198984891671"}},
+			},
+		}
+	})
+
+	causes := func(err error) []string {
+		status, ok := err.(apierrors.APIStatus)
+		Expect(ok).To(BeTrue())
+		fields := []string{}
+		for _, cause := range status.Status().Details.Causes {
+			fields = append(fields, cause.Field)
+		}
+		return fields
+	}
+
+	It("accepts a valid spec", func() {
+		Expect(valheim.ValidateCreate()).To(Succeed())
+	})
+
+	It("rejects invalid fields", func() {
+		valheim.Spec.Storage.Size = "lots"
+		valheim.Spec.Service.Type = "ExternalName"
+		valheim.Spec.Mods.Framework = "melonloader"
+		valheim.Spec.Backups.Schedule = "every hour"
+		valheim.Spec.Access.Banned = []string{"griefer"}
+
+		err := valheim.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(causes(err)).To(ConsistOf(
+			"spec.storage.size",
+			"spec.service.type",
+			"spec.mods.framework",
+			"spec.backups.schedule",
+			"spec.access.banned[0]",
+		))
+	})
+
+	It("checks mod storage only when mods are enabled", func() {
+		Expect(valheim.ValidateCreate()).To(Succeed())
+
+		valheim.Spec.Mods.Enabled = true
+		Expect(causes(valheim.ValidateCreate())).To(ConsistOf("spec.mods.storage.size"))
+	})
+
+	It("rejects unknown ValheimPlus settings", func() {
+		valheim.Spec.Mods.ValheimPlus = &ValheimPlusSpec{
+			Config: map[string]map[string]string{"Server": {"maxPlayer": "20"}},
+		}
+		Expect(causes(valheim.ValidateCreate())).To(ConsistOf("spec.mods.valheimPlus.config"))
+	})
+
+	It("allows storage to grow", func() {
+		old := valheim.DeepCopy()
+		valheim.Spec.Storage.Size = "4Gi"
+		Expect(valheim.ValidateUpdate(old)).To(Succeed())
+	})
+
+	It("forbids shrinking storage or changing its class", func() {
+		old := valheim.DeepCopy()
+		valheim.Spec.Storage.Size = "1Gi"
+		valheim.Spec.Storage.Class = "fast"
+		Expect(causes(valheim.ValidateUpdate(old))).To(ConsistOf("spec.storage.size", "spec.storage.class"))
+	})
+
+	It("persists defaults", func() {
+		valheim.Spec.Backups.MaxCount = nil
+		valheim.Default()
+
+		Expect(valheim.Spec.Image.Repository).To(Equal(DefaultImageRepository))
+		Expect(valheim.Spec.Image.Version).To(Equal(DefaultImageVersion))
+		Expect(valheim.Spec.Server.Name).To(Equal(DefaultServerName))
+		Expect(valheim.Spec.Server.WorldNameOrSeed).To(Equal(DefaultWorldName))
+		Expect(valheim.Spec.Service.Type).To(Equal(DefaultServiceType))
+		Expect(valheim.Spec.Backups.MaxCount).To(HaveValue(BeEquivalentTo(DefaultBackupsMaxCount)))
+		Expect(valheim.Spec.Backups.Directory).To(Equal(DefaultBackupsDirectory))
+		Expect(valheim.ValidateCreate()).To(Succeed())
+	})
+
+	It("keeps values that are set", func() {
+		valheim.Spec.Server.Name = "Vikings Only"
+		valheim.Spec.Backups.MaxCount = new(int32)
+		valheim.Default()
+
+		Expect(valheim.Spec.Server.Name).To(Equal("Vikings Only"))
+		Expect(valheim.Spec.Backups.MaxCount).To(HaveValue(BeZero()))
+	})
+
+	It("rejects world modifiers the game does not know", func() {
+		valheim.Spec.WorldModifiers = ValheimWorldModifiersSpec{
+			Preset:       "nightmare",
+			Combat:       "hard",
+			DeathPenalty: "brutal",
+			Keys:         []ValheimWorldKey{WorldKeyNoMap, "nofood"},
+		}
+		Expect(causes(valheim.ValidateCreate())).To(ConsistOf(
+			"spec.worldModifiers.preset",
+			"spec.worldModifiers.deathPenalty",
+			"spec.worldModifiers.keys[1]",
+		))
+	})
+
+	It("rejects an invalid player list selector", func() {
+		valheim.Spec.Access.PlayerListSelector = &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "community", Operator: "Near"}},
+		}
+		Expect(causes(valheim.ValidateCreate())).To(ConsistOf("spec.access.playerListSelector"))
+	})
+
+	It("rejects password rotation it cannot perform", func() {
+		valheim.Spec.Server.PasswordRotation = &ValheimPasswordRotationSpec{Schedule: "0 4 * * 1"}
+		Expect(valheim.ValidateCreate()).To(Succeed())
+
+		valheim.Spec.Server.Password = &ValheimSecretKeySelector{Name: "server-password", Key: "password"}
+		Expect(causes(valheim.ValidateCreate())).To(ConsistOf("spec.server.passwordRotation"))
+
+		valheim.Spec.Server.PasswordRotation.Schedule = "weekly"
+		valheim.Spec.Server.Password = &ValheimSecretKeySelector{Name: "shared", Namespace: "secrets"}
+		Expect(causes(valheim.ValidateCreate())).To(ConsistOf(
+			"spec.server.password.namespace",
+			"spec.server.passwordRotation.schedule",
+			"spec.server.passwordRotation",
+		))
+	})
+
+	It("only reads passwords from other namespaces that allow it", func() {
+		passwordSecretReader = fake.NewClientBuilder().WithObjects(
+			&v1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Name:        "shared",
+				Namespace:   "secrets",
+				Annotations: map[string]string{AnnotationPasswordNamespaces: "games, default"},
+			}},
+			&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "private", Namespace: "secrets"}},
+		).Build()
+		DeferCleanup(func() { passwordSecretReader = nil })
+
+		valheim.Spec.Server.Password = &ValheimSecretKeySelector{Name: "shared", Namespace: "secrets"}
+		Expect(valheim.ValidateCreate()).To(Succeed())
+
+		valheim.Spec.Server.Password.Name = "private"
+		Expect(causes(valheim.ValidateCreate())).To(ConsistOf("spec.server.password.namespace"))
+
+		valheim.Spec.Server.Password.Name = "missing"
+		Expect(causes(valheim.ValidateCreate())).To(ConsistOf("spec.server.password.namespace"))
+	})
+
+	It("defaults the key of the password secret", func() {
+		valheim.Spec.Server.Password = &ValheimSecretKeySelector{Name: "test"}
+		valheim.Default()
+		Expect(valheim.Spec.Server.Password.Key).To(Equal(DefaultPasswordKey))
+	})
+})
@@ -0,0 +1,86 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ValheimPlayerListSpec defines the desired state of ValheimPlayerList
+type ValheimPlayerListSpec struct {
+	Players []ValheimPlayer `json:"players,omitempty"`
+}
+
+// ValheimPlayer is a player and the access lists they are on
+type ValheimPlayer struct {
+	// Name is who the player is, for the people reading the list
+	Name string `json:"name,omitempty"`
+	// +kubebuilder:validation:Pattern=`^[0-9]+$`
+	SteamID string `json:"steamID"`
+	// +kubebuilder:validation:MinItems=1
+	Roles []ValheimPlayerRole `json:"roles"`
+}
+
+// ValheimPlayerRole is an access list of the server a player is put on
+// +kubebuilder:validation:Enum=admin;banned;permitted
+type ValheimPlayerRole string
+
+const (
+	PlayerRoleAdmin     ValheimPlayerRole = "admin"
+	PlayerRoleBanned    ValheimPlayerRole = "banned"
+	PlayerRolePermitted ValheimPlayerRole = "permitted"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ValheimPlayerList is the Schema for the valheimplayerlists API. Valheims
+// select player lists in their namespace by label with
+// spec.access.playerListSelector, so one list can be shared by many servers.
+type ValheimPlayerList struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ValheimPlayerListSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ValheimPlayerListList contains a list of ValheimPlayerList
+type ValheimPlayerListList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ValheimPlayerList `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ValheimPlayerList{}, &ValheimPlayerListList{})
+}
+
+// SteamIDs are the SteamIDs of the players with role
+func (l *ValheimPlayerList) SteamIDs(role ValheimPlayerRole) []string {
+	ids := []string{}
+	for _, player := range l.Spec.Players {
+		for _, r := range player.Roles {
+			if r == role {
+				ids = append(ids, player.SteamID)
+				break
+			}
+		}
+	}
+	return ids
+}
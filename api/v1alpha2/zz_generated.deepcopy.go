@@ -0,0 +1,769 @@
+//go:build !ignore_autogenerated
+// +build !ignore_autogenerated
+
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1alpha2
+
+import (
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GameServerResourceSpec) DeepCopyInto(out *GameServerResourceSpec) {
+	*out = *in
+	if in.Limits != nil {
+		in, out := &in.Limits, &out.Limits
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.Requests != nil {
+		in, out := &in.Requests, &out.Requests
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GameServerResourceSpec.
+func (in *GameServerResourceSpec) DeepCopy() *GameServerResourceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GameServerResourceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Valheim) DeepCopyInto(out *Valheim) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Valheim.
+func (in *Valheim) DeepCopy() *Valheim {
+	if in == nil {
+		return nil
+	}
+	out := new(Valheim)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Valheim) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimAccessSpec) DeepCopyInto(out *ValheimAccessSpec) {
+	*out = *in
+	if in.Admins != nil {
+		in, out := &in.Admins, &out.Admins
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Banned != nil {
+		in, out := &in.Banned, &out.Banned
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Permitted != nil {
+		in, out := &in.Permitted, &out.Permitted
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PlayerListSelector != nil {
+		in, out := &in.PlayerListSelector, &out.PlayerListSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimAccessSpec.
+func (in *ValheimAccessSpec) DeepCopy() *ValheimAccessSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimAccessSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimBackupSpec) DeepCopyInto(out *ValheimBackupSpec) {
+	*out = *in
+	if in.MaxCount != nil {
+		in, out := &in.MaxCount, &out.MaxCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxAgeDays != nil {
+		in, out := &in.MaxAgeDays, &out.MaxAgeDays
+		*out = new(int32)
+		**out = **in
+	}
+	if in.IfIdle != nil {
+		in, out := &in.IfIdle, &out.IfIdle
+		*out = new(bool)
+		**out = **in
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+	out.Storage = in.Storage
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimBackupSpec.
+func (in *ValheimBackupSpec) DeepCopy() *ValheimBackupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimBackupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimBackupsStatus) DeepCopyInto(out *ValheimBackupsStatus) {
+	*out = *in
+	if in.LastUploadTime != nil {
+		in, out := &in.LastUploadTime, &out.LastUploadTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastIndexTime != nil {
+		in, out := &in.LastIndexTime, &out.LastIndexTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSnapshotTime != nil {
+		in, out := &in.LastSnapshotTime, &out.LastSnapshotTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimBackupsStatus.
+func (in *ValheimBackupsStatus) DeepCopy() *ValheimBackupsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimBackupsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimHooksSpec) DeepCopyInto(out *ValheimHooksSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimHooksSpec.
+func (in *ValheimHooksSpec) DeepCopy() *ValheimHooksSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimHooksSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimImageSpec) DeepCopyInto(out *ValheimImageSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimImageSpec.
+func (in *ValheimImageSpec) DeepCopy() *ValheimImageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimImageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimList) DeepCopyInto(out *ValheimList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Valheim, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimList.
+func (in *ValheimList) DeepCopy() *ValheimList {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ValheimList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimLockedMod) DeepCopyInto(out *ValheimLockedMod) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimLockedMod.
+func (in *ValheimLockedMod) DeepCopy() *ValheimLockedMod {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimLockedMod)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimModOCISource) DeepCopyInto(out *ValheimModOCISource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimModOCISource.
+func (in *ValheimModOCISource) DeepCopy() *ValheimModOCISource {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimModOCISource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimModSource) DeepCopyInto(out *ValheimModSource) {
+	*out = *in
+	if in.URL != nil {
+		in, out := &in.URL, &out.URL
+		*out = new(ValheimModURLSource)
+		**out = **in
+	}
+	if in.OCI != nil {
+		in, out := &in.OCI, &out.OCI
+		*out = new(ValheimModOCISource)
+		**out = **in
+	}
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Volume != nil {
+		in, out := &in.Volume, &out.Volume
+		*out = new(ValheimModVolumeSource)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimModSource.
+func (in *ValheimModSource) DeepCopy() *ValheimModSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimModSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimModSpec) DeepCopyInto(out *ValheimModSpec) {
+	*out = *in
+	if in.Settings != nil {
+		in, out := &in.Settings, &out.Settings
+		*out = make(map[string]map[string]string, len(*in))
+		for key, val := range *in {
+			var outVal map[string]string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.Source != nil {
+		in, out := &in.Source, &out.Source
+		*out = new(ValheimModSource)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimModSpec.
+func (in *ValheimModSpec) DeepCopy() *ValheimModSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimModSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimModURLSource) DeepCopyInto(out *ValheimModURLSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimModURLSource.
+func (in *ValheimModURLSource) DeepCopy() *ValheimModURLSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimModURLSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimModVolumeSource) DeepCopyInto(out *ValheimModVolumeSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimModVolumeSource.
+func (in *ValheimModVolumeSource) DeepCopy() *ValheimModVolumeSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimModVolumeSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimModsSpec) DeepCopyInto(out *ValheimModsSpec) {
+	*out = *in
+	out.Storage = in.Storage
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(ValheimStorageSpec)
+		**out = **in
+	}
+	if in.Packages != nil {
+		in, out := &in.Packages, &out.Packages
+		*out = make(map[string]ValheimModSpec, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.ValheimPlus != nil {
+		in, out := &in.ValheimPlus, &out.ValheimPlus
+		*out = new(ValheimPlusSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimModsSpec.
+func (in *ValheimModsSpec) DeepCopy() *ValheimModsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimModsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimModsStatus) DeepCopyInto(out *ValheimModsStatus) {
+	*out = *in
+	if in.Lock != nil {
+		in, out := &in.Lock, &out.Lock
+		*out = make([]ValheimLockedMod, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastFailureTime != nil {
+		in, out := &in.LastFailureTime, &out.LastFailureTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimModsStatus.
+func (in *ValheimModsStatus) DeepCopy() *ValheimModsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimModsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimPasswordPolicy) DeepCopyInto(out *ValheimPasswordPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimPasswordPolicy.
+func (in *ValheimPasswordPolicy) DeepCopy() *ValheimPasswordPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimPasswordPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimPasswordRotationSpec) DeepCopyInto(out *ValheimPasswordRotationSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimPasswordRotationSpec.
+func (in *ValheimPasswordRotationSpec) DeepCopy() *ValheimPasswordRotationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimPasswordRotationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimPlayer) DeepCopyInto(out *ValheimPlayer) {
+	*out = *in
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]ValheimPlayerRole, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimPlayer.
+func (in *ValheimPlayer) DeepCopy() *ValheimPlayer {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimPlayer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimPlayerList) DeepCopyInto(out *ValheimPlayerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimPlayerList.
+func (in *ValheimPlayerList) DeepCopy() *ValheimPlayerList {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimPlayerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ValheimPlayerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimPlayerListList) DeepCopyInto(out *ValheimPlayerListList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ValheimPlayerList, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimPlayerListList.
+func (in *ValheimPlayerListList) DeepCopy() *ValheimPlayerListList {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimPlayerListList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ValheimPlayerListList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimPlayerListSpec) DeepCopyInto(out *ValheimPlayerListSpec) {
+	*out = *in
+	if in.Players != nil {
+		in, out := &in.Players, &out.Players
+		*out = make([]ValheimPlayer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimPlayerListSpec.
+func (in *ValheimPlayerListSpec) DeepCopy() *ValheimPlayerListSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimPlayerListSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimPlusSpec) DeepCopyInto(out *ValheimPlusSpec) {
+	*out = *in
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = make(map[string]map[string]string, len(*in))
+		for key, val := range *in {
+			var outVal map[string]string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimPlusSpec.
+func (in *ValheimPlusSpec) DeepCopy() *ValheimPlusSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimPlusSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimSecretKeySelector) DeepCopyInto(out *ValheimSecretKeySelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimSecretKeySelector.
+func (in *ValheimSecretKeySelector) DeepCopy() *ValheimSecretKeySelector {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimSecretKeySelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimServerSpec) DeepCopyInto(out *ValheimServerSpec) {
+	*out = *in
+	if in.Password != nil {
+		in, out := &in.Password, &out.Password
+		*out = new(ValheimSecretKeySelector)
+		**out = **in
+	}
+	if in.PasswordPolicy != nil {
+		in, out := &in.PasswordPolicy, &out.PasswordPolicy
+		*out = new(ValheimPasswordPolicy)
+		**out = **in
+	}
+	if in.PasswordRotation != nil {
+		in, out := &in.PasswordRotation, &out.PasswordRotation
+		*out = new(ValheimPasswordRotationSpec)
+		**out = **in
+	}
+	if in.AdditionalArgs != nil {
+		in, out := &in.AdditionalArgs, &out.AdditionalArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AdditionalEnv != nil {
+		in, out := &in.AdditionalEnv, &out.AdditionalEnv
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimServerSpec.
+func (in *ValheimServerSpec) DeepCopy() *ValheimServerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimServerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimServiceSpec) DeepCopyInto(out *ValheimServiceSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimServiceSpec.
+func (in *ValheimServiceSpec) DeepCopy() *ValheimServiceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimServiceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimSpec) DeepCopyInto(out *ValheimSpec) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+	out.Image = in.Image
+	in.Server.DeepCopyInto(&out.Server)
+	out.Service = in.Service
+	in.WorldModifiers.DeepCopyInto(&out.WorldModifiers)
+	in.Access.DeepCopyInto(&out.Access)
+	in.Backups.DeepCopyInto(&out.Backups)
+	out.Storage = in.Storage
+	out.Hooks = in.Hooks
+	in.Mods.DeepCopyInto(&out.Mods)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimSpec.
+func (in *ValheimSpec) DeepCopy() *ValheimSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimStatus) DeepCopyInto(out *ValheimStatus) {
+	*out = *in
+	if in.LastSeen != nil {
+		in, out := &in.LastSeen, &out.LastSeen
+		*out = (*in).DeepCopy()
+	}
+	if in.LastQueried != nil {
+		in, out := &in.LastQueried, &out.LastQueried
+		*out = (*in).DeepCopy()
+	}
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(ValheimSecretKeySelector)
+		**out = **in
+	}
+	in.Backups.DeepCopyInto(&out.Backups)
+	in.Mods.DeepCopyInto(&out.Mods)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimStatus.
+func (in *ValheimStatus) DeepCopy() *ValheimStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimStorageSpec) DeepCopyInto(out *ValheimStorageSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimStorageSpec.
+func (in *ValheimStorageSpec) DeepCopy() *ValheimStorageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimStorageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValheimWorldModifiersSpec) DeepCopyInto(out *ValheimWorldModifiersSpec) {
+	*out = *in
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]ValheimWorldKey, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValheimWorldModifiersSpec.
+func (in *ValheimWorldModifiersSpec) DeepCopy() *ValheimWorldModifiersSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ValheimWorldModifiersSpec)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -32,7 +32,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	serverv1alpha1 "github.com/robwittman/gamely/api/v1alpha1"
+	serverv1alpha2 "github.com/robwittman/gamely/api/v1alpha2"
+	"github.com/robwittman/gamely/internal/a2s"
 	"github.com/robwittman/gamely/internal/controller"
+	"github.com/robwittman/gamely/internal/thunderstore"
+	"github.com/robwittman/gamely/internal/util"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -45,6 +49,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(serverv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(serverv1alpha2.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -52,11 +57,17 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var thunderstoreURL string
+	var queryServers bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&thunderstoreURL, "thunderstore-url", thunderstore.DefaultBaseURL, "The Thunderstore mod dependencies are resolved against.")
+	flag.BoolVar(&queryServers, "query-servers", true,
+		"Query running servers for their players over A2S. "+
+			"Disable when the manager cannot reach service IPs, such as when it runs outside the cluster.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -89,13 +100,53 @@ func main() {
 		os.Exit(1)
 	}
 
+	executor, err := util.NewPodExecutor(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create pod executor")
+		os.Exit(1)
+	}
+
+	var queryClient *a2s.Client
+	if queryServers {
+		queryClient = a2s.NewClient()
+	}
+
 	if err = (&controller.ValheimReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		Recorder:     mgr.GetEventRecorderFor("valheim-controller"),
+		Executor:     executor,
+		Thunderstore: thunderstore.NewClient(thunderstoreURL),
+		A2S:          queryClient,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Valheim")
 		os.Exit(1)
 	}
+	if err = (&controller.ValheimBackupReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("valheimbackup-controller"),
+		Executor: executor,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ValheimBackup")
+		os.Exit(1)
+	}
+	if err = (&controller.ValheimRestoreReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("valheimrestore-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ValheimRestore")
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&serverv1alpha2.Valheim{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Valheim")
+			os.Exit(1)
+		}
+	} else {
+		setupLog.Info("webhooks are disabled, Valheims are only defaulted and validated by their schema and v1alpha1 Valheims cannot be converted")
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -0,0 +1,14 @@
+package a2s
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestA2S(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "A2S Suite")
+}
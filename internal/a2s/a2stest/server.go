@@ -0,0 +1,108 @@
+// Package a2stest provides a fake game server answering A2S queries, for
+// testing code that queries servers with the a2s package.
+package a2stest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+)
+
+const (
+	singlePacket    int32 = -1
+	infoRequest     byte  = 'T'
+	infoResponse    byte  = 'I'
+	playerRequest   byte  = 'U'
+	challengeHeader byte  = 'A'
+)
+
+// Server is a fake game server answering A2S queries on a local UDP port.
+// Requests are answered with Info and Players, after a challenge when
+// Challenge is set. Requests are ignored while the response is nil.
+type Server struct {
+	Challenge []byte
+	Info      []byte
+	Players   []byte
+
+	conn net.PacketConn
+}
+
+// NewServer starts a Server on a random local port
+func NewServer() (*Server, error) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{conn: conn}
+	go s.serve()
+	return s, nil
+}
+
+// Addr is the host:port the server answers queries on
+func (s *Server) Addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+// Port is the port the server answers queries on
+func (s *Server) Port() int {
+	return s.conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	return s.conn.Close()
+}
+
+func (s *Server) serve() {
+	buf := make([]byte, 1400)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if n < 5 {
+			continue
+		}
+		request := buf[4:n]
+		var response []byte
+		switch {
+		case s.Challenge != nil && !bytes.HasSuffix(request, s.Challenge):
+			response = append([]byte{challengeHeader}, s.Challenge...)
+		case request[0] == infoRequest:
+			response = s.Info
+		case request[0] == playerRequest:
+			response = s.Players
+		}
+		if response == nil {
+			continue
+		}
+		_, _ = s.conn.WriteTo(append(Packet(singlePacket), response...), addr)
+	}
+}
+
+// InfoResponse is the A2S_INFO response of a Valheim server without extra data
+func InfoResponse(name string, players int, maxPlayers int, version string) []byte {
+	return Packet(infoResponse, byte(17), name, "Dedicated", "valheim", "Valheim",
+		uint16(0), byte(players), byte(maxPlayers), byte(0), byte('d'), byte('l'), byte(0), byte(0), version)
+}
+
+// Packet builds a response payload from bytes, strings and numbers, written
+// the way A2S encodes them
+func Packet(fields ...interface{}) []byte {
+	b := &bytes.Buffer{}
+	for _, field := range fields {
+		switch v := field.(type) {
+		case byte:
+			b.WriteByte(v)
+		case string:
+			b.WriteString(v)
+			b.WriteByte(0)
+		case float32:
+			_ = binary.Write(b, binary.LittleEndian, math.Float32bits(v))
+		default:
+			_ = binary.Write(b, binary.LittleEndian, v)
+		}
+	}
+	return b.Bytes()
+}
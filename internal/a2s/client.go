@@ -0,0 +1,288 @@
+package a2s
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+const (
+	// singlePacket and splitPacket head every response, telling whether it
+	// fits in a single packet
+	singlePacket int32 = -1
+	splitPacket  int32 = -2
+
+	infoRequest     byte = 'T'
+	infoResponse    byte = 'I'
+	playerRequest   byte = 'U'
+	playerResponse  byte = 'D'
+	challengeHeader byte = 'A'
+
+	// maxPacketSize is the largest packet servers send
+	maxPacketSize = 1400
+	// maxChallenges bounds how often a server may answer with a challenge
+	maxChallenges = 3
+)
+
+// ErrSplitResponse is returned for responses split across several packets,
+// which Valheim servers never send
+var ErrSplitResponse = errors.New("split responses are not supported")
+
+// Client queries game servers with the Steam A2S query protocol
+type Client struct {
+	// Timeout bounds each query when the context has no earlier deadline
+	Timeout time.Duration
+}
+
+// NewClient returns a Client
+func NewClient() *Client {
+	return &Client{Timeout: time.Second * 5}
+}
+
+// Info is what a server reports about itself in response to A2S_INFO
+type Info struct {
+	Protocol    byte
+	Name        string
+	Map         string
+	Folder      string
+	Game        string
+	AppID       uint16
+	Players     int
+	MaxPlayers  int
+	Bots        int
+	ServerType  byte
+	Environment byte
+	Visibility  bool
+	VAC         bool
+	Version     string
+
+	// The extra data fields, set when the server sends them
+	Port     uint16
+	SteamID  uint64
+	Keywords string
+	GameID   uint64
+}
+
+// Player is a player connected to a server, as reported in response to A2S_PLAYER
+type Player struct {
+	Name     string
+	Score    int32
+	Duration time.Duration
+}
+
+// Info asks the server at address, as in host:port of its query port, about itself
+func (c *Client) Info(ctx context.Context, address string) (*Info, error) {
+	request := append([]byte{infoRequest}, []byte("Source Engine Query\x00")...)
+	payload, err := c.query(ctx, address, request, func(challenge []byte) []byte {
+		return append(append([]byte{}, request...), challenge...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reader{buf: payload}
+	if header := r.byte(); header != infoResponse {
+		return nil, fmt.Errorf("unexpected response type %q to A2S_INFO", header)
+	}
+	info := &Info{
+		Protocol:    r.byte(),
+		Name:        r.string(),
+		Map:         r.string(),
+		Folder:      r.string(),
+		Game:        r.string(),
+		AppID:       r.uint16(),
+		Players:     int(r.byte()),
+		MaxPlayers:  int(r.byte()),
+		Bots:        int(r.byte()),
+		ServerType:  r.byte(),
+		Environment: r.byte(),
+		Visibility:  r.byte() == 1,
+		VAC:         r.byte() == 1,
+		Version:     r.string(),
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.remaining() == 0 {
+		return info, nil
+	}
+
+	flags := r.byte()
+	if flags&0x80 != 0 {
+		info.Port = r.uint16()
+	}
+	if flags&0x10 != 0 {
+		info.SteamID = r.uint64()
+	}
+	if flags&0x40 != 0 {
+		// SourceTV port and name
+		r.uint16()
+		r.string()
+	}
+	if flags&0x20 != 0 {
+		info.Keywords = r.string()
+	}
+	if flags&0x01 != 0 {
+		info.GameID = r.uint64()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return info, nil
+}
+
+// Players asks the server at address, as in host:port of its query port, for
+// the players connected to it
+func (c *Client) Players(ctx context.Context, address string) ([]Player, error) {
+	request := []byte{playerRequest, 0xFF, 0xFF, 0xFF, 0xFF}
+	payload, err := c.query(ctx, address, request, func(challenge []byte) []byte {
+		return append([]byte{playerRequest}, challenge...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reader{buf: payload}
+	if header := r.byte(); header != playerResponse {
+		return nil, fmt.Errorf("unexpected response type %q to A2S_PLAYER", header)
+	}
+	count := int(r.byte())
+	players := make([]Player, 0, count)
+	for i := 0; i < count && r.err == nil; i++ {
+		// The index of the player is not meaningful
+		r.byte()
+		players = append(players, Player{
+			Name:     r.string(),
+			Score:    r.int32(),
+			Duration: time.Duration(float64(r.float32()) * float64(time.Second)),
+		})
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return players, nil
+}
+
+// query sends request to the server and returns the payload of its response.
+// Servers may answer with a challenge instead, which is sent back in the
+// request built by withChallenge.
+func (c *Client) query(ctx context.Context, address string, request []byte, withChallenge func(challenge []byte) []byte) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok && c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := make([]byte, maxPacketSize)
+	for attempt := 0; attempt <= maxChallenges; attempt++ {
+		if _, err := conn.Write(append(header(singlePacket), request...)); err != nil {
+			return nil, err
+		}
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		if n < 5 {
+			return nil, fmt.Errorf("response of %d bytes is too short", n)
+		}
+		switch int32(binary.LittleEndian.Uint32(buf[:4])) {
+		case singlePacket:
+		case splitPacket:
+			return nil, ErrSplitResponse
+		default:
+			return nil, fmt.Errorf("response has unknown header %x", buf[:4])
+		}
+
+		payload := buf[4:n]
+		if payload[0] != challengeHeader {
+			return append([]byte{}, payload...), nil
+		}
+		if len(payload) < 5 {
+			return nil, fmt.Errorf("challenge of %d bytes is too short", len(payload)-1)
+		}
+		request = withChallenge(payload[1:5])
+	}
+	return nil, fmt.Errorf("server answered with a challenge %d times", maxChallenges+1)
+}
+
+func header(value int32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, uint32(value))
+	return b
+}
+
+// reader reads the little endian fields of a response. The first read past
+// the end of the response sets err, and later reads return zero values.
+type reader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *reader) remaining() int {
+	return len(r.buf) - r.pos
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if r.remaining() < n {
+		r.err = fmt.Errorf("response ended %d bytes in, reading %d more", r.pos, n)
+		return make([]byte, n)
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) byte() byte {
+	return r.next(1)[0]
+}
+
+func (r *reader) uint16() uint16 {
+	return binary.LittleEndian.Uint16(r.next(2))
+}
+
+func (r *reader) int32() int32 {
+	return int32(binary.LittleEndian.Uint32(r.next(4)))
+}
+
+func (r *reader) uint64() uint64 {
+	return binary.LittleEndian.Uint64(r.next(8))
+}
+
+func (r *reader) float32() float32 {
+	return math.Float32frombits(binary.LittleEndian.Uint32(r.next(4)))
+}
+
+func (r *reader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(r.buf[r.pos:], 0)
+	if end < 0 {
+		r.err = fmt.Errorf("response ended %d bytes in, reading an unterminated string", r.pos)
+		return ""
+	}
+	s := string(r.buf[r.pos : r.pos+end])
+	r.pos += end + 1
+	return s
+}
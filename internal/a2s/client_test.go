@@ -0,0 +1,81 @@
+package a2s
+
+import (
+	"context"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/robwittman/gamely/internal/a2s/a2stest"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		server *a2stest.Server
+		client *Client
+	)
+
+	BeforeEach(func() {
+		var err error
+		server, err = a2stest.NewServer()
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(server.Close)
+		server.Info = a2stest.Packet(infoResponse, byte(17), "Vikings Only", "Dedicated", "valheim", "Valheim",
+			uint16(0), byte(3), byte(10), byte(0), byte('d'), byte('l'), byte(0), byte(0), "1.0.0.0",
+			byte(0xA0), uint16(2456), "0.217.46")
+		server.Players = a2stest.Packet(playerResponse, byte(2),
+			byte(0), "Ragnar", int32(12), float32(90),
+			byte(1), "Lagertha", int32(3), float32(1.5))
+		client = &Client{Timeout: time.Second}
+	})
+
+	It("reads server info", func() {
+		info, err := client.Info(context.Background(), server.Addr())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Name).To(Equal("Vikings Only"))
+		Expect(info.Players).To(Equal(3))
+		Expect(info.MaxPlayers).To(Equal(10))
+		Expect(info.Version).To(Equal("1.0.0.0"))
+		Expect(info.Port).To(BeEquivalentTo(2456))
+		Expect(info.Keywords).To(Equal("0.217.46"))
+	})
+
+	It("reads players", func() {
+		players, err := client.Players(context.Background(), server.Addr())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(players).To(Equal([]Player{
+			{Name: "Ragnar", Score: 12, Duration: time.Second * 90},
+			{Name: "Lagertha", Score: 3, Duration: time.Millisecond * 1500},
+		}))
+	})
+
+	It("answers challenges", func() {
+		server.Challenge = []byte{0x12, 0x34, 0x56, 0x78}
+		info, err := client.Info(context.Background(), server.Addr())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Name).To(Equal("Vikings Only"))
+
+		players, err := client.Players(context.Background(), server.Addr())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(players).To(HaveLen(2))
+	})
+
+	It("rejects truncated responses", func() {
+		server.Info = server.Info[:10]
+		_, err := client.Info(context.Background(), server.Addr())
+		Expect(err).To(MatchError(ContainSubstring("response ended")))
+	})
+
+	It("times out when the server does not answer", func() {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(conn.Close)
+
+		client.Timeout = time.Millisecond * 100
+		_, err = client.Info(context.Background(), conn.LocalAddr().String())
+		Expect(err).To(HaveOccurred())
+		Expect(err.(net.Error).Timeout()).To(BeTrue())
+	})
+})
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	serverv1alpha2 "github.com/robwittman/gamely/api/v1alpha2"
+	"github.com/robwittman/gamely/internal/scope/valheim"
+)
+
+var _ = Describe("Valheim server-side apply", func() {
+	var (
+		ctx     context.Context
+		server  *serverv1alpha2.Valheim
+		service *v1.Service
+	)
+
+	reconcileServer := func() {
+		reconciler := &ValheimReconciler{
+			Client:   k8sClient,
+			Scheme:   k8sClient.Scheme(),
+			Recorder: record.NewFakeRecorder(100),
+		}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(server)})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	getService := func() *v1.Service {
+		service := &v1.Service{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(server), service)).To(Succeed())
+		return service
+	}
+
+	nodePorts := func(service *v1.Service) map[string]int32 {
+		ports := map[string]int32{}
+		for _, port := range service.Spec.Ports {
+			ports[port.Name] = port.NodePort
+		}
+		return ports
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "apply-"}}
+		Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+
+		server = &serverv1alpha2.Valheim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns.Name, Name: "world"},
+			Spec: serverv1alpha2.ValheimSpec{
+				Storage: serverv1alpha2.ValheimStorageSpec{Size: "1Gi"},
+				Service: serverv1alpha2.ValheimServiceSpec{Type: "NodePort"},
+				Backups: serverv1alpha2.ValheimBackupSpec{Storage: serverv1alpha2.ValheimStorageSpec{Size: "1Gi"}},
+				Mods:    serverv1alpha2.ValheimModsSpec{Packages: map[string]serverv1alpha2.ValheimModSpec{}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, server)).To(Succeed())
+		reconcileServer()
+		service = getService()
+	})
+
+	It("applies owned objects under the gamely field manager", func() {
+		Expect(service.ManagedFields).To(ContainElement(And(
+			HaveField("Manager", valheim.FieldManager),
+			HaveField("Operation", metav1.ManagedFieldsOperationApply),
+		)))
+		Expect(service.OwnerReferences).To(ConsistOf(HaveField("UID", server.UID)))
+	})
+
+	It("keeps the allocated node ports and leaves an unchanged object alone", func() {
+		Expect(nodePorts(service)).To(HaveEach(BeNumerically(">", 0)))
+
+		reconcileServer()
+		reconciled := getService()
+		Expect(reconciled.ResourceVersion).To(Equal(service.ResourceVersion))
+		Expect(reconciled.Spec.ClusterIP).To(Equal(service.Spec.ClusterIP))
+		Expect(nodePorts(reconciled)).To(Equal(nodePorts(service)))
+	})
+
+	It("leaves the fields of other managers alone", func() {
+		patch := client.MergeFrom(service.DeepCopy())
+		service.Annotations = map[string]string{"example.com/owner": "someone-else"}
+		Expect(k8sClient.Patch(ctx, service, patch, client.FieldOwner("someone-else"))).To(Succeed())
+
+		reconcileServer()
+		Expect(getService().Annotations).To(HaveKeyWithValue("example.com/owner", "someone-else"))
+	})
+
+	It("repairs the fields it manages", func() {
+		patch := client.MergeFrom(service.DeepCopy())
+		service.Spec.Selector = map[string]string{"app": "something-else"}
+		Expect(k8sClient.Patch(ctx, service, patch, client.FieldOwner("someone-else"))).To(Succeed())
+
+		reconcileServer()
+		Expect(getService().Spec.Selector).NotTo(HaveKeyWithValue("app", "something-else"))
+	})
+})
@@ -18,32 +18,54 @@ package controller
 
 import (
 	"context"
-	"fmt"
+	"github.com/robwittman/gamely/internal/a2s"
 	"github.com/robwittman/gamely/internal/scope/valheim"
+	"github.com/robwittman/gamely/internal/thunderstore"
+	"github.com/robwittman/gamely/internal/util"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
-	"time"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
-	serverv1alpha1 "github.com/robwittman/gamely/api/v1alpha1"
+	serverv1alpha2 "github.com/robwittman/gamely/api/v1alpha2"
 )
 
 // ValheimReconciler reconciles a Valheim object
 type ValheimReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme       *runtime.Scheme
+	Recorder     record.EventRecorder
+	Executor     util.PodExecutor
+	Thunderstore *thunderstore.Client
+	A2S          *a2s.Client
 }
 
 //+kubebuilder:rbac:groups=server.gamely.io,resources=valheims,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=server.gamely.io,resources=valheims/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=server.gamely.io,resources=valheims/finalizers,verbs=update
+//+kubebuilder:rbac:groups=server.gamely.io,resources=valheimplayerlists,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;delete
+//+kubebuilder:rbac:groups=server.gamely.io,resources=valheimbackups,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+//+kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -57,7 +79,7 @@ type ValheimReconciler struct {
 func (r *ValheimReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
-	v := &serverv1alpha1.Valheim{}
+	v := &serverv1alpha2.Valheim{}
 	if err := r.Get(ctx, req.NamespacedName, v); err != nil {
 		if errors.IsNotFound(err) {
 			logger.Error(err, "valheim resource did not exist")
@@ -68,23 +90,14 @@ func (r *ValheimReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		}
 	}
 
-	if v.Spec.Paused {
-		logger.Info("valheim resource is paused")
-		return ctrl.Result{RequeueAfter: time.Minute * 1}, nil
-	}
-
-	if v.Spec.Server.Password == nil {
-		if err := r.generatePassword(ctx, v); err != nil {
-			logger.Error(err, "failed generating server password")
-			return ctrl.Result{}, err
-		}
-		return ctrl.Result{Requeue: true}, nil
-	}
-
 	scope := &valheim.Scope{
-		Logger:  logger,
-		Client:  r.Client,
-		Valheim: v,
+		Logger:       logger,
+		Client:       r.Client,
+		Recorder:     r.Recorder,
+		Valheim:      v,
+		Executor:     r.Executor,
+		Thunderstore: r.Thunderstore,
+		A2S:          r.A2S,
 	}
 
 	return scope.Reconcile(ctx, req)
@@ -93,58 +106,35 @@ func (r *ValheimReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 // SetupWithManager sets up the controller with the Manager.
 func (r *ValheimReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&serverv1alpha1.Valheim{}).
+		For(&serverv1alpha2.Valheim{}).
 		Owns(&v1.ServiceAccount{}).
 		Owns(&v1.PersistentVolumeClaim{}).
 		Owns(&v1.Service{}).
 		Owns(&v1.Secret{}).
+		Owns(&v1.ConfigMap{}).
+		Owns(&appsv1.StatefulSet{}).
+		Owns(&batchv1.Job{}).
+		Owns(&batchv1.CronJob{}).
+		Watches(&source.Kind{Type: &serverv1alpha2.ValheimPlayerList{}}, handler.EnqueueRequestsFromMapFunc(r.valheimsForPlayerList)).
 		Complete(r)
 }
 
-func (r *ValheimReconciler) generatePassword(ctx context.Context, val *serverv1alpha1.Valheim) error {
-	secret := &v1.Secret{}
-
-	if err := r.Get(ctx, types.NamespacedName{
-		Namespace: val.Namespace,
-		Name:      val.Name,
-	}, secret); err != nil {
-		if errors.IsNotFound(err) {
-			password := generatePassword(6)
-			secret := &v1.Secret{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      val.Name,
-					Namespace: val.Namespace,
-				},
-				Data: map[string][]byte{
-					"password": []byte(password),
-				},
-			}
-			if err := r.Create(ctx, secret); err != nil {
-				return err
-			}
-		} else {
-			return err
-		}
-	}
-
-	if err := controllerutil.SetOwnerReference(val, secret, r.Scheme); err != nil {
-		fmt.Println(err)
-		fmt.Println("failed setting owner reference on secret")
+// valheimsForPlayerList finds the servers to sync the access lists of when a
+// player list changes. A list can stop matching the selector of a server by
+// a change of its labels, so every server selecting player lists in the
+// namespace is synced.
+func (r *ValheimReconciler) valheimsForPlayerList(obj client.Object) []reconcile.Request {
+	valheims := &serverv1alpha2.ValheimList{}
+	if err := r.List(context.Background(), valheims, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.Log.Error(err, "failed listing servers for player list", "name", obj.GetName())
+		return nil
 	}
-	val.Spec.Server.Password = &v1.SecretReference{
-		Name:      val.Name,
-		Namespace: val.Namespace,
-	}
-
-	return r.Update(ctx, val)
-}
-
-func generatePassword(length int) string {
-	chars := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = chars[rand.Intn(len(chars))]
+	requests := []reconcile.Request{}
+	for _, valheim := range valheims.Items {
+		if valheim.Spec.Access.PlayerListSelector == nil {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&valheim)})
 	}
-	return string(b)
+	return requests
 }
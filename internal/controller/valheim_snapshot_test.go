@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	serverv1alpha1 "github.com/robwittman/gamely/api/v1alpha1"
+	serverv1alpha2 "github.com/robwittman/gamely/api/v1alpha2"
+	"github.com/robwittman/gamely/internal/scope/valheim"
+)
+
+var _ = Describe("Valheim volume snapshots", func() {
+	var (
+		ctx       context.Context
+		namespace string
+		server    *serverv1alpha2.Valheim
+	)
+
+	snapshotLabels := map[string]string{"gamely.io": "valheim-snapshot", "server": "world"}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "snapshots-"}}
+		Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+		namespace = ns.Name
+
+		server = &serverv1alpha2.Valheim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "world"},
+			Spec: serverv1alpha2.ValheimSpec{
+				Storage: serverv1alpha2.ValheimStorageSpec{Size: "1Gi"},
+				Backups: serverv1alpha2.ValheimBackupSpec{
+					Mode:     serverv1alpha2.BackupModeVolumeSnapshot,
+					Schedule: "0 4 * * *",
+					Storage:  serverv1alpha2.ValheimStorageSpec{Size: "1Gi"},
+				},
+				Mods: serverv1alpha2.ValheimModsSpec{Packages: map[string]serverv1alpha2.ValheimModSpec{}},
+			},
+		}
+	})
+
+	// createServer creates the server with its last scheduled snapshot taken at lastSnapshot
+	createServer := func(lastSnapshot time.Time) {
+		Expect(k8sClient.Create(ctx, server)).To(Succeed())
+		last := metav1.NewTime(lastSnapshot)
+		server.Status.Backups.LastSnapshotTime = &last
+		Expect(k8sClient.Status().Update(ctx, server)).To(Succeed())
+	}
+
+	reconcileServer := func() {
+		reconciler := &ValheimReconciler{
+			Client:   k8sClient,
+			Scheme:   k8sClient.Scheme(),
+			Recorder: record.NewFakeRecorder(100),
+		}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(server)})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	// createSnapshot creates a snapshot of the world volume that was cut at cutAt
+	createSnapshot := func(name string, cutAt time.Time, labels map[string]string) *unstructured.Unstructured {
+		snapshot := valheim.NewVolumeSnapshot(namespace, name, "world", "", labels)
+		Expect(k8sClient.Create(ctx, snapshot)).To(Succeed())
+		Expect(unstructured.SetNestedField(snapshot.Object, cutAt.UTC().Format(time.RFC3339), "status", "creationTime")).To(Succeed())
+		Expect(unstructured.SetNestedField(snapshot.Object, true, "status", "readyToUse")).To(Succeed())
+		Expect(unstructured.SetNestedField(snapshot.Object, "5Gi", "status", "restoreSize")).To(Succeed())
+		Expect(k8sClient.Status().Update(ctx, snapshot)).To(Succeed())
+		return snapshot
+	}
+
+	snapshotNames := func() []string {
+		snapshots := &unstructured.UnstructuredList{}
+		snapshots.SetGroupVersionKind(valheim.VolumeSnapshotGroupVersionKind.GroupVersion().WithKind("VolumeSnapshotList"))
+		Expect(k8sClient.List(ctx, snapshots, client.InNamespace(namespace), client.MatchingLabels(snapshotLabels))).To(Succeed())
+		names := []string{}
+		for _, snapshot := range snapshots.Items {
+			names = append(names, snapshot.GetName())
+		}
+		return names
+	}
+
+	It("takes a snapshot of the world volume when one is due", func() {
+		createServer(time.Now().Add(-time.Hour * 48))
+		reconcileServer()
+
+		snapshots := &unstructured.UnstructuredList{}
+		snapshots.SetGroupVersionKind(valheim.VolumeSnapshotGroupVersionKind.GroupVersion().WithKind("VolumeSnapshotList"))
+		Expect(k8sClient.List(ctx, snapshots, client.InNamespace(namespace), client.MatchingLabels(snapshotLabels))).To(Succeed())
+		Expect(snapshots.Items).To(HaveLen(1))
+		snapshot := snapshots.Items[0]
+		claim, _, _ := unstructured.NestedString(snapshot.Object, "spec", "source", "persistentVolumeClaimName")
+		Expect(claim).To(Equal("world"))
+		Expect(snapshot.GetAnnotations()).To(HaveKey(valheim.AnnotationPostBackupHook))
+		Expect(snapshot.GetOwnerReferences()).To(ConsistOf(HaveField("UID", server.UID)))
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(server), server)).To(Succeed())
+		Expect(server.Status.Backups.LastSnapshotTime.Time).To(BeTemporally("~", time.Now(), time.Minute))
+	})
+
+	It("does not take a snapshot before one is due", func() {
+		createServer(time.Now())
+		reconcileServer()
+		Expect(snapshotNames()).To(BeEmpty())
+	})
+
+	It("prunes snapshots past the maximum count, keeping the newest", func() {
+		maxCount := int32(2)
+		server.Spec.Backups.MaxCount = &maxCount
+		createServer(time.Now())
+		now := time.Now()
+		createSnapshot("world-1", now.Add(-time.Hour*3), snapshotLabels)
+		createSnapshot("world-2", now.Add(-time.Hour*2), snapshotLabels)
+		createSnapshot("world-3", now.Add(-time.Hour), snapshotLabels)
+
+		reconcileServer()
+		Expect(snapshotNames()).To(ConsistOf("world-2", "world-3"))
+	})
+
+	It("prunes snapshots past the maximum age", func() {
+		maxAgeDays := int32(3)
+		server.Spec.Backups.MaxAgeDays = &maxAgeDays
+		createServer(time.Now())
+		now := time.Now()
+		createSnapshot("world-old", now.AddDate(0, 0, -4), snapshotLabels)
+		createSnapshot("world-new", now.AddDate(0, 0, -2), snapshotLabels)
+		createSnapshot("world-other", now.AddDate(0, 0, -4), map[string]string{"gamely.io": "valheim-snapshot", "server": "other"})
+
+		reconcileServer()
+		Expect(snapshotNames()).To(ConsistOf("world-new"))
+
+		other := &unstructured.Unstructured{}
+		other.SetGroupVersionKind(valheim.VolumeSnapshotGroupVersionKind)
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "world-other"}, other)).To(Succeed())
+	})
+
+	It("restores the world volume from a snapshot", func() {
+		Expect(k8sClient.Create(ctx, server)).To(Succeed())
+		createSnapshot("world-old", time.Now().Add(-time.Hour), nil)
+		restore := &serverv1alpha1.ValheimRestore{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "rollback"},
+			Spec: serverv1alpha1.ValheimRestoreSpec{
+				ValheimRef:     v1.LocalObjectReference{Name: "world"},
+				VolumeSnapshot: "world-old",
+			},
+		}
+		Expect(k8sClient.Create(ctx, restore)).To(Succeed())
+
+		reconciler := &ValheimRestoreReconciler{
+			Client:   k8sClient,
+			Scheme:   k8sClient.Scheme(),
+			Recorder: record.NewFakeRecorder(100),
+		}
+		req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(restore)}
+		claim := &v1.PersistentVolumeClaim{}
+		// The restore takes the server over, waits for it to stop, then
+		// provisions the world volume the server has not created yet
+		Eventually(func() error {
+			if _, err := reconciler.Reconcile(ctx, req); err != nil {
+				return err
+			}
+			return k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "world"}, claim)
+		}).WithTimeout(time.Second * 10).Should(Succeed())
+
+		group := valheim.VolumeSnapshotGroupVersionKind.Group
+		Expect(claim.Spec.DataSource).To(Equal(&v1.TypedLocalObjectReference{APIGroup: &group, Kind: "VolumeSnapshot", Name: "world-old"}))
+		Expect(claim.Spec.Resources.Requests.Storage().String()).To(Equal("5Gi"))
+		Expect(claim.Annotations).To(HaveKeyWithValue(serverv1alpha2.AnnotationRestoring, "rollback"))
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(server), server)).To(Succeed())
+		Expect(server.Restoring()).To(Equal("rollback"))
+
+		_, err := reconciler.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(server), server)).To(Succeed())
+		Expect(server.Restoring()).To(BeEmpty())
+		Expect(k8sClient.Get(ctx, req.NamespacedName, restore)).To(Succeed())
+		Expect(restore.Status.Phase).To(Equal(serverv1alpha1.ValheimRestorePhaseStarting))
+	})
+})
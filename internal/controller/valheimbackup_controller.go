@@ -0,0 +1,82 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"github.com/robwittman/gamely/internal/scope/valheimbackup"
+	"github.com/robwittman/gamely/internal/util"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	serverv1alpha1 "github.com/robwittman/gamely/api/v1alpha1"
+)
+
+// ValheimBackupReconciler reconciles a ValheimBackup object
+type ValheimBackupReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Executor util.PodExecutor
+}
+
+//+kubebuilder:rbac:groups=server.gamely.io,resources=valheimbackups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=server.gamely.io,resources=valheimbackups/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=server.gamely.io,resources=valheimbackups/finalizers,verbs=update
+//+kubebuilder:rbac:groups=server.gamely.io,resources=valheims,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+
+// Reconcile takes the backup a ValheimBackup asks for, or inspects the
+// scheduled backup it records, and reports the archive in its status.
+func (r *ValheimBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	backup := &serverv1alpha1.ValheimBackup{}
+	if err := r.Get(ctx, req.NamespacedName, backup); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "failed finding valheim backup resource")
+		return ctrl.Result{}, err
+	}
+
+	scope := &valheimbackup.Scope{
+		Logger:   logger,
+		Client:   r.Client,
+		Recorder: r.Recorder,
+		Executor: r.Executor,
+		Backup:   backup,
+	}
+
+	return scope.Reconcile(ctx, req)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ValheimBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&serverv1alpha1.ValheimBackup{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"github.com/robwittman/gamely/internal/scope/valheimrestore"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	serverv1alpha1 "github.com/robwittman/gamely/api/v1alpha1"
+)
+
+// ValheimRestoreReconciler reconciles a ValheimRestore object
+type ValheimRestoreReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=server.gamely.io,resources=valheimrestores,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=server.gamely.io,resources=valheimrestores/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=server.gamely.io,resources=valheimrestores/finalizers,verbs=update
+//+kubebuilder:rbac:groups=server.gamely.io,resources=valheims,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=server.gamely.io,resources=valheimbackups,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots,verbs=get;list;watch;create
+
+// Reconcile stops the server a ValheimRestore names, replaces its world with
+// the chosen backup and starts it again, reporting progress in its status.
+func (r *ValheimRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	restore := &serverv1alpha1.ValheimRestore{}
+	if err := r.Get(ctx, req.NamespacedName, restore); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "failed finding valheim restore resource")
+		return ctrl.Result{}, err
+	}
+
+	scope := &valheimrestore.Scope{
+		Logger:   logger,
+		Client:   r.Client,
+		Recorder: r.Recorder,
+		Restore:  restore,
+	}
+
+	return scope.Reconcile(ctx, req)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ValheimRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&serverv1alpha1.ValheimRestore{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,170 @@
+package valheim
+
+import (
+	"context"
+	"github.com/robwittman/gamely/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
+)
+
+const (
+	// AdminListFile, BannedListFile and PermittedListFile are the access
+	// lists Valheim reads from its config directory. It picks up changes to
+	// them while running.
+	AdminListFile     = "adminlist.txt"
+	BannedListFile    = "bannedlist.txt"
+	PermittedListFile = "permittedlist.txt"
+
+	// accessSyncContainer copies the access lists into the config directory
+	accessSyncContainer = "access-sync"
+	// accessPath is where the access list ConfigMap is mounted. It is mounted
+	// as a directory rather than by subPath, so updates to the ConfigMap
+	// reach the running pod.
+	accessPath = "/access"
+)
+
+// accessSyncScript copies the access lists from the ConfigMap into the config
+// directory whenever the ConfigMap changes. Valheim reads them from the world
+// volume, which a ConfigMap cannot be mounted into without hiding the worlds.
+// The checksum of the last list copied is kept next to it on the world volume,
+// so changes made in game are left alone until the ConfigMap changes again,
+// across restarts too.
+const accessSyncScript = `
+while true; do
+  for list in ${ACCESS_LISTS}; do
+    checksum="$(sha256sum "${ACCESS_PATH}/${list}" | cut -d " " -f 1)"
+    applied="${CONFIG_DIRECTORY}/.${list}.sha256"
+    if [ "${checksum}" != "$(cat "${applied}" 2>/dev/null)" ]; then
+      cp "${ACCESS_PATH}/${list}" "${CONFIG_DIRECTORY}/${list}.tmp"
+      mv "${CONFIG_DIRECTORY}/${list}.tmp" "${CONFIG_DIRECTORY}/${list}"
+      echo "${checksum}" > "${applied}"
+      echo "synced ${list}"
+    fi
+  done
+  sleep 10
+done
+`
+
+// accessConfigMapName is the ConfigMap the access lists of a server are rendered into
+func (s *Scope) accessConfigMapName() string {
+	return s.Valheim.Name + "-access"
+}
+
+// reconcileAccess renders the admin, banned and permitted lists into a
+// ConfigMap, merging the players of the selected player lists into those of
+// the spec. Empty lists are rendered empty too, so removing the last player
+// from a list takes effect.
+func (s *Scope) reconcileAccess(ctx context.Context, req ctrl.Request) error {
+	access, err := s.accessLists(ctx, req)
+	if err != nil {
+		return err
+	}
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: req.Namespace,
+			Name:      s.accessConfigMapName(),
+			Labels:    s.labels,
+		},
+		Data: map[string]string{
+			AdminListFile:     renderAccessList("admin", access.Admins),
+			BannedListFile:    renderAccessList("banned", access.Banned),
+			PermittedListFile: renderAccessList("permitted", access.Permitted),
+		},
+	}
+	_, err = s.apply(ctx, configMap)
+	return err
+}
+
+// accessLists merges the players of the player lists selected by the spec
+// into its access lists. Inline entries come first, followed by those of the
+// player lists by name, and players on several lists are listed once.
+func (s *Scope) accessLists(ctx context.Context, req ctrl.Request) (v1alpha2.ValheimAccessSpec, error) {
+	access := s.Valheim.Spec.Access
+	merged := v1alpha2.ValheimAccessSpec{
+		Admins:    mergeSteamIDs(nil, access.Admins),
+		Banned:    mergeSteamIDs(nil, access.Banned),
+		Permitted: mergeSteamIDs(nil, access.Permitted),
+	}
+	if access.PlayerListSelector == nil {
+		return merged, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(access.PlayerListSelector)
+	if err != nil {
+		return merged, err
+	}
+
+	lists := &v1alpha2.ValheimPlayerListList{}
+	if err := s.Client.List(ctx, lists, client.InNamespace(req.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return merged, err
+	}
+	sort.Slice(lists.Items, func(i, j int) bool {
+		return lists.Items[i].Name < lists.Items[j].Name
+	})
+	for i := range lists.Items {
+		list := &lists.Items[i]
+		merged.Admins = mergeSteamIDs(merged.Admins, list.SteamIDs(v1alpha2.PlayerRoleAdmin))
+		merged.Banned = mergeSteamIDs(merged.Banned, list.SteamIDs(v1alpha2.PlayerRoleBanned))
+		merged.Permitted = mergeSteamIDs(merged.Permitted, list.SteamIDs(v1alpha2.PlayerRolePermitted))
+	}
+	return merged, nil
+}
+
+// mergeSteamIDs appends the ids not already in list
+func mergeSteamIDs(list []string, ids []string) []string {
+	for _, id := range ids {
+		found := false
+		for _, existing := range list {
+			if existing == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, id)
+		}
+	}
+	return list
+}
+
+// renderAccessList renders SteamIDs in the format of the lists Valheim writes
+func renderAccessList(kind string, ids []string) string {
+	var b strings.Builder
+	b.WriteString("// List " + kind + " players ID  ONE per line\n")
+	for _, id := range ids {
+		b.WriteString(id + "\n")
+	}
+	return b.String()
+}
+
+// accessSync is the volume holding the access lists, and the container
+// keeping them in sync with the config directory of the server
+func (s *Scope) accessSync() (v1.Volume, v1.Container) {
+	volume := v1.Volume{
+		Name: "access",
+		VolumeSource: v1.VolumeSource{
+			ConfigMap: &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{Name: s.accessConfigMapName()},
+			},
+		},
+	}
+	container := v1.Container{
+		Name:    accessSyncContainer,
+		Image:   "busybox",
+		Command: []string{"sh", "-c"},
+		Args:    []string{accessSyncScript},
+		Env: []v1.EnvVar{
+			{Name: "ACCESS_PATH", Value: accessPath},
+			{Name: "CONFIG_DIRECTORY", Value: ConfigDirectory},
+			{Name: "ACCESS_LISTS", Value: strings.Join([]string{AdminListFile, BannedListFile, PermittedListFile}, " ")},
+		},
+		VolumeMounts: []v1.VolumeMount{
+			{Name: "access", MountPath: accessPath, ReadOnly: true},
+			{Name: "worlddata", MountPath: ConfigDirectory, SubPath: worldDataConfigPath},
+		},
+	}
+	return volume, container
+}
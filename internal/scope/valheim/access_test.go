@@ -0,0 +1,109 @@
+package valheim
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/robwittman/gamely/api/v1alpha2"
+)
+
+var _ = ginkgo.Describe("mergeSteamIDs", func() {
+	ginkgo.It("appends the ids not already in the list, in order", func() {
+		Expect(mergeSteamIDs([]string{"1", "2"}, []string{"3", "2", "1", "4", "3"})).To(Equal([]string{"1", "2", "3", "4"}))
+	})
+
+	ginkgo.It("deduplicates the ids it is given", func() {
+		Expect(mergeSteamIDs(nil, []string{"2", "1", "2"})).To(Equal([]string{"2", "1"}))
+	})
+})
+
+var _ = ginkgo.Describe("accessLists", func() {
+	var (
+		server *v1alpha2.Valheim
+		req    ctrl.Request
+	)
+
+	playerList := func(name string, labels map[string]string, players ...v1alpha2.ValheimPlayer) *v1alpha2.ValheimPlayerList {
+		return &v1alpha2.ValheimPlayerList{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Labels: labels},
+			Spec:       v1alpha2.ValheimPlayerListSpec{Players: players},
+		}
+	}
+
+	scope := func() *Scope {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(v1alpha2.AddToScheme(scheme)).To(Succeed())
+		selected := map[string]string{"community": "vikings"}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			playerList("b-moderators", selected,
+				v1alpha2.ValheimPlayer{SteamID: "30", Roles: []v1alpha2.ValheimPlayerRole{v1alpha2.PlayerRoleAdmin, v1alpha2.PlayerRolePermitted}},
+				v1alpha2.ValheimPlayer{SteamID: "10", Roles: []v1alpha2.ValheimPlayerRole{v1alpha2.PlayerRoleAdmin}},
+			),
+			playerList("a-griefers", selected,
+				v1alpha2.ValheimPlayer{SteamID: "40", Roles: []v1alpha2.ValheimPlayerRole{v1alpha2.PlayerRoleBanned}},
+				v1alpha2.ValheimPlayer{SteamID: "20", Roles: []v1alpha2.ValheimPlayerRole{v1alpha2.PlayerRoleAdmin}},
+			),
+			playerList("other", map[string]string{"community": "other"},
+				v1alpha2.ValheimPlayer{SteamID: "50", Roles: []v1alpha2.ValheimPlayerRole{v1alpha2.PlayerRoleAdmin}},
+			),
+		).Build()
+		return &Scope{
+			Logger:   logr.Discard(),
+			Client:   c,
+			Recorder: record.NewFakeRecorder(10),
+			Valheim:  server,
+		}
+	}
+
+	ginkgo.BeforeEach(func() {
+		server = &v1alpha2.Valheim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world"},
+			Spec: v1alpha2.ValheimSpec{
+				Access: v1alpha2.ValheimAccessSpec{
+					Admins:    []string{"20", "1", "20"},
+					Permitted: []string{"2"},
+				},
+			},
+		}
+		req = ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "world"}}
+	})
+
+	ginkgo.It("lists the inline players only without a selector", func() {
+		access, err := scope().accessLists(context.Background(), req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(access).To(Equal(v1alpha2.ValheimAccessSpec{
+			Admins:    []string{"20", "1"},
+			Permitted: []string{"2"},
+		}))
+	})
+
+	ginkgo.It("merges the selected player lists after the inline players, by name", func() {
+		server.Spec.Access.PlayerListSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"community": "vikings"}}
+		access, err := scope().accessLists(context.Background(), req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(access.Admins).To(Equal([]string{"20", "1", "30", "10"}))
+		Expect(access.Banned).To(Equal([]string{"40"}))
+		Expect(access.Permitted).To(Equal([]string{"2", "30"}))
+		Expect(access.PlayerListSelector).To(BeNil())
+	})
+
+	ginkgo.It("fails on a selector it cannot parse", func() {
+		server.Spec.Access.PlayerListSelector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "community", Operator: "Near"},
+		}}
+		_, err := scope().accessLists(context.Background(), req)
+		Expect(err).To(HaveOccurred())
+	})
+})
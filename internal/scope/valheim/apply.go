@@ -0,0 +1,61 @@
+package valheim
+
+import (
+	"context"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// FieldManager is the field manager all of our server-side applies are made under
+const FieldManager = "gamely"
+
+// apply server-side applies obj as owned by the Valheim. Only the fields we set
+// are claimed, so fields managed by other controllers and values allocated by
+// the API server (cluster IPs, node ports, defaults) are left alone. obj is
+// updated with the live object, and a Drifted event is recorded if applying it
+// changed anything even though the Valheim spec did not.
+func (s *Scope) apply(ctx context.Context, obj client.Object) (bool, error) {
+	gvk, err := apiutil.GVKForObject(obj, s.Client.Scheme())
+	if err != nil {
+		return false, err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+
+	if err := controllerutil.SetOwnerReference(s.Valheim, obj, s.Client.Scheme()); err != nil {
+		s.Logger.Error(err, "failed setting owner reference", "kind", gvk.Kind, "name", obj.GetName())
+	}
+
+	existing, err := s.Client.Scheme().New(gvk)
+	if err != nil {
+		return false, err
+	}
+	existingResourceVersion := ""
+	if err := s.Client.Get(ctx, client.ObjectKeyFromObject(obj), existing.(client.Object)); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+	} else {
+		existingResourceVersion = existing.(client.Object).GetResourceVersion()
+	}
+
+	if err := s.Client.Patch(ctx, obj, client.Apply, client.FieldOwner(FieldManager), client.ForceOwnership); err != nil {
+		return false, err
+	}
+
+	if obj.GetResourceVersion() == existingResourceVersion {
+		return false, nil
+	}
+
+	if existingResourceVersion == "" {
+		s.Logger.Info("created resource", "kind", gvk.Kind, "name", obj.GetName())
+		s.recordDrift(gvk.Kind, obj.GetName(), "recreated")
+	} else {
+		s.Logger.Info("updated resource", "kind", gvk.Kind, "name", obj.GetName())
+		s.recordDrift(gvk.Kind, obj.GetName(), "updated")
+	}
+	return true, nil
+}
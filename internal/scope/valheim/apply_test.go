@@ -0,0 +1,111 @@
+package valheim
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/robwittman/gamely/api/v1alpha2"
+)
+
+// applyClient stands in for server-side apply, which the fake client only
+// supports as a strategic merge patch of an object that already exists
+type applyClient struct {
+	client.Client
+}
+
+func (c applyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	existing := obj.DeepCopyObject().(client.Object)
+	if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+		if errors.IsNotFound(err) {
+			return c.Client.Create(ctx, obj)
+		}
+		return err
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+var _ = ginkgo.Describe("apply", func() {
+	var (
+		server   *v1alpha2.Valheim
+		recorder *record.FakeRecorder
+	)
+
+	ginkgo.BeforeEach(func() {
+		server = &v1alpha2.Valheim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world", UID: "world-uid", Generation: 2},
+			Status:     v1alpha2.ValheimStatus{ObservedGeneration: 2},
+		}
+		recorder = record.NewFakeRecorder(10)
+	})
+
+	scope := func(objects ...client.Object) *Scope {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(v1alpha2.AddToScheme(scheme)).To(Succeed())
+		return &Scope{
+			Logger:   logr.Discard(),
+			Client:   applyClient{fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()},
+			Recorder: recorder,
+			Valheim:  server,
+		}
+	}
+
+	configMap := func(data string) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world-mods"},
+			Data:       map[string]string{"registry.txt": data},
+		}
+	}
+
+	ginkgo.It("recreates a deleted object as owned by the server and reports the drift", func() {
+		s := scope()
+		desired := configMap("a/Mod:1.0.0")
+		Expect(s.apply(context.Background(), desired)).To(BeTrue())
+
+		stored := &v1.ConfigMap{}
+		Expect(s.Client.Get(context.Background(), client.ObjectKeyFromObject(desired), stored)).To(Succeed())
+		Expect(stored.Data).To(HaveKeyWithValue("registry.txt", "a/Mod:1.0.0"))
+		Expect(stored.OwnerReferences).To(ConsistOf(HaveField("UID", server.UID)))
+		Expect(recorder.Events).To(Receive(Equal("Warning Drifted recreated ConfigMap world-mods to match the desired state")))
+	})
+
+	ginkgo.It("repairs a hand edited object and reports the drift", func() {
+		s := scope(configMap("edited"))
+		Expect(s.apply(context.Background(), configMap("a/Mod:1.0.0"))).To(BeTrue())
+
+		stored := &v1.ConfigMap{}
+		Expect(s.Client.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "world-mods"}, stored)).To(Succeed())
+		Expect(stored.Data).To(HaveKeyWithValue("registry.txt", "a/Mod:1.0.0"))
+		Expect(recorder.Events).To(Receive(Equal("Warning Drifted updated ConfigMap world-mods to match the desired state")))
+	})
+
+	ginkgo.It("does not report changes made for a new generation of the spec", func() {
+		server.Generation = 3
+		s := scope(configMap("a/Mod:1.0.0"))
+		Expect(s.apply(context.Background(), configMap("a/Mod:2.0.0"))).To(BeTrue())
+		Expect(recorder.Events).NotTo(Receive())
+	})
+
+	ginkgo.It("does not report creating the objects of a new server", func() {
+		server.Status.ObservedGeneration = 0
+		s := scope()
+		Expect(s.apply(context.Background(), configMap("a/Mod:1.0.0"))).To(BeTrue())
+		Expect(recorder.Events).NotTo(Receive())
+	})
+})
@@ -0,0 +1,642 @@
+package valheim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"github.com/robwittman/gamely/api/v1alpha1"
+	"github.com/robwittman/gamely/api/v1alpha2"
+	"github.com/robwittman/gamely/internal/util"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ConfigDirectory is where the server image keeps worlds, backups and mods
+	ConfigDirectory = "/config"
+
+	// worldDataConfigPath and worldDataServerPath are the sub paths of the
+	// world volume holding the server's config directory and its installation.
+	// Volumes of older servers are moved into them by worldLayoutScript.
+	worldDataConfigPath = "config"
+	worldDataServerPath = "server"
+
+	// UploaderImage is the image used to upload backups to the bucket
+	UploaderImage = "amazon/aws-cli"
+	// DefaultUploadSchedule is how often backups are uploaded when no
+	// spec.backups.uploadSchedule is set
+	DefaultUploadSchedule = "*/15 * * * *"
+
+	// uploadDeadlineSeconds bounds an upload, including the time spent waiting
+	// to be scheduled next to the server
+	uploadDeadlineSeconds = 600
+
+	// backupIndexDelay gives a scheduled backup time to finish before the
+	// backups volume is checked for it
+	backupIndexDelay = time.Minute * 2
+	// backupIndexLimit caps how many archives one index job reports, keeping
+	// its result inside the 4096 byte termination message limit
+	backupIndexLimit = 80
+)
+
+// backupScript archives the world directory the same way the server image's
+// own backups are made, into BACKUP_FILE when it is set. The backup hooks run
+// in the server around the job rather than in it. When BACKUP_ARCHIVE is set
+// an existing archive is inspected instead. Either way the archive is
+// described in the termination message as a BackupResult.
+const backupScript = `
+set -eu
+if [ -n "${BACKUP_ARCHIVE:-}" ]; then
+  backup_file="${BACKUPS_DIRECTORY}/${BACKUP_ARCHIVE}"
+  test -f "${backup_file}"
+else
+  backup_file="${BACKUP_FILE:-}"
+  if [ -z "${backup_file}" ]; then
+    backup_file="${BACKUPS_DIRECTORY}/worlds_local-$(date +%Y%m%d-%H%M%S)"
+    if [ "${BACKUPS_ZIP}" = "true" ]; then
+      backup_file="${backup_file}.zip"
+    else
+      backup_file="${backup_file}.tar.gz"
+    fi
+  fi
+  cd "${CONFIG_DIRECTORY}"
+  if [ "${BACKUPS_ZIP}" = "true" ]; then
+    zip -r "${backup_file}" worlds_local
+  else
+    tar -czf "${backup_file}" worlds_local
+  fi
+  echo "created ${backup_file}"
+fi
+printf '{"archive":"%s","size":%s,"sha256":"%s","time":"%s"}' \
+  "$(basename "${backup_file}")" \
+  "$(stat -c %s "${backup_file}")" \
+  "$(sha256sum "${backup_file}" | cut -d " " -f 1)" \
+  "$(date -u -r "${backup_file}" +%Y-%m-%dT%H:%M:%SZ)" > /dev/termination-log
+`
+
+// indexScript lists the newest archives on the backups volume in the
+// termination message, separated by spaces
+const indexScript = `
+set -eu
+cd "${BACKUPS_DIRECTORY}"
+ls -1t | grep -E '\.(zip|tar\.gz)$' | head -n "${INDEX_LIMIT}" | tr '\n' ' ' > /dev/termination-log
+`
+
+// uploadScript pushes new backup archives to the bucket, then prunes the
+// archives there past the same retention as the backups volume. Archive names
+// sort by the time they were taken.
+const uploadScript = `
+set -eu
+export AWS_DEFAULT_REGION="${AWS_DEFAULT_REGION:-us-east-1}"
+aws configure set default.s3.signature_version s3v4
+destination="s3://${BUCKET}/${BACKUP_PREFIX}/"
+if [ -n "${AWS_ENDPOINT_URL:-}" ]; then
+  set -- --endpoint-url "${AWS_ENDPOINT_URL}"
+fi
+aws "$@" s3 sync "${BACKUPS_DIRECTORY}" "${destination}" --exclude "*" --include "*.zip" --include "*.tar.gz" --no-progress
+cutoff="$(date -u -d "-${BACKUPS_MAX_AGE} days" +%Y-%m-%d)"
+aws "$@" s3 ls "${destination}" | awk -v cutoff="${cutoff}" '$1 < cutoff {print $4}' | grep -E '\.(zip|tar\.gz)$' | while read -r archive; do
+  aws "$@" s3 rm "${destination}${archive}"
+done
+if [ "${BACKUPS_MAX_COUNT}" -gt 0 ]; then
+  aws "$@" s3 ls "${destination}" | awk '{print $4}' | grep -E '\.(zip|tar\.gz)$' | sort -r | tail -n +$((BACKUPS_MAX_COUNT + 1)) | while read -r archive; do
+    aws "$@" s3 rm "${destination}${archive}"
+  done
+fi
+echo "uploaded backups to ${destination}"
+`
+
+// BackupJobOptions tune the job built by NewBackupJob
+type BackupJobOptions struct {
+	// Archive is an existing archive on the backups volume to inspect instead
+	// of taking a new backup
+	Archive string
+	// File is the path on the backups volume to write a new archive to, as
+	// chosen by NewBackupFile. A name is picked by the job when it is empty.
+	File string
+	// Upload uploads the backups volume once the archive is made, if a bucket is configured
+	Upload bool
+	// Colocate schedules the job onto the node the server is running on, so the
+	// ReadWriteOnce volumes can be shared with it
+	Colocate bool
+}
+
+// BackupResult describes an archive on the backups volume, as reported by a backup job
+type BackupResult struct {
+	Archive string      `json:"archive"`
+	Size    int64       `json:"size"`
+	SHA256  string      `json:"sha256"`
+	Time    metav1.Time `json:"time"`
+}
+
+// Labels are the labels identifying the server pods of valheim
+func Labels(valheim *v1alpha2.Valheim) map[string]string {
+	return map[string]string{
+		"gamely.io": "valheim",
+		"server":    valheim.Name,
+	}
+}
+
+// BackupLocation is where archive is stored on the backups volume of valheim
+func BackupLocation(valheim *v1alpha2.Valheim, archive string) string {
+	return fmt.Sprintf("pvc://%s-backups/%s", valheim.Name, archive)
+}
+
+// NewBackupFile is the path on the backups volume of a new archive of the
+// world of valheim taken at t, named the way the server image names its own
+// backups. The backup hooks are handed this path.
+func NewBackupFile(valheim *v1alpha2.Valheim, t time.Time) string {
+	extension := ".tar.gz"
+	if valheim.Spec.Backups.GetCompression() == v1alpha2.BackupCompressionZip {
+		extension = ".zip"
+	}
+	return fmt.Sprintf("%s/worlds_local-%s%s", valheim.Spec.Backups.GetDirectory(), t.UTC().Format("20060102-150405"), extension)
+}
+
+// uploadEnabled reports whether backups should be shipped to a bucket
+func uploadEnabled(valheim *v1alpha2.Valheim) bool {
+	return valheim.Spec.Backups.Bucket != ""
+}
+
+// serverAffinity schedules a pod onto the node the server of valheim is running on
+func serverAffinity(valheim *v1alpha2.Valheim) *v1.Affinity {
+	return &v1.Affinity{
+		PodAffinity: &v1.PodAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{
+				{
+					LabelSelector: &metav1.LabelSelector{MatchLabels: Labels(valheim)},
+					TopologyKey:   v1.LabelHostname,
+				},
+			},
+		},
+	}
+}
+
+// reconcileBackupUpload manages the cron job uploading backups to the bucket,
+// removing it again once no bucket is configured
+func (s *Scope) reconcileBackupUpload(ctx context.Context, req ctrl.Request) (*batchv1.CronJob, error) {
+	if !uploadEnabled(s.Valheim) {
+		cronJob := &batchv1.CronJob{}
+		if err := s.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name + "-backup-upload"}, cronJob); err != nil {
+			if errors.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		s.Logger.Info("no backup bucket configured, removing upload cron job")
+		if err := s.Client.Delete(ctx, cronJob); err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	cronJob := s.makeUploadCronJob(req.Name + "-backup-upload")
+	if _, err := s.apply(ctx, cronJob); err != nil {
+		return nil, err
+	}
+	return cronJob, nil
+}
+
+// reconcileBackupIndex records the backups the server takes on
+// spec.backups.schedule as ValheimBackups. Shortly after each scheduled backup
+// a job lists the backups volume, and any archive we have no ValheimBackup for
+// yet gets one. Returns how long to wait until the next check is due.
+func (s *Scope) reconcileBackupIndex(ctx context.Context, req ctrl.Request, running bool) (time.Duration, error) {
+	if s.Valheim.Spec.Backups.Schedule == "" || s.Valheim.Spec.Backups.GetMode() != v1alpha2.BackupModeArchive {
+		return 0, nil
+	}
+	schedule, err := cron.ParseStandard(s.Valheim.Spec.Backups.Schedule)
+	if err != nil {
+		s.Logger.Error(err, "invalid backup schedule, not recording scheduled backups")
+		return 0, nil
+	}
+
+	job := &batchv1.Job{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name + "-backup-index"}, job); err != nil {
+		if !errors.IsNotFound(err) {
+			return 0, err
+		}
+
+		if last := s.Valheim.Status.Backups.LastIndexTime; last != nil {
+			due := schedule.Next(last.Time).Add(backupIndexDelay)
+			if wait := time.Until(due); wait > 0 {
+				return wait, nil
+			}
+		}
+		// Scheduled backups are only taken while the server is up, and the
+		// index job has to run next to it to read the backups volume
+		if !running {
+			return 0, nil
+		}
+		_, err := s.apply(ctx, s.makeIndexJob(req.Name+"-backup-index"))
+		return 0, err
+	}
+
+	finished, result := JobFinished(job)
+	if !finished {
+		return 0, nil
+	}
+	if result == batchv1.JobComplete {
+		message, err := jobContainerMessage(ctx, s.Client, job, "index")
+		if err != nil {
+			return 0, err
+		}
+		if err := s.recordScheduledBackups(ctx, strings.Fields(message)); err != nil {
+			return 0, err
+		}
+	} else {
+		s.Logger.Info("backup index job failed, trying again after the next scheduled backup")
+	}
+
+	if err := s.Client.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+		return 0, err
+	}
+	now := metav1.Now()
+	s.Valheim.Status.Backups.LastIndexTime = &now
+	return time.Until(schedule.Next(now.Time).Add(backupIndexDelay)), nil
+}
+
+// recordScheduledBackups creates a ValheimBackup for each archive that is not
+// recorded by one yet, and deletes the scheduled ValheimBackups whose archive
+// retention has since removed from the backups volume. archives are listed
+// newest first.
+func (s *Scope) recordScheduledBackups(ctx context.Context, archives []string) error {
+	backups := &v1alpha1.ValheimBackupList{}
+	if err := s.Client.List(ctx, backups, client.InNamespace(s.Valheim.Namespace)); err != nil {
+		return err
+	}
+	indexed := map[string]bool{}
+	for _, archive := range archives {
+		indexed[archive] = true
+	}
+	recorded := map[string]bool{}
+	for i := range backups.Items {
+		backup := &backups.Items[i]
+		if backup.Spec.ValheimRef.Name != s.Valheim.Name {
+			continue
+		}
+		if archiveExpired(backup, archives, indexed) {
+			if err := s.Client.Delete(ctx, backup); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			s.Logger.Info("deleted scheduled backup whose archive is gone", "archive", backup.Spec.Archive, "backup", backup.Name)
+			continue
+		}
+		recorded[backup.Spec.Archive] = true
+		recorded[backup.Status.Archive] = true
+	}
+
+	for _, archive := range archives {
+		if recorded[archive] {
+			continue
+		}
+		backup := &v1alpha1.ValheimBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      scheduledBackupName(s.Valheim.Name, archive),
+				Namespace: s.Valheim.Namespace,
+				Labels:    map[string]string{v1alpha1.LabelScheduledBackup: "true"},
+			},
+			Spec: v1alpha1.ValheimWorldBackupSpec{
+				ValheimRef: v1.LocalObjectReference{Name: s.Valheim.Name},
+				Archive:    archive,
+			},
+		}
+		if err := controllerutil.SetOwnerReference(s.Valheim, backup, s.Client.Scheme()); err != nil {
+			return err
+		}
+		if err := s.Client.Create(ctx, backup); err != nil {
+			if errors.IsAlreadyExists(err) {
+				continue
+			}
+			return err
+		}
+		s.Logger.Info("recorded scheduled backup", "archive", archive, "backup", backup.Name)
+	}
+	return nil
+}
+
+// archiveExpired reports whether backup was recorded for a scheduled archive
+// that is missing from the index. An index cut off at backupIndexLimit only
+// tells about archives newer than the oldest it lists, which archive names sort
+// by.
+func archiveExpired(backup *v1alpha1.ValheimBackup, archives []string, indexed map[string]bool) bool {
+	if backup.Labels[v1alpha1.LabelScheduledBackup] != "true" || backup.Spec.Archive == "" || indexed[backup.Spec.Archive] {
+		return false
+	}
+	if len(archives) >= backupIndexLimit {
+		return backup.Spec.Archive > archives[len(archives)-1]
+	}
+	return true
+}
+
+// scheduledBackupName names the ValheimBackup recording archive, which the
+// server names after the time it was taken
+func scheduledBackupName(server string, archive string) string {
+	stem := strings.TrimSuffix(strings.TrimSuffix(archive, ".zip"), ".tar.gz")
+	stem = strings.TrimPrefix(stem, "worlds_local-")
+	stem = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(stem))
+	return server + "-" + strings.Trim(stem, "-")
+}
+
+// backupRetentionEnv configures how backups are kept, the same way for the
+// server's own backups and the bucket copy
+func backupRetentionEnv(valheim *v1alpha2.Valheim) []v1.EnvVar {
+	backups := valheim.Spec.Backups
+	return []v1.EnvVar{
+		{Name: EnvVarBackupsDirectory, Value: backups.GetDirectory()},
+		{Name: EnvVarBackupsMax, Value: strconv.Itoa(int(backups.GetMaxCount()))},
+		{Name: EnvVarBackupsMaxAge, Value: strconv.Itoa(int(backups.GetMaxAgeDays()))},
+		{Name: EnvVarBackupsZip, Value: strconv.FormatBool(backups.GetCompression() == v1alpha2.BackupCompressionZip)},
+	}
+}
+
+// bucketEnv is the environment the aws cli needs to reach the backup bucket of valheim
+func bucketEnv(valheim *v1alpha2.Valheim) ([]v1.EnvVar, []v1.EnvFromSource) {
+	env := []v1.EnvVar{
+		{Name: "BUCKET", Value: valheim.Spec.Backups.Bucket},
+		{Name: "BACKUP_PREFIX", Value: valheim.Namespace + "/" + valheim.Name},
+	}
+	if endpoint := valheim.Spec.Backups.Endpoint; endpoint != "" {
+		env = append(env, v1.EnvVar{Name: "AWS_ENDPOINT_URL", Value: endpoint})
+	}
+
+	var envFrom []v1.EnvFromSource
+	if ref := valheim.Spec.Backups.SecretKeyRef; ref != nil {
+		envFrom = append(envFrom, v1.EnvFromSource{
+			SecretRef: &v1.SecretEnvSource{
+				LocalObjectReference: v1.LocalObjectReference{Name: ref.Name},
+			},
+		})
+	}
+	return env, envFrom
+}
+
+// newUploadContainer builds the container that uploads the backups volume
+func newUploadContainer(valheim *v1alpha2.Valheim) v1.Container {
+	env, envFrom := bucketEnv(valheim)
+	env = append(env, backupRetentionEnv(valheim)...)
+
+	return v1.Container{
+		Name:         "upload",
+		Image:        UploaderImage,
+		Command:      []string{"sh", "-c"},
+		Args:         []string{uploadScript},
+		Env:          env,
+		EnvFrom:      envFrom,
+		VolumeMounts: []v1.VolumeMount{{Name: "backups", MountPath: valheim.Spec.Backups.GetDirectory(), ReadOnly: true}},
+	}
+}
+
+// backupsVolume is the pod volume for the backups claim of valheim
+func backupsVolume(valheim *v1alpha2.Valheim) v1.Volume {
+	return v1.Volume{
+		Name: "backups",
+		VolumeSource: v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+				ClaimName: valheim.Name + "-backups",
+			},
+		},
+	}
+}
+
+// makeUploadCronJob builds the cron job uploading backups to the bucket. The
+// backups volume is ReadWriteOnce, so uploads are scheduled onto the node the
+// server is running on, and suspended while the server is paused.
+func (s *Scope) makeUploadCronJob(name string) *batchv1.CronJob {
+	labels := map[string]string{
+		"gamely.io": "valheim-backup-upload",
+		"server":    s.Valheim.Name,
+	}
+
+	schedule := s.Valheim.Spec.Backups.UploadSchedule
+	if schedule == "" {
+		schedule = DefaultUploadSchedule
+	}
+
+	return &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: s.Valheim.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:          schedule,
+			ConcurrencyPolicy: batchv1.ForbidConcurrent,
+			Suspend:           util.BoolAddr(s.Valheim.Stopped()),
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: batchv1.JobSpec{
+					BackoffLimit:          util.Int32Addr(2),
+					ActiveDeadlineSeconds: util.Int64Addr(uploadDeadlineSeconds),
+					Template: v1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: labels,
+						},
+						Spec: v1.PodSpec{
+							RestartPolicy: v1.RestartPolicyNever,
+							Affinity:      serverAffinity(s.Valheim),
+							Containers:    []v1.Container{newUploadContainer(s.Valheim)},
+							Volumes:       []v1.Volume{backupsVolume(s.Valheim)},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// makeIndexJob builds the job listing the archives on the backups volume
+func (s *Scope) makeIndexJob(name string) *batchv1.Job {
+	labels := map[string]string{
+		"gamely.io": "valheim-backup-index",
+		"server":    s.Valheim.Name,
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: s.Valheim.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          util.Int32Addr(2),
+			ActiveDeadlineSeconds: util.Int64Addr(uploadDeadlineSeconds),
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: v1.PodSpec{
+					RestartPolicy: v1.RestartPolicyNever,
+					Affinity:      serverAffinity(s.Valheim),
+					Containers: []v1.Container{
+						{
+							Name:    "index",
+							Image:   s.Valheim.GetImage(),
+							Command: []string{"sh", "-c"},
+							Args:    []string{indexScript},
+							Env: []v1.EnvVar{
+								{Name: EnvVarBackupsDirectory, Value: s.Valheim.Spec.Backups.GetDirectory()},
+								{Name: "INDEX_LIMIT", Value: strconv.Itoa(backupIndexLimit)},
+							},
+							VolumeMounts: []v1.VolumeMount{{Name: "backups", MountPath: s.Valheim.Spec.Backups.GetDirectory(), ReadOnly: true}},
+						},
+					},
+					Volumes: []v1.Volume{backupsVolume(s.Valheim)},
+				},
+			},
+		},
+	}
+}
+
+// NewBackupJob builds a job that archives the world of valheim into the
+// backups volume. Unless the job is colocated with the server, the server has
+// to be scaled down first, since the world volume is ReadWriteOnce.
+func NewBackupJob(valheim *v1alpha2.Valheim, name string, opts BackupJobOptions) *batchv1.Job {
+	labels := map[string]string{
+		"gamely.io": "valheim-backup",
+		"server":    valheim.Name,
+	}
+
+	env := []v1.EnvVar{
+		{Name: "CONFIG_DIRECTORY", Value: ConfigDirectory},
+		{Name: EnvVarBackupsDirectory, Value: valheim.Spec.Backups.GetDirectory()},
+		{Name: EnvVarBackupsZip, Value: strconv.FormatBool(valheim.Spec.Backups.GetCompression() == v1alpha2.BackupCompressionZip)},
+	}
+	if opts.Archive != "" {
+		env = append(env, v1.EnvVar{Name: "BACKUP_ARCHIVE", Value: opts.Archive})
+	}
+	if opts.File != "" {
+		env = append(env, v1.EnvVar{Name: "BACKUP_FILE", Value: opts.File})
+	}
+
+	backup := v1.Container{
+		Name:    "backup",
+		Image:   valheim.GetImage(),
+		Command: []string{"sh", "-c"},
+		Args:    []string{backupScript},
+		Env:     env,
+		VolumeMounts: []v1.VolumeMount{
+			{Name: "worlddata", MountPath: ConfigDirectory, SubPath: worldDataConfigPath},
+			{Name: "backups", MountPath: valheim.Spec.Backups.GetDirectory()},
+		},
+	}
+
+	var initContainers []v1.Container
+	containers := []v1.Container{backup}
+	if opts.Upload && uploadEnabled(valheim) {
+		initContainers = containers
+		containers = []v1.Container{newUploadContainer(valheim)}
+	}
+
+	var affinity *v1.Affinity
+	if opts.Colocate {
+		affinity = serverAffinity(valheim)
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: valheim.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: util.Int32Addr(2),
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: v1.PodSpec{
+					RestartPolicy:  v1.RestartPolicyNever,
+					Affinity:       affinity,
+					InitContainers: initContainers,
+					Containers:     containers,
+					Volumes: []v1.Volume{
+						{
+							Name: "worlddata",
+							VolumeSource: v1.VolumeSource{
+								PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+									ClaimName: valheim.Name,
+								},
+							},
+						},
+						backupsVolume(valheim),
+					},
+				},
+			},
+		},
+	}
+}
+
+// BackupJobResult reads the archive a finished backup job reported
+func BackupJobResult(ctx context.Context, c client.Client, job *batchv1.Job) (*BackupResult, error) {
+	message, err := jobContainerMessage(ctx, c, job, "backup")
+	if err != nil {
+		return nil, err
+	}
+	result := &BackupResult{}
+	if err := json.Unmarshal([]byte(message), result); err != nil {
+		return nil, fmt.Errorf("failed reading backup result of job %s: %w", job.Name, err)
+	}
+	return result, nil
+}
+
+// jobContainerMessage returns the termination message container of job
+// succeeded with, or why the job failed if it never did
+func jobContainerMessage(ctx context.Context, c client.Client, job *batchv1.Job, container string) (string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(job.Spec.Selector)
+	if err != nil {
+		return "", err
+	}
+	pods := &v1.PodList{}
+	if err := c.List(ctx, pods, client.InNamespace(job.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return "", err
+	}
+
+	failure := fmt.Sprintf("container %s did not finish", container)
+	for _, pod := range pods.Items {
+		statuses := []v1.ContainerStatus{}
+		statuses = append(statuses, pod.Status.InitContainerStatuses...)
+		statuses = append(statuses, pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			terminated := status.State.Terminated
+			if terminated == nil {
+				continue
+			}
+			if terminated.ExitCode != 0 {
+				failure = fmt.Sprintf("container %s %s", status.Name, terminatedMessage(terminated))
+				continue
+			}
+			if status.Name == container {
+				return terminated.Message, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("job %s did not succeed: %s", job.Name, failure)
+}
+
+// JobFinished reports whether job has completed or failed for good
+func JobFinished(job *batchv1.Job) (bool, batchv1.JobConditionType) {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) && condition.Status == v1.ConditionTrue {
+			return true, condition.Type
+		}
+	}
+	return false, ""
+}
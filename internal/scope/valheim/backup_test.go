@@ -0,0 +1,187 @@
+package valheim
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/robwittman/gamely/api/v1alpha1"
+	"github.com/robwittman/gamely/api/v1alpha2"
+)
+
+var _ = ginkgo.Describe("makeUploadCronJob", func() {
+	var server *v1alpha2.Valheim
+
+	ginkgo.BeforeEach(func() {
+		server = &v1alpha2.Valheim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world"},
+			Spec: v1alpha2.ValheimSpec{
+				Backups: v1alpha2.ValheimBackupSpec{
+					Bucket:       "backups",
+					Endpoint:     "http://minio.default:9000",
+					SecretKeyRef: &v1.SecretReference{Name: "backups"},
+				},
+			},
+		}
+	})
+
+	upload := func() v1.Container {
+		s := &Scope{Logger: logr.Discard(), Valheim: server}
+		cronJob := s.makeUploadCronJob("world-backup-upload")
+		containers := cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers
+		Expect(containers).To(HaveLen(1))
+		return containers[0]
+	}
+
+	ginkgo.It("uploads to the bucket under the namespace and name of the server", func() {
+		Expect(upload().Env).To(ContainElements(
+			v1.EnvVar{Name: "BUCKET", Value: "backups"},
+			v1.EnvVar{Name: "BACKUP_PREFIX", Value: "default/world"},
+		))
+	})
+
+	ginkgo.It("reaches a plain http endpoint as given", func() {
+		Expect(upload().Env).To(ContainElement(v1.EnvVar{Name: "AWS_ENDPOINT_URL", Value: "http://minio.default:9000"}))
+	})
+
+	ginkgo.It("leaves the endpoint to the aws cli without one", func() {
+		server.Spec.Backups.Endpoint = ""
+		Expect(upload().Env).NotTo(ContainElement(HaveField("Name", "AWS_ENDPOINT_URL")))
+	})
+
+	ginkgo.It("reads the credentials from the secret", func() {
+		Expect(upload().EnvFrom).To(Equal([]v1.EnvFromSource{
+			{SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "backups"}}},
+		}))
+	})
+
+	ginkgo.It("leaves the credentials to the pod without a secret", func() {
+		server.Spec.Backups.SecretKeyRef = nil
+		Expect(upload().EnvFrom).To(BeEmpty())
+	})
+
+	ginkgo.It("prunes the bucket with the retention of the backups volume", func() {
+		maxCount := int32(3)
+		server.Spec.Backups.MaxCount = &maxCount
+		Expect(upload().Env).To(ContainElements(
+			v1.EnvVar{Name: EnvVarBackupsDirectory, Value: v1alpha2.DefaultBackupsDirectory},
+			v1.EnvVar{Name: EnvVarBackupsMax, Value: "3"},
+		))
+	})
+
+	ginkgo.It("is suspended while the server is paused", func() {
+		server.Spec.Paused = true
+		s := &Scope{Logger: logr.Discard(), Valheim: server}
+		Expect(*s.makeUploadCronJob("world-backup-upload").Spec.Suspend).To(BeTrue())
+	})
+})
+
+var _ = ginkgo.Describe("NewBackupJob", func() {
+	ginkgo.It("uploads after the backup when asked to and a bucket is configured", func() {
+		server := &v1alpha2.Valheim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world"},
+			Spec: v1alpha2.ValheimSpec{
+				Backups: v1alpha2.ValheimBackupSpec{Bucket: "backups", Endpoint: "https://s3.example.com"},
+			},
+		}
+		spec := NewBackupJob(server, "world-backup", BackupJobOptions{Upload: true}).Spec.Template.Spec
+		Expect(spec.InitContainers).To(ConsistOf(HaveField("Name", "backup")))
+		Expect(spec.Containers).To(ConsistOf(HaveField("Name", "upload")))
+		Expect(spec.Containers[0].Env).To(ContainElement(v1.EnvVar{Name: "AWS_ENDPOINT_URL", Value: "https://s3.example.com"}))
+
+		spec = NewBackupJob(server, "world-backup", BackupJobOptions{}).Spec.Template.Spec
+		Expect(spec.InitContainers).To(BeEmpty())
+		Expect(spec.Containers).To(ConsistOf(HaveField("Name", "backup")))
+	})
+})
+
+var _ = ginkgo.Describe("recordScheduledBackups", func() {
+	var server *v1alpha2.Valheim
+
+	ginkgo.BeforeEach(func() {
+		server = &v1alpha2.Valheim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world", UID: "world-uid"},
+		}
+	})
+
+	backup := func(name string, archive string, scheduled bool) *v1alpha1.ValheimBackup {
+		backup := &v1alpha1.ValheimBackup{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec: v1alpha1.ValheimWorldBackupSpec{
+				ValheimRef: v1.LocalObjectReference{Name: "world"},
+				Archive:    archive,
+			},
+		}
+		if scheduled {
+			backup.Labels = map[string]string{v1alpha1.LabelScheduledBackup: "true"}
+		}
+		return backup
+	}
+
+	scope := func(objects ...client.Object) *Scope {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+		Expect(v1alpha2.AddToScheme(scheme)).To(Succeed())
+		return &Scope{
+			Logger:   logr.Discard(),
+			Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+			Recorder: record.NewFakeRecorder(10),
+			Valheim:  server,
+		}
+	}
+
+	recorded := func(s *Scope) []string {
+		backups := &v1alpha1.ValheimBackupList{}
+		Expect(s.Client.List(context.Background(), backups)).To(Succeed())
+		var archives []string
+		for _, backup := range backups.Items {
+			archives = append(archives, backup.Spec.Archive)
+		}
+		return archives
+	}
+
+	ginkgo.It("records the archives without a backup", func() {
+		s := scope(backup("world-20240101-000000", "worlds_local-20240101-000000.zip", true))
+		Expect(s.recordScheduledBackups(context.Background(), []string{
+			"worlds_local-20240102-000000.zip",
+			"worlds_local-20240101-000000.zip",
+		})).To(Succeed())
+		Expect(recorded(s)).To(ConsistOf("worlds_local-20240101-000000.zip", "worlds_local-20240102-000000.zip"))
+	})
+
+	ginkgo.It("deletes the scheduled backups whose archive is gone", func() {
+		s := scope(
+			backup("world-20240101-000000", "worlds_local-20240101-000000.zip", true),
+			backup("world-20240102-000000", "worlds_local-20240102-000000.zip", true),
+			backup("manual", "worlds_local-20231231-000000.zip", false),
+		)
+		Expect(s.recordScheduledBackups(context.Background(), []string{"worlds_local-20240102-000000.zip"})).To(Succeed())
+		Expect(recorded(s)).To(ConsistOf("worlds_local-20240102-000000.zip", "worlds_local-20231231-000000.zip"))
+	})
+
+	ginkgo.It("keeps the backups older than a cut off index", func() {
+		var archives []string
+		for i := backupIndexLimit; i > 0; i-- {
+			archives = append(archives, fmt.Sprintf("worlds_local-20240101-%06d.zip", i))
+		}
+		s := scope(
+			backup("world-20231231-000000", "worlds_local-20231231-000000.zip", true),
+			backup("world-20240101-999999", "worlds_local-20240101-999999.zip", true),
+		)
+		Expect(s.recordScheduledBackups(context.Background(), archives)).To(Succeed())
+		Expect(recorded(s)).To(ContainElement("worlds_local-20231231-000000.zip"))
+		Expect(recorded(s)).NotTo(ContainElement("worlds_local-20240101-999999.zip"))
+	})
+})
@@ -0,0 +1,473 @@
+package valheim
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"github.com/robwittman/gamely/api/v1alpha2"
+	"github.com/robwittman/gamely/internal/thunderstore"
+	"github.com/robwittman/gamely/internal/util"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// EventReasonModsResolved and EventReasonModResolutionFailed track
+	// resolving the requested mod packages and their dependencies
+	EventReasonModsResolved        = "ModsResolved"
+	EventReasonModResolutionFailed = "ModResolutionFailed"
+
+	// bepInExPackage is the BepInEx pack nearly every Valheim mod depends on.
+	// The server image installs it itself when BepInEx is enabled.
+	bepInExPackage = "denikson/BepInExPack_Valheim"
+
+	// modResolveBackoff is how long a failed resolution waits before it is
+	// retried, doubling with every failure up to modResolveMaxBackoff
+	modResolveBackoff    = time.Second * 30
+	modResolveMaxBackoff = time.Minute * 30
+
+	// EventReasonModsChanged is emitted with the packages added, removed or
+	// changed in version when the set of mods to install changes
+	EventReasonModsChanged = "ModsChanged"
+
+	// AnnotationModSet records the set of mods a server pod was started with,
+	// so the server restarts to sync its mods when the set changes
+	AnnotationModSet = "gamely.io/mod-set"
+
+	// ModCacheClaim is the volume claim of the mod cache shared by every
+	// Valheim in a namespace
+	ModCacheClaim = "gamely-mod-cache"
+	// modCacheDirectory is where the mod downloader mounts the mod cache
+	modCacheDirectory = "/cache"
+)
+
+// modDownloadScript syncs MOD_PATH to the registry, one package:version:sha256
+// per line. Packages listed in sources.txt are downloaded from their url, or
+// read from a file their ModSource provides. Every package installed gets a
+// manifest of its files, so packages that are removed or change version have
+// their files deleted rather than left behind, except for files a package that
+// stays installed also ships. Archives are kept in the cache by their
+// checksum, so they are only downloaded when no server in the namespace has
+// fetched them before. Archives are verified against the checksum of the
+// registry or, when there is none yet, the one first recorded in the cache.
+// The checksums installed are written to the termination message.
+const modDownloadScript = `
+set -eu
+manifests="${MOD_PATH}/.gamely/manifests"
+staging="${MOD_PATH}/.gamely/staging"
+mkdir -p "${CACHE_PATH}/sha256" "${CACHE_PATH}/versions" "${CACHE_PATH}/tmp" "${manifests}"
+: > /tmp/checksums
+: > /tmp/kept
+: > /tmp/stale
+
+# source_of prints the kind and location of a package from another source
+source_of() {
+  awk -v package="$1" '$1 == package { print $2, $3; exit }' /config/mods/sources.txt
+}
+
+for manifest in "${manifests}"/*; do
+  [ -f "${manifest}" ] || continue
+  IFS=: read -r package version checksum < "${manifest}"
+  # Files from other sources may change under the same version, so they are always reinstalled
+  if [ "$(source_of "${package}" | cut -d ' ' -f 1)" = "file" ]; then
+    echo "${manifest}" >> /tmp/stale
+  elif grep -qxF -e "${package}:${version}:${checksum}" -e "${package}:${version}:" /config/mods/registry.txt; then
+    tail -n +2 "${manifest}" >> /tmp/kept
+    echo "${package}:${version}:${checksum}" >> /tmp/checksums
+  else
+    echo "${manifest}" >> /tmp/stale
+  fi
+done
+
+while IFS= read -r manifest; do
+  IFS=: read -r package version checksum < "${manifest}"
+  echo "Removing ${package} at version ${version}"
+  tail -n +2 "${manifest}" | while IFS= read -r file; do
+    grep -qxF "${file}" /tmp/kept || rm -f "${MOD_PATH}/${file}"
+  done
+  rm -f "${manifest}"
+done < /tmp/stale
+
+while IFS=: read -r package version checksum || [ -n "${package:-}" ]; do
+  [ -n "${package}" ] || continue
+  manifest="${manifests}/$(echo "${package}" | tr / -)"
+  if [ -f "${manifest}" ]; then
+    continue
+  fi
+
+  kind=thunderstore
+  location="${THUNDERSTORE_URL}/package/download/${package}/${version}/"
+  source="$(source_of "${package}")"
+  if [ -n "${source}" ]; then
+    kind="${source%% *}"
+    location="${source#* }"
+  fi
+
+  if [ "${kind}" = "file" ]; then
+    archive="${location}"
+    if [ -d "${archive}" ]; then
+      archive="$(find "${archive}" -type f | head -n 1)"
+    fi
+    if [ ! -f "${archive}" ]; then
+      echo "package ${package} was not found at ${location}" | tee /dev/termination-log
+      exit 1
+    fi
+    if [ -z "${checksum}" ]; then
+      checksum="$(sha256sum "${archive}" | cut -d ' ' -f 1)"
+    fi
+  else
+    index="${CACHE_PATH}/versions/$(echo "${package}" | tr / -)-${version}"
+    if [ -z "${checksum}" ] && [ -f "${index}" ]; then
+      checksum="$(cat "${index}")"
+    fi
+
+    archive="${CACHE_PATH}/sha256/${checksum}.zip"
+    if [ -n "${checksum}" ] && [ -f "${archive}" ]; then
+      echo "Using cached ${package} at version ${version}"
+    else
+      echo "Downloading ${package} at version ${version}"
+      download="$(mktemp "${CACHE_PATH}/tmp/download.XXXXXX")"
+      wget -q -O "${download}" "${location}"
+      if [ -z "${checksum}" ]; then
+        checksum="$(sha256sum "${download}" | cut -d ' ' -f 1)"
+      fi
+      archive="${CACHE_PATH}/sha256/${checksum}.zip"
+      mv "${download}" "${archive}"
+    fi
+  fi
+
+  actual="$(sha256sum "${archive}" | cut -d ' ' -f 1)"
+  if [ "${actual}" != "${checksum}" ]; then
+    [ "${kind}" = "file" ] || rm -f "${archive}"
+    echo "checksum mismatch for ${package} ${version}: expected ${checksum}, got ${actual}" | tee /dev/termination-log
+    exit 1
+  fi
+  if [ "${kind}" != "file" ]; then
+    echo "${checksum}" > "${index}.tmp" && mv "${index}.tmp" "${index}"
+  fi
+
+  echo "Installing ${package} at version ${version}"
+  rm -rf "${staging}"
+  mkdir -p "${staging}"
+  if unzip -l "${archive}" > /dev/null 2>&1; then
+    unzip -q "${archive}" -d "${staging}"
+  else
+    # A single DLL is installed as a plugin
+    mkdir -p "${staging}/plugins"
+    cp "${archive}" "${staging}/plugins/$(basename "${archive}")"
+  fi
+  {
+    echo "${package}:${version}:${checksum}"
+    (cd "${staging}" && find . -type f) | sed 's#^\./##'
+  } > "${manifest}.tmp"
+  cp -a "${staging}/." "${MOD_PATH}/"
+  mv "${manifest}.tmp" "${manifest}"
+  rm -rf "${staging}"
+  echo "${package}:${version}:${checksum}" >> /tmp/checksums
+done < /config/mods/registry.txt
+cat /tmp/checksums > /dev/termination-log
+`
+
+// resolveMods locks the requested packages and their dependencies into
+// status.mods, once for every generation of the Valheim. When resolution
+// fails the last lock is kept, so the server keeps the mods it had, and it is
+// retried with a backoff. The failure is reported once for every generation.
+func (s *Scope) resolveMods(ctx context.Context) {
+	if s.Thunderstore == nil {
+		return
+	}
+	mods := &s.Valheim.Status.Mods
+	if mods.ResolvedGeneration == s.Valheim.Generation {
+		return
+	}
+	if mods.FailedGeneration == s.Valheim.Generation && mods.LastFailureTime != nil {
+		if wait := time.Until(mods.LastFailureTime.Add(modResolveRetryDelay(mods.Failures))); wait > 0 {
+			s.modsErr = fmt.Errorf("%s", mods.FailureMessage)
+			s.modsRetryAfter = wait
+			return
+		}
+	}
+
+	// Packages from other sources stand in for any Thunderstore package of
+	// the same name that is depended on
+	requested := map[string]string{}
+	resolver := &thunderstore.Resolver{Client: s.Thunderstore, Provided: map[string]bool{}}
+	for pkg, conf := range s.Valheim.Spec.Mods.Packages {
+		if conf.Source != nil {
+			resolver.Provided[pkg] = true
+			continue
+		}
+		requested[pkg] = conf.Version
+	}
+	if s.Valheim.Spec.Mods.Framework == "bepinex" {
+		resolver.Provided[bepInExPackage] = true
+	}
+
+	resolved, err := resolver.Resolve(ctx, requested)
+	if err != nil {
+		s.Logger.Error(err, "failed resolving mod packages")
+		s.modsErr = err
+		if mods.FailedGeneration != s.Valheim.Generation {
+			s.Recorder.Eventf(s.Valheim, v1.EventTypeWarning, EventReasonModResolutionFailed, "failed resolving mod packages: %s", err)
+			mods.FailedGeneration = s.Valheim.Generation
+			mods.Failures = 0
+		}
+		now := metav1.Now()
+		mods.Failures++
+		mods.LastFailureTime = &now
+		mods.FailureMessage = err.Error()
+		s.modsRetryAfter = modResolveRetryDelay(mods.Failures)
+		return
+	}
+
+	// Checksums carry over for versions that stay locked, unless the spec pins another
+	checksums := map[string]string{}
+	for _, pkg := range mods.Lock {
+		checksums[pkg.Package+":"+pkg.Version] = pkg.SHA256
+	}
+	for pkg, conf := range s.Valheim.Spec.Mods.Packages {
+		if conf.Version != "" && conf.SHA256 != "" {
+			checksums[pkg+":"+conf.Version] = conf.SHA256
+		}
+	}
+
+	lock := []v1alpha2.ValheimLockedMod{}
+	for _, pkg := range resolved {
+		lock = append(lock, v1alpha2.ValheimLockedMod{
+			Package:     pkg.Package,
+			Version:     pkg.Version,
+			DownloadURL: pkg.DownloadURL,
+			SHA256:      checksums[pkg.Package+":"+pkg.Version],
+		})
+	}
+	if !equality.Semantic.DeepEqual(lock, mods.Lock) {
+		s.Recorder.Eventf(s.Valheim, v1.EventTypeNormal, EventReasonModsResolved, "resolved %d requested mod packages to %d packages", len(requested), len(lock))
+	}
+	mods.Lock = lock
+	mods.ResolvedGeneration = s.Valheim.Generation
+	mods.FailedGeneration = 0
+	mods.Failures = 0
+	mods.LastFailureTime = nil
+	mods.FailureMessage = ""
+}
+
+// modResolveRetryDelay is how long to wait before resolving again after the
+// given number of failures
+func modResolveRetryDelay(failures int32) time.Duration {
+	delay := modResolveBackoff
+	for i := int32(1); i < failures && delay < modResolveMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > modResolveMaxBackoff {
+		return modResolveMaxBackoff
+	}
+	return delay
+}
+
+// modRegistry lists the packages the mod downloader installs as
+// package:version:sha256, where the checksum may not be known yet. The lock
+// is used for Thunderstore packages once there is one.
+func (s *Scope) modRegistry() []string {
+	registry := []string{}
+	locked := s.Thunderstore != nil && len(s.Valheim.Status.Mods.Lock) > 0
+	if locked {
+		for _, pkg := range s.Valheim.Status.Mods.Lock {
+			registry = append(registry, pkg.Package+":"+pkg.Version+":"+pkg.SHA256)
+		}
+	}
+	for pkg, conf := range s.Valheim.Spec.Mods.Packages {
+		switch {
+		case conf.Source != nil:
+			registry = append(registry, pkg+":"+modVersion(conf)+":"+conf.SHA256)
+		case !locked:
+			registry = append(registry, pkg+":"+conf.Version+":"+conf.SHA256)
+		}
+	}
+	return registry
+}
+
+// modSourcePlan is how one package is fetched from its ModSource
+type modSourcePlan struct {
+	pkg  string
+	plan *ModSourcePlan
+}
+
+// modSourcePlans plans fetching every package that is not installed from
+// Thunderstore, in package order. Packages that cannot be planned are left
+// out and reported in the error.
+func (s *Scope) modSourcePlans() ([]modSourcePlan, error) {
+	packages := []string{}
+	for pkg, conf := range s.Valheim.Spec.Mods.Packages {
+		if conf.Source != nil {
+			packages = append(packages, pkg)
+		}
+	}
+	sort.Strings(packages)
+
+	plans := []modSourcePlan{}
+	failures := []string{}
+	keys := map[string]string{}
+	for i, pkg := range packages {
+		conf := s.Valheim.Spec.Mods.Packages[pkg]
+		source, err := modSourceFor(pkg, conf)
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		key := modSourceKey(pkg)
+		if owner, ok := keys[key]; ok {
+			failures = append(failures, fmt.Sprintf("packages %s and %s both install from %s, rename one of them", owner, pkg, key))
+			continue
+		}
+		keys[key] = pkg
+		plan, err := source.Plan(i, pkg, conf)
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		plans = append(plans, modSourcePlan{pkg: pkg, plan: plan})
+	}
+	if len(failures) > 0 {
+		return plans, fmt.Errorf("invalid mod sources: %s", strings.Join(failures, "; "))
+	}
+	return plans, nil
+}
+
+// modSourceList lists where the mod downloader gets packages from other
+// sources, one package, kind and location per line
+func modSourceList(plans []modSourcePlan) string {
+	lines := []string{}
+	for _, p := range plans {
+		lines = append(lines, fmt.Sprintf("%s %s %s", p.pkg, p.plan.Kind, p.plan.Location))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// recordModChecksums locks the checksums the mod downloader verified on first
+// download, so a changed archive is caught from then on
+func (s *Scope) recordModChecksums(pods []v1.Pod) {
+	downloaded := map[string]string{}
+	for _, pod := range pods {
+		for _, containerStatus := range pod.Status.InitContainerStatuses {
+			terminated := containerStatus.State.Terminated
+			if containerStatus.Name != modDownloaderContainer || terminated == nil || terminated.ExitCode != 0 {
+				continue
+			}
+			for _, line := range strings.Split(terminated.Message, "\n") {
+				parts := strings.Split(strings.TrimSpace(line), ":")
+				if len(parts) == 3 && parts[2] != "" {
+					downloaded[parts[0]+":"+parts[1]] = parts[2]
+				}
+			}
+		}
+	}
+
+	lock := s.Valheim.Status.Mods.Lock
+	for i := range lock {
+		if lock[i].SHA256 != "" {
+			continue
+		}
+		if checksum, ok := downloaded[lock[i].Package+":"+lock[i].Version]; ok {
+			lock[i].SHA256 = checksum
+		}
+	}
+}
+
+// reconcileModCache makes sure the shared mod cache exists and is owned by
+// this Valheim among others, so it is only collected once every server using
+// it is gone
+func (s *Scope) reconcileModCache(ctx context.Context, req ctrl.Request) (*v1.PersistentVolumeClaim, error) {
+	cache := s.Valheim.Spec.Mods.Cache
+	claim := &v1.PersistentVolumeClaim{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: ModCacheClaim}, claim); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+		claim, err = util.StorageVolume(req.Namespace, ModCacheClaim, &util.StorageVolumeOpts{
+			AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteMany},
+			StorageClassName: cache.Class,
+			Size:             cache.Size,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := controllerutil.SetOwnerReference(s.Valheim, claim, s.Client.Scheme()); err != nil {
+			return nil, err
+		}
+		if err := s.Client.Create(ctx, claim); err != nil {
+			return nil, err
+		}
+		return claim, nil
+	}
+
+	for _, owner := range claim.OwnerReferences {
+		if owner.UID == s.Valheim.UID {
+			return claim, nil
+		}
+	}
+	patch := client.MergeFromWithOptions(claim.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	if err := controllerutil.SetOwnerReference(s.Valheim, claim, s.Client.Scheme()); err != nil {
+		return nil, err
+	}
+	if err := s.Client.Patch(ctx, claim, patch); err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// modVersions maps the packages of a registry to their versions
+func modVersions(registry []string) map[string]string {
+	versions := map[string]string{}
+	for _, line := range registry {
+		parts := strings.Split(line, ":")
+		if len(parts) >= 2 && parts[0] != "" {
+			versions[parts[0]] = parts[1]
+		}
+	}
+	return versions
+}
+
+// modSetHash identifies the packages and versions of a registry, leaving out
+// checksums so recording them does not restart the server
+func modSetHash(registry []string) string {
+	versions := modVersions(registry)
+	packages := make([]string, 0, len(versions))
+	for pkg, version := range versions {
+		packages = append(packages, pkg+":"+version)
+	}
+	sort.Strings(packages)
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(packages, "\n"))))
+}
+
+// modRegistryDiff describes how the packages of registry changed from previous,
+// or returns nothing when only checksums changed
+func modRegistryDiff(previous []string, registry []string) string {
+	before := modVersions(previous)
+	after := modVersions(registry)
+
+	changes := []string{}
+	for pkg, version := range after {
+		switch old, ok := before[pkg]; {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("added %s %s", pkg, version))
+		case old != version:
+			changes = append(changes, fmt.Sprintf("changed %s %s to %s", pkg, old, version))
+		}
+	}
+	for pkg, version := range before {
+		if _, ok := after[pkg]; !ok {
+			changes = append(changes, fmt.Sprintf("removed %s %s", pkg, version))
+		}
+	}
+	sort.Strings(changes)
+	return strings.Join(changes, ", ")
+}
@@ -0,0 +1,217 @@
+package valheim
+
+import (
+	"fmt"
+	"github.com/robwittman/gamely/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	"path"
+	"strings"
+)
+
+const (
+	// modSourcesDirectory is where the mod downloader finds packages that are
+	// not installed from Thunderstore
+	modSourcesDirectory = "/sources"
+	// ociModsDirectory is where OCI artifacts are pulled to
+	ociModsDirectory = modSourcesDirectory + "/oci"
+
+	// OCIPullerImage pulls mod packages published as OCI artifacts
+	OCIPullerImage = "ghcr.io/oras-project/oras:v1.1.0"
+
+	// unversionedMod is the version recorded for packages from a source that
+	// have no version in the spec
+	unversionedMod = "unversioned"
+)
+
+// ModSource installs packages from somewhere other than Thunderstore. A
+// source tells the mod downloader where to fetch the package from, and adds
+// whatever volumes or init containers it needs to get it there.
+type ModSource interface {
+	// Handles reports whether mod is installed from this source
+	Handles(mod v1alpha2.ValheimModSpec) bool
+	// Plan describes how the mod downloader gets package. index is unique
+	// among the packages of the server, for naming volumes and containers.
+	Plan(index int, pkg string, mod v1alpha2.ValheimModSpec) (*ModSourcePlan, error)
+}
+
+// ModSourcePlan is how the mod downloader gets a package from a ModSource
+type ModSourcePlan struct {
+	// Kind is url for packages the mod downloader downloads itself, or file
+	// for packages the source puts in the mod downloader
+	Kind string
+	// Location is the URL to download, or the path of the file or of a
+	// directory holding only the file
+	Location string
+
+	Volumes        []v1.Volume
+	VolumeMounts   []v1.VolumeMount
+	InitContainers []v1.Container
+}
+
+// ModSources are the sources packages can be installed from besides Thunderstore
+var ModSources = []ModSource{
+	urlModSource{},
+	ociModSource{},
+	configMapModSource{},
+	secretModSource{},
+	volumeModSource{},
+}
+
+// modSourceFor finds the source mod is installed from, or nil for
+// Thunderstore. Exactly one source has to handle a mod with a source set.
+func modSourceFor(pkg string, mod v1alpha2.ValheimModSpec) (ModSource, error) {
+	if mod.Source == nil {
+		return nil, nil
+	}
+	var found ModSource
+	for _, source := range ModSources {
+		if !source.Handles(mod) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("package %s has more than one source set", pkg)
+		}
+		found = source
+	}
+	if found == nil {
+		return nil, fmt.Errorf("package %s has no source set", pkg)
+	}
+	return found, nil
+}
+
+// modVersion is the version recorded for a package from a source
+func modVersion(mod v1alpha2.ValheimModSpec) string {
+	if mod.Version == "" {
+		return unversionedMod
+	}
+	return mod.Version
+}
+
+// modSourceKey names the directory the package is put in for the mod
+// downloader, which packages differing only in case or punctuation share
+func modSourceKey(pkg string) string {
+	return strings.ToLower(strings.NewReplacer("/", "-", "_", "-", ".", "-").Replace(pkg))
+}
+
+type urlModSource struct{}
+
+func (urlModSource) Handles(mod v1alpha2.ValheimModSpec) bool {
+	return mod.Source.URL != nil
+}
+
+func (urlModSource) Plan(index int, pkg string, mod v1alpha2.ValheimModSpec) (*ModSourcePlan, error) {
+	if mod.SHA256 == "" {
+		return nil, fmt.Errorf("package %s is downloaded from a url, which needs a sha256", pkg)
+	}
+	return &ModSourcePlan{Kind: "url", Location: mod.Source.URL.URL}, nil
+}
+
+type ociModSource struct{}
+
+func (ociModSource) Handles(mod v1alpha2.ValheimModSpec) bool {
+	return mod.Source.OCI != nil
+}
+
+func (ociModSource) Plan(index int, pkg string, mod v1alpha2.ValheimModSpec) (*ModSourcePlan, error) {
+	oci := mod.Source.OCI
+	directory := path.Join(ociModsDirectory, modSourceKey(pkg))
+	container := v1.Container{
+		Name:         fmt.Sprintf("mod-source-%d", index),
+		Image:        OCIPullerImage,
+		Args:         []string{"pull", oci.Reference, "--output", directory},
+		VolumeMounts: []v1.VolumeMount{{Name: "mod-sources", MountPath: ociModsDirectory}},
+	}
+
+	plan := &ModSourcePlan{Kind: "file", Location: directory}
+	if oci.PullSecret != "" {
+		volume := fmt.Sprintf("mod-source-%d", index)
+		plan.Volumes = append(plan.Volumes, v1.Volume{
+			Name: volume,
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: oci.PullSecret,
+					Items:      []v1.KeyToPath{{Key: v1.DockerConfigJsonKey, Path: "config.json"}},
+				},
+			},
+		})
+		container.Args = append(container.Args, "--registry-config", "/auth/config.json")
+		container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{Name: volume, MountPath: "/auth", ReadOnly: true})
+	}
+	plan.InitContainers = append(plan.InitContainers, container)
+	return plan, nil
+}
+
+type configMapModSource struct{}
+
+func (configMapModSource) Handles(mod v1alpha2.ValheimModSpec) bool {
+	return mod.Source.ConfigMap != nil
+}
+
+func (configMapModSource) Plan(index int, pkg string, mod v1alpha2.ValheimModSpec) (*ModSourcePlan, error) {
+	selector := mod.Source.ConfigMap
+	return keyModSourcePlan(index, pkg, selector.Key, v1.VolumeSource{
+		ConfigMap: &v1.ConfigMapVolumeSource{
+			LocalObjectReference: selector.LocalObjectReference,
+			Items:                []v1.KeyToPath{{Key: selector.Key, Path: path.Base(selector.Key)}},
+		},
+	}), nil
+}
+
+type secretModSource struct{}
+
+func (secretModSource) Handles(mod v1alpha2.ValheimModSpec) bool {
+	return mod.Source.Secret != nil
+}
+
+func (secretModSource) Plan(index int, pkg string, mod v1alpha2.ValheimModSpec) (*ModSourcePlan, error) {
+	selector := mod.Source.Secret
+	return keyModSourcePlan(index, pkg, selector.Key, v1.VolumeSource{
+		Secret: &v1.SecretVolumeSource{
+			SecretName: selector.Name,
+			Items:      []v1.KeyToPath{{Key: selector.Key, Path: path.Base(selector.Key)}},
+		},
+	}), nil
+}
+
+// keyModSourcePlan mounts the key of a ConfigMap or Secret into the mod downloader
+func keyModSourcePlan(index int, pkg string, key string, source v1.VolumeSource) *ModSourcePlan {
+	volume := fmt.Sprintf("mod-source-%d", index)
+	directory := path.Join(modSourcesDirectory, modSourceKey(pkg))
+	return &ModSourcePlan{
+		Kind:         "file",
+		Location:     path.Join(directory, path.Base(key)),
+		Volumes:      []v1.Volume{{Name: volume, VolumeSource: source}},
+		VolumeMounts: []v1.VolumeMount{{Name: volume, MountPath: directory, ReadOnly: true}},
+	}
+}
+
+type volumeModSource struct{}
+
+func (volumeModSource) Handles(mod v1alpha2.ValheimModSpec) bool {
+	return mod.Source.Volume != nil
+}
+
+func (volumeModSource) Plan(index int, pkg string, mod v1alpha2.ValheimModSpec) (*ModSourcePlan, error) {
+	source := mod.Source.Volume
+	volume := fmt.Sprintf("mod-source-%d", index)
+	location := path.Join(modSourcesDirectory, modSourceKey(pkg), path.Base(source.Path))
+	return &ModSourcePlan{
+		Kind:     "file",
+		Location: location,
+		Volumes: []v1.Volume{{
+			Name: volume,
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+					ClaimName: source.ClaimName,
+					ReadOnly:  true,
+				},
+			},
+		}},
+		VolumeMounts: []v1.VolumeMount{{
+			Name:      volume,
+			MountPath: location,
+			SubPath:   strings.TrimPrefix(source.Path, "/"),
+			ReadOnly:  true,
+		}},
+	}, nil
+}
@@ -0,0 +1,55 @@
+package valheim
+
+import (
+	"github.com/go-logr/logr"
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/robwittman/gamely/api/v1alpha2"
+)
+
+var _ = ginkgo.Describe("modSourcePlans", func() {
+	oci := &v1alpha2.ValheimModOCISource{Reference: "registry.example.com/mods/mymod:1.0.0"}
+	url := &v1alpha2.ValheimModURLSource{URL: "https://example.com/mymod.zip"}
+
+	plans := func(packages map[string]v1alpha2.ValheimModSpec) ([]modSourcePlan, error) {
+		s := &Scope{
+			Logger: logr.Discard(),
+			Valheim: &v1alpha2.Valheim{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world"},
+				Spec:       v1alpha2.ValheimSpec{Mods: v1alpha2.ValheimModsSpec{Enabled: true, Packages: packages}},
+			},
+		}
+		return s.modSourcePlans()
+	}
+
+	ginkgo.It("plans every package with a source, in package order", func() {
+		planned, err := plans(map[string]v1alpha2.ValheimModSpec{
+			"b/Pulled":     {Source: &v1alpha2.ValheimModSource{OCI: oci}},
+			"a/Downloaded": {SHA256: "0000000000000000000000000000000000000000000000000000000000000000", Source: &v1alpha2.ValheimModSource{URL: url}},
+			"c/Thunder":    {Version: "1.0.0"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(modSourceList(planned)).To(Equal("a/Downloaded url https://example.com/mymod.zip\nb/Pulled file /sources/oci/b-pulled"))
+	})
+
+	ginkgo.DescribeTable("reports packages it cannot plan",
+		func(packages map[string]v1alpha2.ValheimModSpec, message string, planned int) {
+			p, err := plans(packages)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+			Expect(p).To(HaveLen(planned))
+		},
+		ginkgo.Entry("with no source set", map[string]v1alpha2.ValheimModSpec{
+			"a/Empty": {Source: &v1alpha2.ValheimModSource{}},
+		}, "package a/Empty has no source set", 0),
+		ginkgo.Entry("with two sources set", map[string]v1alpha2.ValheimModSpec{
+			"a/Both": {SHA256: "0000000000000000000000000000000000000000000000000000000000000000", Source: &v1alpha2.ValheimModSource{URL: url, OCI: oci}},
+		}, "package a/Both has more than one source set", 0),
+		ginkgo.Entry("with names sharing a source directory", map[string]v1alpha2.ValheimModSpec{
+			"a/My_Mod": {Source: &v1alpha2.ValheimModSource{OCI: oci}},
+			"a/My.Mod": {Source: &v1alpha2.ValheimModSource{OCI: oci}},
+		}, "packages a/My.Mod and a/My_Mod both install from a-my-mod", 1),
+	)
+})
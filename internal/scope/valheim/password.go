@@ -0,0 +1,315 @@
+package valheim
+
+import (
+	"context"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"github.com/robwittman/gamely/api/v1alpha2"
+	"github.com/robwittman/gamely/internal/util"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"time"
+)
+
+const (
+	// AnnotationPasswordRotatedAt records when the password in a Secret was
+	// last rotated. It is copied to the server pod template, so the server
+	// restarts to read the new password.
+	AnnotationPasswordRotatedAt = "gamely.io/password-rotated-at"
+
+	// EventReasonPasswordGenerated and EventReasonPasswordRotated are emitted
+	// when a server password is generated or replaced
+	EventReasonPasswordGenerated = "PasswordGenerated"
+	EventReasonPasswordRotated   = "PasswordRotated"
+
+	// generatedPasswordLabel is the value of the gamely.io label on Secrets
+	// holding generated passwords, the only Secrets named after a server
+	// that it takes ownership of
+	generatedPasswordLabel = "valheim-password"
+)
+
+// GeneratePassword generates a password for the server under its password policy
+func GeneratePassword(valheim *v1alpha2.Valheim) (string, error) {
+	policy := valheim.Spec.Server.PasswordPolicy
+	return util.GeneratePassword(policy.GetLength(), policy.GetCharset().Characters(), v1alpha2.MinPasswordLength, valheim.GetServerName())
+}
+
+// passwordError is why the Secret key holding the server password cannot be used
+type passwordError struct {
+	reason  string
+	message string
+}
+
+func (e *passwordError) Error() string {
+	return e.message
+}
+
+// passwordSource is the Secret key holding the server password. It is
+// generated in a Secret named after the server when the spec names none.
+func (s *Scope) passwordSource() v1alpha2.ValheimSecretKeySelector {
+	password := s.Valheim.Spec.Server.Password
+	if password == nil {
+		return v1alpha2.ValheimSecretKeySelector{
+			Name:      s.Valheim.Name,
+			Key:       v1alpha2.DefaultPasswordKey,
+			Namespace: s.Valheim.Namespace,
+		}
+	}
+	source := *password
+	source.Key = password.GetKey()
+	if source.Namespace == "" {
+		source.Namespace = s.Valheim.Namespace
+	}
+	return source
+}
+
+// passwordSelector is the Secret key the server reads its password from. A
+// password in another namespace is read from the copy next to the server.
+func (s *Scope) passwordSelector() *v1.SecretKeySelector {
+	source := s.passwordSource()
+	name := source.Name
+	if source.Namespace != s.Valheim.Namespace {
+		name = s.Valheim.Name + "-password"
+	}
+	return &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: name},
+		Key:                  source.Key,
+	}
+}
+
+// reconcilePassword checks the Secret key holding the server password exists,
+// generating it when the spec names none and copying it next to the server
+// when it is in another namespace. The spec is never written to, the Secret
+// in use is reported in status.passwordSecretRef instead.
+func (s *Scope) reconcilePassword(ctx context.Context, req ctrl.Request) error {
+	source := s.passwordSource()
+	if s.Valheim.Spec.Server.Password == nil {
+		if err := s.reconcileGeneratedPassword(ctx, source); err != nil {
+			return err
+		}
+		if s.passwordErr != nil {
+			s.Valheim.Status.PasswordSecretRef = nil
+			return nil
+		}
+	}
+	s.Valheim.Status.PasswordSecretRef = &source
+
+	secret := &v1.Secret{}
+	err := s.Client.Get(ctx, types.NamespacedName{Namespace: source.Namespace, Name: source.Name}, secret)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if source.Namespace != req.Namespace && (err != nil || !v1alpha2.PasswordSecretAllowed(secret, req.Namespace)) {
+		// Secrets of other namespaces that are missing are reported the
+		// same as those that are not allowed, so servers cannot be used to
+		// find out which Secrets exist
+		s.passwordErr = &passwordError{
+			reason:  "NotAllowed",
+			message: fmt.Sprintf("password secret %s/%s does not allow namespace %s in annotation %s", source.Namespace, source.Name, req.Namespace, v1alpha2.AnnotationPasswordNamespaces),
+		}
+		return s.deletePasswordCopy(ctx, req)
+	}
+	if err != nil {
+		s.passwordErr = &passwordError{
+			reason:  "SecretNotFound",
+			message: fmt.Sprintf("password secret %s/%s was not found", source.Namespace, source.Name),
+		}
+		return nil
+	}
+	value, ok := secret.Data[source.Key]
+	if !ok {
+		s.passwordErr = &passwordError{
+			reason:  "KeyNotFound",
+			message: fmt.Sprintf("password secret %s/%s has no key %s", source.Namespace, source.Name, source.Key),
+		}
+		return nil
+	}
+	if err := util.ValidatePassword(string(value), v1alpha2.MinPasswordLength, s.Valheim.GetServerName()); err != nil {
+		s.passwordErr = &passwordError{
+			reason:  "InvalidPassword",
+			message: fmt.Sprintf("password in key %s of secret %s/%s is not accepted by Valheim: %s", source.Key, source.Namespace, source.Name, err),
+		}
+		return nil
+	}
+
+	if source.Namespace == req.Namespace {
+		return nil
+	}
+	copied := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: req.Namespace,
+			Name:      s.passwordSelector().Name,
+		},
+		Data: map[string][]byte{source.Key: value},
+	}
+	_, err = s.apply(ctx, copied)
+	return err
+}
+
+// deletePasswordCopy deletes the copy of a password from another namespace
+// that the server may no longer read
+func (s *Scope) deletePasswordCopy(ctx context.Context, req ctrl.Request) error {
+	copied := &v1.Secret{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: s.passwordSelector().Name}, copied); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !s.ownsSecret(copied) {
+		return nil
+	}
+	if err := s.Client.Delete(ctx, copied); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// reconcileGeneratedPassword creates the Secret holding a generated server
+// password, owned by the server so it is deleted along with it. Existing
+// Secrets are only adopted when they are labeled as generated or already
+// owned by the server, any other Secret of that name belongs to the user and
+// is reported rather than overwritten, rotated or deleted with the server.
+func (s *Scope) reconcileGeneratedPassword(ctx context.Context, source v1alpha2.ValheimSecretKeySelector) error {
+	secret := &v1.Secret{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: source.Namespace, Name: source.Name}, secret); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		value, err := GeneratePassword(s.Valheim)
+		if err != nil {
+			return err
+		}
+		secret = &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: source.Namespace,
+				Name:      source.Name,
+				Labels:    map[string]string{"gamely.io": generatedPasswordLabel},
+			},
+			Data: map[string][]byte{source.Key: []byte(value)},
+		}
+		if err := controllerutil.SetOwnerReference(s.Valheim, secret, s.Client.Scheme()); err != nil {
+			return err
+		}
+		if err := s.Client.Create(ctx, secret); err != nil {
+			return err
+		}
+		s.Recorder.Eventf(s.Valheim, v1.EventTypeNormal, EventReasonPasswordGenerated, "generated server password in secret %s", secret.Name)
+		return nil
+	}
+
+	if secret.Labels["gamely.io"] != generatedPasswordLabel && !s.ownsSecret(secret) {
+		s.passwordErr = &passwordError{
+			reason: "SecretConflict",
+			message: fmt.Sprintf("secret %s was not generated for this server, so no password is generated in its place. "+
+				"Set spec.server.password to use it, delete it to have a password generated, or label it gamely.io=%s to have it managed as a generated password",
+				secret.Name, generatedPasswordLabel),
+		}
+		return nil
+	}
+
+	adopted := secret.DeepCopy()
+	if adopted.Labels == nil {
+		adopted.Labels = map[string]string{}
+	}
+	adopted.Labels["gamely.io"] = generatedPasswordLabel
+	if err := controllerutil.SetOwnerReference(s.Valheim, adopted, s.Client.Scheme()); err != nil {
+		return err
+	}
+	if equality.Semantic.DeepEqual(adopted.ObjectMeta, secret.ObjectMeta) {
+		return nil
+	}
+	return s.Client.Patch(ctx, adopted, client.MergeFrom(secret))
+}
+
+// ownsSecret reports whether the server owns secret
+func (s *Scope) ownsSecret(secret *v1.Secret) bool {
+	for _, owner := range secret.OwnerReferences {
+		if owner.UID == s.Valheim.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcilePasswordRotation replaces the server password on the rotation
+// schedule, which is the quiet hour the server may restart in. Passwords are
+// left alone while a restore is running, and only generated ones are rotated.
+// Returns how long to wait until the next rotation is due.
+func (s *Scope) reconcilePasswordRotation(ctx context.Context, req ctrl.Request) (time.Duration, error) {
+	rotation := s.Valheim.Spec.Server.PasswordRotation
+	if rotation == nil || s.passwordErr != nil {
+		return 0, nil
+	}
+	// Secrets supplied by the user are theirs to rotate, and writing to them
+	// would fight whatever manages them
+	if s.Valheim.Spec.Server.Password != nil {
+		return 0, nil
+	}
+	source := s.passwordSource()
+	schedule, err := cron.ParseStandard(rotation.Schedule)
+	if err != nil {
+		s.Logger.Error(err, "invalid password rotation schedule, not rotating the server password")
+		return 0, nil
+	}
+
+	secret := &v1.Secret{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: source.Name}, secret); err != nil {
+		return 0, err
+	}
+	last := secret.GetCreationTimestamp().Time
+	if rotatedAt, ok := secret.Annotations[AnnotationPasswordRotatedAt]; ok {
+		s.passwordRotatedAt = rotatedAt
+		if t, err := time.Parse(time.RFC3339, rotatedAt); err == nil {
+			last = t
+		}
+	}
+	if wait := time.Until(schedule.Next(last)); wait > 0 {
+		return wait, nil
+	}
+	if s.Valheim.Restoring() != "" {
+		return 0, nil
+	}
+
+	value, err := GeneratePassword(s.Valheim)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[AnnotationPasswordRotatedAt] = now.Format(time.RFC3339)
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[source.Key] = []byte(value)
+	if err := s.Client.Update(ctx, secret); err != nil {
+		return 0, err
+	}
+	s.passwordRotatedAt = secret.Annotations[AnnotationPasswordRotatedAt]
+	s.Recorder.Eventf(s.Valheim, v1.EventTypeNormal, EventReasonPasswordRotated, "rotated password in secret %s, now at resourceVersion %s", secret.Name, secret.ResourceVersion)
+	return time.Until(schedule.Next(now)), nil
+}
+
+// setPasswordCondition reports whether the server password can be read
+func (s *Scope) setPasswordCondition() {
+	if s.passwordErr != nil {
+		s.setCondition(v1alpha2.ConditionPasswordReady, metav1.ConditionFalse, s.passwordErr.reason, s.passwordErr.message)
+		return
+	}
+	selector := s.passwordSelector()
+	// Servers stored before rotation was limited to generated passwords can
+	// still name a password next to a rotation, which is never applied
+	if s.Valheim.Spec.Server.Password != nil && s.Valheim.Spec.Server.PasswordRotation != nil {
+		s.setCondition(v1alpha2.ConditionPasswordReady, metav1.ConditionTrue, "RotationIgnored", fmt.Sprintf("password is read from key %s of secret %s, which is not rotated because only generated passwords are; unset password to have one generated, or rotate the secret yourself", selector.Key, selector.Name))
+		return
+	}
+	s.setCondition(v1alpha2.ConditionPasswordReady, metav1.ConditionTrue, "SecretFound", fmt.Sprintf("password is read from key %s of secret %s", selector.Key, selector.Name))
+}
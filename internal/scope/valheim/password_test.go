@@ -0,0 +1,147 @@
+package valheim
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/robwittman/gamely/api/v1alpha2"
+)
+
+var _ = ginkgo.Describe("reconcilePassword", func() {
+	var (
+		server *v1alpha2.Valheim
+		req    ctrl.Request
+	)
+
+	ginkgo.BeforeEach(func() {
+		server = &v1alpha2.Valheim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world", UID: "world-uid"},
+		}
+		req = ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "world"}}
+	})
+
+	scope := func(objects ...client.Object) *Scope {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(v1alpha2.AddToScheme(scheme)).To(Succeed())
+		return &Scope{
+			Logger:   logr.Discard(),
+			Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+			Recorder: record.NewFakeRecorder(10),
+			Valheim:  server,
+		}
+	}
+
+	secret := func(s *Scope) *v1.Secret {
+		secret := &v1.Secret{}
+		Expect(s.Client.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "world"}, secret)).To(Succeed())
+		return secret
+	}
+
+	ready := func(s *Scope) *metav1.Condition {
+		s.setPasswordCondition()
+		return meta.FindStatusCondition(s.Valheim.Status.Conditions, v1alpha2.ConditionPasswordReady)
+	}
+
+	ginkgo.It("generates a labeled secret owned by the server", func() {
+		s := scope()
+		Expect(s.reconcilePassword(context.Background(), req)).To(Succeed())
+
+		generated := secret(s)
+		Expect(generated.Labels).To(HaveKeyWithValue("gamely.io", generatedPasswordLabel))
+		Expect(generated.OwnerReferences).To(ConsistOf(HaveField("UID", server.UID)))
+		Expect(generated.Data).To(HaveKey(v1alpha2.DefaultPasswordKey))
+		Expect(server.Status.PasswordSecretRef).To(Equal(&v1alpha2.ValheimSecretKeySelector{Name: "world", Key: "password", Namespace: "default"}))
+		Expect(ready(s).Status).To(Equal(metav1.ConditionTrue))
+	})
+
+	ginkgo.It("adopts a secret labeled as generated", func() {
+		s := scope(&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world", Labels: map[string]string{"gamely.io": generatedPasswordLabel}},
+			Data:       map[string][]byte{"password": []byte("hunter22")},
+		})
+		Expect(s.reconcilePassword(context.Background(), req)).To(Succeed())
+
+		adopted := secret(s)
+		Expect(adopted.OwnerReferences).To(ConsistOf(HaveField("UID", server.UID)))
+		Expect(adopted.Data).To(HaveKeyWithValue("password", []byte("hunter22")))
+		Expect(ready(s).Status).To(Equal(metav1.ConditionTrue))
+	})
+
+	ginkgo.It("labels a secret the server already owns", func() {
+		owned := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world"},
+			Data:       map[string][]byte{"password": []byte("hunter22")},
+		}
+		owned.OwnerReferences = []metav1.OwnerReference{{APIVersion: v1alpha2.GroupVersion.String(), Kind: "Valheim", Name: "world", UID: server.UID}}
+		s := scope(owned)
+		Expect(s.reconcilePassword(context.Background(), req)).To(Succeed())
+		Expect(secret(s).Labels).To(HaveKeyWithValue("gamely.io", generatedPasswordLabel))
+	})
+
+	ginkgo.It("reports a secret of the user instead of taking it over", func() {
+		s := scope(&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world"},
+			Data:       map[string][]byte{"password": []byte("hunter22")},
+		})
+		Expect(s.reconcilePassword(context.Background(), req)).To(Succeed())
+
+		untouched := secret(s)
+		Expect(untouched.OwnerReferences).To(BeEmpty())
+		Expect(untouched.Labels).To(BeEmpty())
+		Expect(untouched.Data).To(HaveKeyWithValue("password", []byte("hunter22")))
+		Expect(server.Status.PasswordSecretRef).To(BeNil())
+
+		condition := ready(s)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("SecretConflict"))
+	})
+
+	ginkgo.It("does not rotate a secret of the user", func() {
+		server.Spec.Server.PasswordRotation = &v1alpha2.ValheimPasswordRotationSpec{Schedule: "* * * * *"}
+		s := scope(&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "default",
+				Name:              "world",
+				CreationTimestamp: metav1.Now(),
+			},
+			Data: map[string][]byte{"password": []byte("hunter22")},
+		})
+		Expect(s.reconcilePassword(context.Background(), req)).To(Succeed())
+		after, err := s.reconcilePasswordRotation(context.Background(), req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(after).To(BeZero())
+		Expect(secret(s).Data).To(HaveKeyWithValue("password", []byte("hunter22")))
+	})
+
+	ginkgo.It("reports that the rotation of a named password is ignored", func() {
+		server.Spec.Server.Password = &v1alpha2.ValheimSecretKeySelector{Name: "world"}
+		server.Spec.Server.PasswordRotation = &v1alpha2.ValheimPasswordRotationSpec{Schedule: "* * * * *"}
+		s := scope(&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world"},
+			Data:       map[string][]byte{"password": []byte("hunter22")},
+		})
+		Expect(s.reconcilePassword(context.Background(), req)).To(Succeed())
+		after, err := s.reconcilePasswordRotation(context.Background(), req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(after).To(BeZero())
+		condition := ready(s)
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal("RotationIgnored"))
+		Expect(condition.Message).To(ContainSubstring("unset password"))
+	})
+})
@@ -0,0 +1,145 @@
+package valheim
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/robwittman/gamely/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// EventReasonPluginConfigInvalid is emitted when the config of a plugin
+	// in the spec cannot be rendered
+	EventReasonPluginConfigInvalid = "PluginConfigInvalid"
+
+	// AnnotationPluginConfig records the checksum of the plugin config files a
+	// server pod was started with, so the server restarts when they change
+	AnnotationPluginConfig = "gamely.io/plugin-config"
+
+	// pluginConfigDirectory is where BepInEx plugins read their config files
+	// from, relative to the mod volume
+	pluginConfigDirectory = "config"
+)
+
+// pluginConfigFile is the name of the BepInEx config file of pkg
+func pluginConfigFile(pkg string, mod v1alpha2.ValheimModSpec) string {
+	if mod.ConfigFile != "" {
+		return mod.ConfigFile
+	}
+	return strings.Replace(pkg, "/", ".", -1) + ".cfg"
+}
+
+// pluginConfigFiles renders the config of every package into BepInEx config
+// files, keyed by file name. A package whose config cannot be rendered keeps
+// its file from previous, and is reported in the returned error.
+func pluginConfigFiles(packages map[string]v1alpha2.ValheimModSpec, previous map[string]string) (map[string]string, error) {
+	names := make([]string, 0, len(packages))
+	for pkg := range packages {
+		names = append(names, pkg)
+	}
+	sort.Strings(names)
+
+	files := map[string]string{}
+	owners := map[string]string{}
+	problems := []string{}
+	for _, pkg := range names {
+		mod := packages[pkg]
+		if mod.Config == "" && len(mod.Settings) == 0 {
+			continue
+		}
+		file := pluginConfigFile(pkg, mod)
+		if owner, ok := owners[file]; ok {
+			problems = append(problems, fmt.Sprintf("packages %s and %s both configure %s", owner, pkg, file))
+			continue
+		}
+		owners[file] = pkg
+
+		contents, err := renderPluginConfig(mod)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("package %s: %s", pkg, err))
+			if contents, ok := previous[file]; ok {
+				files[file] = contents
+			}
+			continue
+		}
+		files[file] = contents
+	}
+	if len(problems) > 0 {
+		return files, fmt.Errorf("invalid plugin config: %s", strings.Join(problems, ", "))
+	}
+	return files, nil
+}
+
+// renderPluginConfig writes the config of a package in the format of BepInEx
+// config files
+func renderPluginConfig(mod v1alpha2.ValheimModSpec) (string, error) {
+	if mod.Config != "" {
+		if len(mod.Settings) > 0 {
+			return "", fmt.Errorf("config and settings are exclusive")
+		}
+		return mod.Config, nil
+	}
+
+	sections := make([]string, 0, len(mod.Settings))
+	for section := range mod.Settings {
+		if section == "" || strings.ContainsAny(section, "[]\r\n") {
+			return "", fmt.Errorf("section %q is not a valid section name", section)
+		}
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+
+	var b strings.Builder
+	for i, section := range sections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "[%s]\n\n", section)
+
+		keys := make([]string, 0, len(mod.Settings[section]))
+		for key := range mod.Settings[section] {
+			if key == "" || strings.ContainsAny(key, "=\r\n") || strings.TrimSpace(key) != key {
+				return "", fmt.Errorf("setting %q in section [%s] is not a valid setting name", key, section)
+			}
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := mod.Settings[section][key]
+			if strings.ContainsAny(value, "\r\n") {
+				return "", fmt.Errorf("setting %s in section [%s] spans more than one line", key, section)
+			}
+			fmt.Fprintf(&b, "%s = %s\n", key, value)
+		}
+	}
+	return b.String(), nil
+}
+
+// pluginConfigMounts mounts each plugin config file from the mods ConfigMap
+// into the config directory of BepInEx, and returns the checksum of the files
+func (s *Scope) pluginConfigMounts(modPath string) ([]v1.VolumeMount, string) {
+	if len(s.pluginConfig) == 0 {
+		return nil, ""
+	}
+	files := make([]string, 0, len(s.pluginConfig))
+	for file := range s.pluginConfig {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	mounts := make([]v1.VolumeMount, 0, len(files))
+	checksum := sha256.New()
+	for _, file := range files {
+		mounts = append(mounts, v1.VolumeMount{
+			Name:      "mod-config",
+			MountPath: path.Join(modPath, pluginConfigDirectory, file),
+			SubPath:   file,
+			ReadOnly:  true,
+		})
+		fmt.Fprintf(checksum, "%s\n%s\n", file, s.pluginConfig[file])
+	}
+	return mounts, fmt.Sprintf("%x", checksum.Sum(nil))
+}
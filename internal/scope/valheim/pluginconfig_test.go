@@ -0,0 +1,129 @@
+package valheim
+
+import (
+	"errors"
+
+	"github.com/go-logr/logr"
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/robwittman/gamely/api/v1alpha2"
+)
+
+var _ = ginkgo.Describe("renderPluginConfig", func() {
+	ginkgo.It("renders sections and settings in order", func() {
+		config, err := renderPluginConfig(v1alpha2.ValheimModSpec{
+			Settings: map[string]map[string]string{
+				"Server":  {"MaxPortals": "20", "Enabled": "true"},
+				"General": {"Language": "English"},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config).To(Equal("[General]\n\nLanguage = English\n\n[Server]\n\nEnabled = true\nMaxPortals = 20\n"))
+	})
+
+	ginkgo.It("passes raw config through", func() {
+		config, err := renderPluginConfig(v1alpha2.ValheimModSpec{Config: "[General]\nLanguage = English\n"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config).To(Equal("[General]\nLanguage = English\n"))
+	})
+
+	ginkgo.DescribeTable("rejects config it cannot render",
+		func(mod v1alpha2.ValheimModSpec, message string) {
+			_, err := renderPluginConfig(mod)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		ginkgo.Entry("with both config and settings", v1alpha2.ValheimModSpec{
+			Config:   "[General]\n",
+			Settings: map[string]map[string]string{"General": {"Language": "English"}},
+		}, "exclusive"),
+		ginkgo.Entry("with a section that would open another", v1alpha2.ValheimModSpec{
+			Settings: map[string]map[string]string{"General]\n[Server": {"Language": "English"}},
+		}, "not a valid section name"),
+		ginkgo.Entry("with a setting that would set another", v1alpha2.ValheimModSpec{
+			Settings: map[string]map[string]string{"General": {"Language = English\nEnabled": "false"}},
+		}, "not a valid setting name"),
+		ginkgo.Entry("with a value spanning lines", v1alpha2.ValheimModSpec{
+			Settings: map[string]map[string]string{"General": {"Language": "English\nEnabled = false"}},
+		}, "spans more than one line"),
+	)
+})
+
+var _ = ginkgo.Describe("pluginConfigFiles", func() {
+	ginkgo.It("renders a file for every configured package", func() {
+		files, err := pluginConfigFiles(map[string]v1alpha2.ValheimModSpec{
+			"Azumatt/AzuCraftyBoxes": {Settings: map[string]map[string]string{"General": {"Range": "20"}}},
+			"SpikeHimself/XPortal":   {Config: "[General]\n", ConfigFile: "xportal.cfg"},
+			"ValheimModding/Jotunn":  {Version: "2.15.1"},
+		}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(Equal(map[string]string{
+			"Azumatt.AzuCraftyBoxes.cfg": "[General]\n\nRange = 20\n",
+			"xportal.cfg":                "[General]\n",
+		}))
+	})
+
+	ginkgo.It("lets the first package by name own a file configured twice", func() {
+		packages := map[string]v1alpha2.ValheimModSpec{
+			"b/Second": {Config: "second", ConfigFile: "shared.cfg"},
+			"a/First":  {Config: "first", ConfigFile: "shared.cfg"},
+			"c/Third":  {Config: "third"},
+		}
+		for i := 0; i < 10; i++ {
+			files, err := pluginConfigFiles(packages, nil)
+			Expect(err).To(MatchError("invalid plugin config: packages a/First and b/Second both configure shared.cfg"))
+			Expect(files).To(Equal(map[string]string{"shared.cfg": "first", "c.Third.cfg": "third"}))
+		}
+	})
+
+	ginkgo.It("keeps the previous file of a package whose config is invalid", func() {
+		files, err := pluginConfigFiles(map[string]v1alpha2.ValheimModSpec{
+			"a/Broken": {Config: "[General]\n", Settings: map[string]map[string]string{"General": {"Range": "20"}}},
+			"b/New":    {Config: "[General]\n", Settings: map[string]map[string]string{"General": {"Range": "20"}}},
+			"c/Valid":  {Config: "valid"},
+		}, map[string]string{
+			"a.Broken.cfg": "previous",
+			"registry.txt": "a/Broken:1.0.0:",
+		})
+		Expect(err).To(MatchError(And(
+			ContainSubstring("package a/Broken: config and settings are exclusive"),
+			ContainSubstring("package b/New: config and settings are exclusive"),
+		)))
+		Expect(files).To(Equal(map[string]string{"a.Broken.cfg": "previous", "c.Valid.cfg": "valid"}))
+	})
+})
+
+var _ = ginkgo.Describe("reportInvalidConfig", func() {
+	ginkgo.It("warns once for every generation", func() {
+		recorder := record.NewFakeRecorder(10)
+		s := &Scope{
+			Logger:   logr.Discard(),
+			Recorder: recorder,
+			Valheim: &v1alpha2.Valheim{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world", Generation: 1},
+				Spec:       v1alpha2.ValheimSpec{Mods: v1alpha2.ValheimModsSpec{Enabled: true}},
+			},
+		}
+		reconcile := func() {
+			s.configErr = nil
+			s.reportInvalidConfig(EventReasonPluginConfigInvalid, errors.New("invalid plugin config"))
+			s.setModsCondition(nil)
+		}
+
+		reconcile()
+		Expect(recorder.Events).To(Receive(ContainSubstring(EventReasonPluginConfigInvalid)))
+		reconcile()
+		Expect(recorder.Events).NotTo(Receive())
+
+		s.Valheim.Generation = 2
+		reconcile()
+		Expect(recorder.Events).To(Receive(ContainSubstring(EventReasonPluginConfigInvalid)))
+		Expect(s.Valheim.Status.Conditions).To(ContainElement(And(
+			HaveField("Type", v1alpha2.ConditionModsReady),
+			HaveField("Reason", "InvalidConfig"),
+		)))
+	})
+})
@@ -0,0 +1,73 @@
+package valheim
+
+import (
+	"context"
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+const (
+	// queryPort is the port the server answers A2S queries on
+	queryPort = 2457
+
+	// queryInterval is how often a running server is queried for its players
+	queryInterval = time.Second * 30
+)
+
+// queryServer asks the running server about itself over its query port,
+// recording its players and version in the status. Failures are only logged,
+// as their details change from query to query. Servers are queried at most
+// once per queryInterval whether or not they answer, since every answer
+// updates the status and every unanswered query blocks until it times out.
+// Returns how long to wait until the server should be queried again.
+func (s *Scope) queryServer(ctx context.Context, statefulSet *appsv1.StatefulSet, service *v1.Service) time.Duration {
+	if s.A2S == nil {
+		return 0
+	}
+	if s.Valheim.Stopped() || statefulSet.Status.ReadyReplicas == 0 {
+		s.Valheim.Status.Players = 0
+		return 0
+	}
+	status := &s.Valheim.Status
+	if lastQueried := status.LastQueried; lastQueried != nil {
+		if wait := queryInterval - time.Since(lastQueried.Time); wait > 0 {
+			// Until the next query, the server is assumed to still not
+			// answer if it did not answer the last one
+			if status.LastSeen == nil || status.LastSeen.Before(lastQueried) {
+				s.queryErr = fmt.Errorf("server did not answer its last query at %s", lastQueried.Format(time.RFC3339))
+			}
+			return wait
+		}
+	}
+	if service.Spec.ClusterIP == "" || service.Spec.ClusterIP == v1.ClusterIPNone {
+		s.queryErr = fmt.Errorf("service %s has no cluster IP to query the server on", service.Name)
+		return queryInterval
+	}
+
+	now := metav1.Now()
+	status.LastQueried = &now
+	info, err := s.A2S.Info(ctx, fmt.Sprintf("%s:%d", service.Spec.ClusterIP, serviceQueryPort(service)))
+	if err != nil {
+		s.Logger.Info("server did not answer query", "error", err.Error())
+		s.queryErr = err
+		return queryInterval
+	}
+	status.Players = int32(info.Players)
+	status.MaxPlayers = int32(info.MaxPlayers)
+	status.Version = info.Version
+	status.LastSeen = &now
+	return queryInterval
+}
+
+// serviceQueryPort is the port of service that reaches the query port of the server
+func serviceQueryPort(service *v1.Service) int32 {
+	for _, port := range service.Spec.Ports {
+		if port.Name == "query" {
+			return port.Port
+		}
+	}
+	return queryPort
+}
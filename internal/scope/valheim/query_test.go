@@ -0,0 +1,159 @@
+package valheim
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/robwittman/gamely/api/v1alpha2"
+	"github.com/robwittman/gamely/internal/a2s"
+	"github.com/robwittman/gamely/internal/a2s/a2stest"
+)
+
+var _ = ginkgo.Describe("queryServer", func() {
+	var (
+		server      *a2stest.Server
+		s           *Scope
+		statefulSet *appsv1.StatefulSet
+		service     *v1.Service
+	)
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		server, err = a2stest.NewServer()
+		Expect(err).NotTo(HaveOccurred())
+		ginkgo.DeferCleanup(server.Close)
+		server.Info = a2stest.InfoResponse("Vikings", 3, 10, "0.217.46")
+
+		s = &Scope{
+			Logger: logr.Discard(),
+			Valheim: &v1alpha2.Valheim{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world"},
+			},
+			A2S: &a2s.Client{Timeout: time.Millisecond * 200},
+		}
+		statefulSet = &appsv1.StatefulSet{Status: appsv1.StatefulSetStatus{ReadyReplicas: 1}}
+		service = &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world"},
+			Spec: v1.ServiceSpec{
+				ClusterIP: "127.0.0.1",
+				Ports: []v1.ServicePort{
+					{Name: "game", Port: 2456},
+					{Name: "query", Port: int32(server.Port())},
+				},
+			},
+		}
+	})
+
+	listening := func() *metav1.Condition {
+		s.setServerListeningCondition(statefulSet, nil)
+		return meta.FindStatusCondition(s.Valheim.Status.Conditions, v1alpha2.ConditionServerListening)
+	}
+
+	ginkgo.It("records the players and version of a server answering queries", func() {
+		Expect(s.queryServer(context.Background(), statefulSet, service)).To(Equal(queryInterval))
+		Expect(s.queryErr).NotTo(HaveOccurred())
+		Expect(s.Valheim.Status.Players).To(BeEquivalentTo(3))
+		Expect(s.Valheim.Status.MaxPlayers).To(BeEquivalentTo(10))
+		Expect(s.Valheim.Status.Version).To(Equal("0.217.46"))
+		Expect(s.Valheim.Status.LastSeen.Time).To(BeTemporally("~", time.Now(), time.Second))
+
+		condition := listening()
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal("Responding"))
+		Expect(condition.Message).To(Equal("server answers queries with 3 of 10 players"))
+	})
+
+	ginkgo.It("reports a server that does not answer queries", func() {
+		server.Info = nil
+		Expect(s.queryServer(context.Background(), statefulSet, service)).To(Equal(queryInterval))
+		Expect(s.queryErr).To(HaveOccurred())
+		Expect(s.Valheim.Status.LastSeen).To(BeNil())
+		Expect(s.Valheim.Status.LastQueried.Time).To(BeTemporally("~", time.Now(), time.Second))
+
+		condition := listening()
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("NotResponding"))
+	})
+
+	ginkgo.It("waits out the query interval after a query went unanswered", func() {
+		lastQueried := metav1.NewTime(time.Now().Add(-time.Second * 10))
+		s.Valheim.Status.LastQueried = &lastQueried
+
+		wait := s.queryServer(context.Background(), statefulSet, service)
+		Expect(wait).To(BeNumerically("~", queryInterval-time.Second*10, time.Second))
+		Expect(s.Valheim.Status.LastSeen).To(BeNil())
+		Expect(s.Valheim.Status.LastQueried.Time).To(Equal(lastQueried.Time))
+		Expect(listening().Reason).To(Equal("NotResponding"))
+	})
+
+	ginkgo.It("reports a service without a cluster IP", func() {
+		service.Spec.ClusterIP = v1.ClusterIPNone
+		Expect(s.queryServer(context.Background(), statefulSet, service)).To(Equal(queryInterval))
+		Expect(s.queryErr).To(MatchError(ContainSubstring("no cluster IP")))
+	})
+
+	ginkgo.It("waits out the query interval after the server was last seen", func() {
+		lastSeen := metav1.NewTime(time.Now().Add(-time.Second * 10))
+		s.Valheim.Status.LastSeen = &lastSeen
+		s.Valheim.Status.LastQueried = &lastSeen
+		s.Valheim.Status.Players = 1
+
+		wait := s.queryServer(context.Background(), statefulSet, service)
+		Expect(wait).To(BeNumerically("~", queryInterval-time.Second*10, time.Second))
+		Expect(s.Valheim.Status.Players).To(BeEquivalentTo(1))
+		Expect(s.Valheim.Status.LastSeen.Time).To(Equal(lastSeen.Time))
+		Expect(listening().Reason).To(Equal("Responding"))
+	})
+
+	ginkgo.It("clears the players of a server that is not ready", func() {
+		s.Valheim.Status.Players = 2
+		statefulSet.Status.ReadyReplicas = 0
+		Expect(s.queryServer(context.Background(), statefulSet, service)).To(BeZero())
+		Expect(s.Valheim.Status.Players).To(BeZero())
+		Expect(listening().Reason).To(Equal("NotReady"))
+	})
+
+	ginkgo.It("clears the players of a stopped server", func() {
+		s.Valheim.Status.Players = 2
+		s.Valheim.Spec.Paused = true
+		Expect(s.queryServer(context.Background(), statefulSet, service)).To(BeZero())
+		Expect(s.Valheim.Status.Players).To(BeZero())
+		Expect(listening().Reason).To(Equal("Paused"))
+	})
+
+	ginkgo.It("reports a restoring server before a paused one", func() {
+		s.Valheim.Spec.Paused = true
+		s.Valheim.Annotations = map[string]string{v1alpha2.AnnotationRestoring: "rollback"}
+		Expect(listening().Reason).To(Equal("Restoring"))
+	})
+
+	ginkgo.It("reports a ready pod when queries are disabled", func() {
+		s.A2S = nil
+		Expect(s.queryServer(context.Background(), statefulSet, service)).To(BeZero())
+		Expect(s.Valheim.Status.LastSeen).To(BeNil())
+
+		condition := listening()
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal("PodReady"))
+	})
+})
+
+var _ = ginkgo.Describe("serviceQueryPort", func() {
+	ginkgo.It("finds the port named query", func() {
+		service := &v1.Service{Spec: v1.ServiceSpec{Ports: []v1.ServicePort{{Name: "game", Port: 2456}, {Name: "query", Port: 30457}}}}
+		Expect(serviceQueryPort(service)).To(BeEquivalentTo(30457))
+	})
+
+	ginkgo.It("falls back to the query port of the server", func() {
+		Expect(serviceQueryPort(&v1.Service{})).To(BeEquivalentTo(queryPort))
+	})
+})
@@ -0,0 +1,169 @@
+package valheim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/robwittman/gamely/api/v1alpha1"
+	"github.com/robwittman/gamely/api/v1alpha2"
+	"github.com/robwittman/gamely/internal/util"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// restoreDirectory is where archives downloaded from the bucket are kept
+// while they are restored
+const restoreDirectory = "/restore"
+
+// downloadScript fetches the archive being restored from the bucket
+const downloadScript = `
+set -eu
+export AWS_DEFAULT_REGION="${AWS_DEFAULT_REGION:-us-east-1}"
+aws configure set default.s3.signature_version s3v4
+if [ -n "${AWS_ENDPOINT_URL:-}" ]; then
+  set -- --endpoint-url "${AWS_ENDPOINT_URL}"
+fi
+aws "$@" s3 cp "s3://${BUCKET}/${BACKUP_PREFIX}/${RESTORE_ARCHIVE}" "${RESTORE_DIRECTORY}/${RESTORE_ARCHIVE}" --no-progress
+`
+
+// restoreScript keeps a safety copy of the current world on the backups
+// volume, then replaces the world with the contents of the archive. Archives
+// may hold the worlds_local directory itself or only its contents. The safety
+// copy is described in the termination message as a RestoreResult.
+const restoreScript = `
+set -eu
+archive="${RESTORE_DIRECTORY}/${RESTORE_ARCHIVE}"
+test -f "${archive}"
+cd "${CONFIG_DIRECTORY}"
+safety_archive=""
+if [ -d worlds_local ]; then
+  safety_archive="worlds_local-$(date +%Y%m%d-%H%M%S)-pre-restore.zip"
+  zip -r "${BACKUPS_DIRECTORY}/${safety_archive}" worlds_local
+  echo "saved current world to ${safety_archive}"
+fi
+rm -rf worlds_local.restore
+mkdir worlds_local.restore
+case "${archive}" in
+  *.tar.gz) tar -xzf "${archive}" -C worlds_local.restore ;;
+  *) unzip -q "${archive}" -d worlds_local.restore ;;
+esac
+restored=worlds_local.restore
+if [ -d worlds_local.restore/worlds_local ]; then
+  restored=worlds_local.restore/worlds_local
+fi
+rm -rf worlds_local
+mv "${restored}" worlds_local
+rm -rf worlds_local.restore
+echo "restored ${RESTORE_ARCHIVE}"
+printf '{"safetyArchive":"%s"}' "${safety_archive}" > /dev/termination-log
+`
+
+// RestoreResult describes a finished restore, as reported by a restore job
+type RestoreResult struct {
+	SafetyArchive string `json:"safetyArchive"`
+}
+
+// NewRestoreJob builds a job that replaces the world of valheim with archive,
+// read from the backups volume or downloaded from the bucket. The server has
+// to be scaled down first, since the world volume is ReadWriteOnce.
+func NewRestoreJob(valheim *v1alpha2.Valheim, name string, archive string, source v1alpha1.ValheimRestoreSource) *batchv1.Job {
+	labels := map[string]string{
+		"gamely.io": "valheim-restore",
+		"server":    valheim.Name,
+	}
+
+	directory := valheim.Spec.Backups.GetDirectory()
+	volumeMounts := []v1.VolumeMount{
+		{Name: "worlddata", MountPath: ConfigDirectory, SubPath: worldDataConfigPath},
+		{Name: "backups", MountPath: directory},
+	}
+	volumes := []v1.Volume{
+		{
+			Name: "worlddata",
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+					ClaimName: valheim.Name,
+				},
+			},
+		},
+		backupsVolume(valheim),
+	}
+
+	var initContainers []v1.Container
+	if source == v1alpha1.RestoreSourceBucket {
+		directory = restoreDirectory
+		volumeMounts = append(volumeMounts, v1.VolumeMount{Name: "restore", MountPath: restoreDirectory})
+		volumes = append(volumes, v1.Volume{
+			Name:         "restore",
+			VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}},
+		})
+
+		env, envFrom := bucketEnv(valheim)
+		env = append(env,
+			v1.EnvVar{Name: "RESTORE_ARCHIVE", Value: archive},
+			v1.EnvVar{Name: "RESTORE_DIRECTORY", Value: restoreDirectory},
+		)
+		initContainers = append(initContainers, v1.Container{
+			Name:         "download",
+			Image:        UploaderImage,
+			Command:      []string{"sh", "-c"},
+			Args:         []string{downloadScript},
+			Env:          env,
+			EnvFrom:      envFrom,
+			VolumeMounts: []v1.VolumeMount{{Name: "restore", MountPath: restoreDirectory}},
+		})
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: valheim.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			// A failed restore may have already moved the world aside, so we
+			// report it rather than trying again on top of it
+			BackoffLimit: util.Int32Addr(0),
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: v1.PodSpec{
+					RestartPolicy:  v1.RestartPolicyNever,
+					InitContainers: initContainers,
+					Containers: []v1.Container{
+						{
+							Name:    "restore",
+							Image:   valheim.GetImage(),
+							Command: []string{"sh", "-c"},
+							Args:    []string{restoreScript},
+							Env: []v1.EnvVar{
+								{Name: "CONFIG_DIRECTORY", Value: ConfigDirectory},
+								{Name: EnvVarBackupsDirectory, Value: valheim.Spec.Backups.GetDirectory()},
+								{Name: "RESTORE_ARCHIVE", Value: archive},
+								{Name: "RESTORE_DIRECTORY", Value: directory},
+							},
+							VolumeMounts: volumeMounts,
+						},
+					},
+					Volumes: volumes,
+				},
+			},
+		},
+	}
+}
+
+// RestoreJobResult reads what a finished restore job reported
+func RestoreJobResult(ctx context.Context, c client.Client, job *batchv1.Job) (*RestoreResult, error) {
+	message, err := jobContainerMessage(ctx, c, job, "restore")
+	if err != nil {
+		return nil, err
+	}
+	result := &RestoreResult{}
+	if err := json.Unmarshal([]byte(message), result); err != nil {
+		return nil, fmt.Errorf("failed reading restore result of job %s: %w", job.Name, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,302 @@
+package valheim
+
+import (
+	"context"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"github.com/robwittman/gamely/api/v1alpha2"
+	"github.com/robwittman/gamely/internal/util"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sort"
+	"time"
+)
+
+const (
+	// AnnotationPostBackupHook marks a scheduled snapshot whose post backup
+	// hook still has to run once the snapshot has been cut, or a backup job
+	// whose hook has to run once it finishes, holding the archive it writes
+	AnnotationPostBackupHook = "gamely.io/post-backup-hook"
+
+	// EventReasonSnapshot and EventReasonSnapshotFailed track the volume
+	// snapshots taken on the backup schedule
+	EventReasonSnapshot       = "Snapshot"
+	EventReasonSnapshotFailed = "SnapshotFailed"
+
+	// serverContainer is the container of the server pod running Valheim
+	serverContainer = "server"
+
+	// reasonSnapshotsNotSupported is the BackupHealthy reason for clusters
+	// without the volume snapshot CRDs
+	reasonSnapshotsNotSupported = "SnapshotsNotSupported"
+
+	// snapshotRequeueInterval is how often we check whether a snapshot has been cut
+	snapshotRequeueInterval = time.Second * 10
+)
+
+// VolumeSnapshotGroupVersionKind is the kind of CSI volume snapshots. Their
+// types are not vendored, so they are handled as unstructured objects.
+var VolumeSnapshotGroupVersionKind = schema.GroupVersionKind{
+	Group:   "snapshot.storage.k8s.io",
+	Version: "v1",
+	Kind:    "VolumeSnapshot",
+}
+
+// hookScript runs the backup hook named by $1 with @BACKUP_FILE@ replaced by
+// $2, then flushes the world to disk
+const hookScript = `
+hook="$(printenv "$1" || true)"
+if [ -n "${hook}" ]; then
+  eval "$(echo "${hook}" | sed "s#@BACKUP_FILE@#$2#g")"
+fi
+sync
+`
+
+// NewVolumeSnapshot builds a snapshot of claim with the given labels
+func NewVolumeSnapshot(namespace string, name string, claim string, class string, labels map[string]string) *unstructured.Unstructured {
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(VolumeSnapshotGroupVersionKind)
+	snapshot.SetNamespace(namespace)
+	snapshot.SetName(name)
+	snapshot.SetLabels(labels)
+
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": claim,
+		},
+	}
+	if class != "" {
+		spec["volumeSnapshotClassName"] = class
+	}
+	snapshot.Object["spec"] = spec
+	return snapshot
+}
+
+// VolumeSnapshotCut reports whether the snapshot has been taken, after which
+// the source volume may change again
+func VolumeSnapshotCut(snapshot *unstructured.Unstructured) bool {
+	created, _, _ := unstructured.NestedString(snapshot.Object, "status", "creationTime")
+	return created != ""
+}
+
+// volumeSnapshotTime is when the snapshot was cut, or when it was created
+// while it has not been cut yet
+func volumeSnapshotTime(snapshot *unstructured.Unstructured) time.Time {
+	if created, _, _ := unstructured.NestedString(snapshot.Object, "status", "creationTime"); created != "" {
+		if t, err := time.Parse(time.RFC3339, created); err == nil {
+			return t
+		}
+	}
+	return snapshot.GetCreationTimestamp().Time
+}
+
+// VolumeSnapshotReady reports whether a volume can be provisioned from the snapshot
+func VolumeSnapshotReady(snapshot *unstructured.Unstructured) bool {
+	ready, _, _ := unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+	return ready
+}
+
+// VolumeSnapshotRestoreSize is the smallest volume the snapshot can be restored to
+func VolumeSnapshotRestoreSize(snapshot *unstructured.Unstructured) (resource.Quantity, bool) {
+	size, found, _ := unstructured.NestedString(snapshot.Object, "status", "restoreSize")
+	if !found {
+		return resource.Quantity{}, false
+	}
+	quantity, err := resource.ParseQuantity(size)
+	if err != nil {
+		return resource.Quantity{}, false
+	}
+	return quantity, true
+}
+
+func (s *Scope) snapshotLabels() map[string]string {
+	return map[string]string{
+		"gamely.io": "valheim-snapshot",
+		"server":    s.Valheim.Name,
+	}
+}
+
+// reconcileSnapshots takes volume snapshots of the world volume on the backup
+// schedule in volumeSnapshot mode, and prunes them by the backup retention.
+// The pre backup hook runs in the server before each snapshot and the post
+// backup hook once it has been cut. Returns how long to wait until the next
+// snapshot is due or needs to be checked on.
+func (s *Scope) reconcileSnapshots(ctx context.Context, req ctrl.Request, running bool) (time.Duration, error) {
+	backups := s.Valheim.Spec.Backups
+	if backups.GetMode() != v1alpha2.BackupModeVolumeSnapshot || backups.Schedule == "" {
+		return 0, nil
+	}
+	schedule, err := cron.ParseStandard(backups.Schedule)
+	if err != nil {
+		s.Logger.Error(err, "invalid backup schedule, not taking volume snapshots")
+		return 0, nil
+	}
+
+	snapshots := &unstructured.UnstructuredList{}
+	snapshots.SetGroupVersionKind(VolumeSnapshotGroupVersionKind.GroupVersion().WithKind("VolumeSnapshotList"))
+	if err := s.Client.List(ctx, snapshots, client.InNamespace(req.Namespace), client.MatchingLabels(s.snapshotLabels())); err != nil {
+		if meta.IsNoMatchError(err) {
+			// Reported in the BackupHealthy condition, and as an event only
+			// when it starts failing
+			s.snapshotErr = fmt.Errorf("volume snapshots are not supported by this cluster, install the snapshot.storage.k8s.io CRDs")
+			if condition := meta.FindStatusCondition(s.Valheim.Status.Conditions, v1alpha2.ConditionBackupHealthy); condition == nil || condition.Reason != reasonSnapshotsNotSupported {
+				s.Recorder.Event(s.Valheim, v1.EventTypeWarning, EventReasonSnapshotFailed, s.snapshotErr.Error())
+			}
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	requeueAfter := time.Duration(0)
+	for i := range snapshots.Items {
+		snapshot := &snapshots.Items[i]
+		if _, pending := snapshot.GetAnnotations()[AnnotationPostBackupHook]; !pending {
+			continue
+		}
+		if !VolumeSnapshotCut(snapshot) {
+			requeueAfter = snapshotRequeueInterval
+			continue
+		}
+		if running {
+			s.runBackupHook(ctx, EnvVarPostBackupHook, snapshot.GetName())
+		}
+		patch := client.MergeFrom(snapshot.DeepCopy())
+		annotations := snapshot.GetAnnotations()
+		delete(annotations, AnnotationPostBackupHook)
+		snapshot.SetAnnotations(annotations)
+		if err := s.Client.Patch(ctx, snapshot, patch); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := s.pruneSnapshots(ctx, snapshots.Items); err != nil {
+		return 0, err
+	}
+
+	last := s.Valheim.GetCreationTimestamp()
+	if s.Valheim.Status.Backups.LastSnapshotTime != nil {
+		last = *s.Valheim.Status.Backups.LastSnapshotTime
+	}
+	if wait := time.Until(schedule.Next(last.Time)); wait > 0 {
+		if requeueAfter == 0 || wait < requeueAfter {
+			requeueAfter = wait
+		}
+		return requeueAfter, nil
+	}
+
+	now := metav1.Now()
+	name := fmt.Sprintf("%s-%s", req.Name, now.UTC().Format("20060102-150405"))
+	if running {
+		s.runBackupHook(ctx, EnvVarPreBackupHook, name)
+	}
+	snapshot := NewVolumeSnapshot(req.Namespace, name, req.Name, backups.VolumeSnapshotClassName, s.snapshotLabels())
+	snapshot.SetAnnotations(map[string]string{AnnotationPostBackupHook: "pending"})
+	if err := controllerutil.SetOwnerReference(s.Valheim, snapshot, s.Client.Scheme()); err != nil {
+		return 0, err
+	}
+	if err := s.Client.Create(ctx, snapshot); err != nil && !errors.IsAlreadyExists(err) {
+		s.Recorder.Eventf(s.Valheim, v1.EventTypeWarning, EventReasonSnapshotFailed, "failed creating volume snapshot %s: %s", name, err)
+		return 0, err
+	}
+	s.Recorder.Eventf(s.Valheim, v1.EventTypeNormal, EventReasonSnapshot, "created volume snapshot %s", name)
+	s.Valheim.Status.Backups.LastSnapshotTime = &now
+	return snapshotRequeueInterval, nil
+}
+
+// pruneSnapshots deletes the scheduled snapshots past the backup retention,
+// keeping the newest. Snapshots are aged by when they were cut.
+func (s *Scope) pruneSnapshots(ctx context.Context, snapshots []unstructured.Unstructured) error {
+	sort.Slice(snapshots, func(i, j int) bool {
+		return volumeSnapshotTime(&snapshots[i]).After(volumeSnapshotTime(&snapshots[j]))
+	})
+
+	maxCount := int(s.Valheim.Spec.Backups.GetMaxCount())
+	cutoff := time.Now().AddDate(0, 0, -int(s.Valheim.Spec.Backups.GetMaxAgeDays()))
+	for i := range snapshots {
+		snapshot := &snapshots[i]
+		if (maxCount == 0 || i < maxCount) && volumeSnapshotTime(snapshot).After(cutoff) {
+			continue
+		}
+		if snapshot.GetDeletionTimestamp() != nil {
+			continue
+		}
+		s.Logger.Info("pruning volume snapshot", "snapshot", snapshot.GetName())
+		if err := s.Client.Delete(ctx, snapshot); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// runBackupHook runs a backup hook inside the server, so it can quiesce the
+// world around a snapshot. Failing hooks are reported but do not stop the snapshot.
+func (s *Scope) runBackupHook(ctx context.Context, hook string, backupFile string) {
+	if err := RunBackupHook(ctx, s.Client, s.Executor, s.Valheim, hook, backupFile); err != nil {
+		s.Logger.Error(err, "backup hook failed", "hook", hook)
+		s.Recorder.Event(s.Valheim, v1.EventTypeWarning, EventReasonSnapshotFailed, err.Error())
+	}
+}
+
+// RunBackupHook runs the backup hook named by the environment variable hook
+// in the running server pods of valheim, with @BACKUP_FILE@ replaced by
+// backupFile. Nothing is run without an executor or a running server.
+func RunBackupHook(ctx context.Context, c client.Client, executor util.PodExecutor, valheim *v1alpha2.Valheim, hook string, backupFile string) error {
+	if executor == nil {
+		return nil
+	}
+	pods := &v1.PodList{}
+	if err := c.List(ctx, pods, client.InNamespace(valheim.Namespace), client.MatchingLabels(Labels(valheim))); err != nil {
+		return fmt.Errorf("failed listing server pods: %w", err)
+	}
+	var failed error
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != v1.PodRunning {
+			continue
+		}
+		if _, err := executor.Exec(ctx, pod.Namespace, pod.Name, serverContainer, []string{"sh", "-c", hookScript, "hook", hook, backupFile}); err != nil && failed == nil {
+			failed = fmt.Errorf("%s failed in pod %s: %w", hook, pod.Name, err)
+		}
+	}
+	return failed
+}
+
+// orphanSnapshots drops our owner reference from the scheduled volume snapshots
+func (s *Scope) orphanSnapshots(ctx context.Context, req ctrl.Request) error {
+	snapshots := &unstructured.UnstructuredList{}
+	snapshots.SetGroupVersionKind(VolumeSnapshotGroupVersionKind.GroupVersion().WithKind("VolumeSnapshotList"))
+	if err := s.Client.List(ctx, snapshots, client.InNamespace(req.Namespace), client.MatchingLabels(s.snapshotLabels())); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil
+		}
+		return err
+	}
+
+	for i := range snapshots.Items {
+		snapshot := &snapshots.Items[i]
+		owners := []metav1.OwnerReference{}
+		for _, owner := range snapshot.GetOwnerReferences() {
+			if owner.UID != s.Valheim.UID {
+				owners = append(owners, owner)
+			}
+		}
+		if len(owners) == len(snapshot.GetOwnerReferences()) {
+			continue
+		}
+
+		patch := client.MergeFrom(snapshot.DeepCopy())
+		snapshot.SetOwnerReferences(owners)
+		if err := s.Client.Patch(ctx, snapshot, patch); err != nil {
+			return err
+		}
+		s.Recorder.Eventf(s.Valheim, v1.EventTypeNormal, EventReasonRetained, "retained volume snapshot %s", snapshot.GetName())
+	}
+	return nil
+}
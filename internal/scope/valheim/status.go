@@ -0,0 +1,352 @@
+package valheim
+
+import (
+	"context"
+	"fmt"
+	"github.com/robwittman/gamely/api/v1alpha2"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"time"
+)
+
+const (
+	// modDownloaderContainer is the init container that installs mod packages
+	modDownloaderContainer = "mod-downloader"
+
+	// statusRequeueInterval is how often we look at the server again while it
+	// is not yet running, since pod progress does not trigger a reconcile
+	statusRequeueInterval = time.Second * 30
+)
+
+// reconcileStatus derives the conditions and phase of the server from its
+// owned resources, returning how long to wait before checking on it again
+func (s *Scope) reconcileStatus(ctx context.Context, statefulSet *appsv1.StatefulSet, service *v1.Service, claims []*v1.PersistentVolumeClaim, uploadCronJob *batchv1.CronJob) (time.Duration, error) {
+	pods := &v1.PodList{}
+	if err := s.Client.List(ctx, pods, client.InNamespace(s.Valheim.Namespace), client.MatchingLabels(s.labels)); err != nil {
+		return 0, err
+	}
+
+	s.recordModChecksums(pods.Items)
+	requeueAfter := s.setPausedCondition(statefulSet)
+	s.setStorageCondition(claims)
+	resizeAfter := s.setStorageResizedCondition()
+	s.setModsCondition(pods.Items)
+	queryAfter := s.queryServer(ctx, statefulSet, service)
+	s.setServerListeningCondition(statefulSet, pods.Items)
+	s.setBackupCondition(claims, uploadCronJob)
+	s.setPasswordCondition()
+	s.setDegradedCondition()
+
+	if uploadCronJob != nil && uploadCronJob.Status.LastSuccessfulTime != nil {
+		s.Valheim.Status.Backups.LastUploadTime = uploadCronJob.Status.LastSuccessfulTime
+	}
+	s.Valheim.Status.Address = serviceAddress(service)
+	s.Valheim.Status.Ready = meta.IsStatusConditionTrue(s.Valheim.Status.Conditions, v1alpha2.ConditionServerListening)
+	s.Valheim.Status.Phase = s.phase()
+
+	for _, after := range []time.Duration{resizeAfter, queryAfter} {
+		if after > 0 && (requeueAfter == 0 || after < requeueAfter) {
+			requeueAfter = after
+		}
+	}
+	if requeueAfter == 0 && s.Valheim.Status.Phase != v1alpha2.ValheimPhaseRunning && s.Valheim.Status.Phase != v1alpha2.ValheimPhasePaused && s.Valheim.Status.Phase != v1alpha2.ValheimPhaseRestoring {
+		requeueAfter = statusRequeueInterval
+	}
+	return requeueAfter, nil
+}
+
+// setCondition records condition against the current generation of the Valheim
+func (s *Scope) setCondition(conditionType string, status metav1.ConditionStatus, reason string, message string) {
+	meta.SetStatusCondition(&s.Valheim.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: s.Valheim.Generation,
+	})
+}
+
+// reportInvalidConfig records err as why config of the spec could not be
+// rendered, next to any config reported before it. The ModsReady condition
+// reports it on every reconcile, so the event is only emitted once for every
+// generation of the Valheim.
+func (s *Scope) reportInvalidConfig(reason string, err error) {
+	if s.configErr != nil {
+		s.configErr = fmt.Errorf("%s, %s", s.configErr, err)
+	} else {
+		s.configErr = err
+	}
+	condition := meta.FindStatusCondition(s.Valheim.Status.Conditions, v1alpha2.ConditionModsReady)
+	if condition != nil && condition.Reason == "InvalidConfig" && condition.ObservedGeneration == s.Valheim.Generation {
+		return
+	}
+	s.Recorder.Event(s.Valheim, v1.EventTypeWarning, reason, err.Error())
+}
+
+// setPausedCondition reflects spec.paused into the Paused condition, returning
+// how long to wait before checking again while the server is still shutting down
+func (s *Scope) setPausedCondition(statefulSet *appsv1.StatefulSet) time.Duration {
+	if !s.Valheim.Stopped() {
+		s.setCondition(v1alpha2.ConditionPaused, metav1.ConditionFalse, "Running", "server is not paused")
+		return 0
+	}
+
+	if restore := s.Valheim.Restoring(); restore != "" {
+		s.setCondition(v1alpha2.ConditionPaused, metav1.ConditionTrue, "Restoring", fmt.Sprintf("server is stopped while restore %s replaces its world", restore))
+		if statefulSet.Status.Replicas > 0 {
+			return time.Second * 10
+		}
+		return 0
+	}
+
+	if statefulSet.Status.Replicas > 0 {
+		s.setCondition(v1alpha2.ConditionPaused, metav1.ConditionTrue, "ShuttingDown", "waiting for server pods to shut down")
+		return time.Second * 10
+	}
+	s.setCondition(v1alpha2.ConditionPaused, metav1.ConditionTrue, "ServerStopped", "server has been scaled down to zero replicas")
+	return 0
+}
+
+func (s *Scope) setStorageCondition(claims []*v1.PersistentVolumeClaim) {
+	lost := []string{}
+	pending := []string{}
+	for _, claim := range claims {
+		switch claim.Status.Phase {
+		case v1.ClaimBound:
+		case v1.ClaimLost:
+			lost = append(lost, claim.Name)
+		default:
+			pending = append(pending, claim.Name)
+		}
+	}
+
+	switch {
+	case len(lost) > 0:
+		s.setCondition(v1alpha2.ConditionStorageReady, metav1.ConditionFalse, "ClaimLost", "lost persistent volume claims: "+strings.Join(lost, ", "))
+	case len(pending) > 0:
+		s.setCondition(v1alpha2.ConditionStorageReady, metav1.ConditionFalse, "ClaimPending", "waiting for persistent volume claims to bind: "+strings.Join(pending, ", "))
+	default:
+		s.setCondition(v1alpha2.ConditionStorageReady, metav1.ConditionTrue, "ClaimsBound", "all persistent volume claims are bound")
+	}
+}
+
+func (s *Scope) setModsCondition(pods []v1.Pod) {
+	if !s.Valheim.Spec.Mods.Enabled {
+		meta.RemoveStatusCondition(&s.Valheim.Status.Conditions, v1alpha2.ConditionModsReady)
+		return
+	}
+
+	if s.configErr != nil {
+		s.setCondition(v1alpha2.ConditionModsReady, metav1.ConditionFalse, "InvalidConfig", s.configErr.Error())
+		return
+	}
+
+	if s.modsErr != nil {
+		s.setCondition(v1alpha2.ConditionModsReady, metav1.ConditionFalse, "ResolutionFailed", s.modsErr.Error())
+		return
+	}
+
+	if len(pods) == 0 {
+		s.setCondition(v1alpha2.ConditionModsReady, metav1.ConditionUnknown, "NoPods", "no server pods are running")
+		return
+	}
+
+	for _, pod := range pods {
+		for _, containerStatus := range pod.Status.InitContainerStatuses {
+			if containerStatus.Name != modDownloaderContainer {
+				continue
+			}
+
+			if terminated := containerStatus.State.Terminated; terminated != nil {
+				if terminated.ExitCode == 0 {
+					continue
+				}
+				s.setCondition(v1alpha2.ConditionModsReady, metav1.ConditionFalse, "DownloadFailed", terminatedMessage(terminated))
+				return
+			}
+			if terminated := containerStatus.LastTerminationState.Terminated; terminated != nil && terminated.ExitCode != 0 {
+				s.setCondition(v1alpha2.ConditionModsReady, metav1.ConditionFalse, "DownloadFailed", terminatedMessage(terminated))
+				return
+			}
+			s.setCondition(v1alpha2.ConditionModsReady, metav1.ConditionFalse, "Downloading", "mod packages are being installed")
+			return
+		}
+	}
+	s.setCondition(v1alpha2.ConditionModsReady, metav1.ConditionTrue, "Installed", "mod packages are installed")
+}
+
+func (s *Scope) setServerListeningCondition(statefulSet *appsv1.StatefulSet, pods []v1.Pod) {
+	if s.Valheim.Restoring() != "" {
+		s.setCondition(v1alpha2.ConditionServerListening, metav1.ConditionFalse, "Restoring", "server is stopped for a restore")
+		return
+	}
+	if s.Valheim.Spec.Paused {
+		s.setCondition(v1alpha2.ConditionServerListening, metav1.ConditionFalse, "Paused", "server is paused")
+		return
+	}
+
+	if statefulSet.Status.ReadyReplicas > 0 {
+		switch {
+		case s.A2S == nil:
+			s.setCondition(v1alpha2.ConditionServerListening, metav1.ConditionTrue, "PodReady", "server pod is ready")
+		case s.queryErr != nil:
+			s.setCondition(v1alpha2.ConditionServerListening, metav1.ConditionFalse, "NotResponding", fmt.Sprintf("server pod is ready but does not answer queries on port %d", queryPort))
+		default:
+			s.setCondition(v1alpha2.ConditionServerListening, metav1.ConditionTrue, "Responding", fmt.Sprintf("server answers queries with %d of %d players", s.Valheim.Status.Players, s.Valheim.Status.MaxPlayers))
+		}
+		return
+	}
+
+	for _, pod := range pods {
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			if waiting := containerStatus.State.Waiting; waiting != nil && waiting.Reason == "CrashLoopBackOff" {
+				s.setCondition(v1alpha2.ConditionServerListening, metav1.ConditionFalse, "CrashLoopBackOff", fmt.Sprintf("container %s is crash looping", containerStatus.Name))
+				return
+			}
+		}
+	}
+	s.setCondition(v1alpha2.ConditionServerListening, metav1.ConditionFalse, "NotReady", "waiting for the server pod to become ready")
+}
+
+// setBackupCondition reports whether backups can be written, whether volume
+// snapshots can be taken in volumeSnapshot mode, and when a bucket is
+// configured, whether the most recent upload to it succeeded
+func (s *Scope) setBackupCondition(claims []*v1.PersistentVolumeClaim, uploadCronJob *batchv1.CronJob) {
+	var backupClaim *v1.PersistentVolumeClaim
+	for _, claim := range claims {
+		if claim.Name == s.Valheim.Name+"-backups" {
+			backupClaim = claim
+		}
+	}
+
+	switch {
+	case s.snapshotErr != nil:
+		s.setCondition(v1alpha2.ConditionBackupHealthy, metav1.ConditionFalse, reasonSnapshotsNotSupported, s.snapshotErr.Error())
+	case backupClaim == nil:
+		s.setCondition(v1alpha2.ConditionBackupHealthy, metav1.ConditionUnknown, "StorageUnknown", "backup volume claim was not found")
+	case backupClaim.Status.Phase != v1.ClaimBound:
+		s.setCondition(v1alpha2.ConditionBackupHealthy, metav1.ConditionFalse, "StorageUnavailable", fmt.Sprintf("backup volume claim is %s", backupClaim.Status.Phase))
+	case uploadCronJob == nil:
+		s.setCondition(v1alpha2.ConditionBackupHealthy, metav1.ConditionTrue, "StorageBound", "backup storage is available")
+	case uploadFailed(uploadCronJob):
+		s.setCondition(v1alpha2.ConditionBackupHealthy, metav1.ConditionFalse, "UploadFailed", fmt.Sprintf("last upload to bucket %s did not succeed, see jobs of cron job %s", s.Valheim.Spec.Backups.Bucket, uploadCronJob.Name))
+	default:
+		s.setCondition(v1alpha2.ConditionBackupHealthy, metav1.ConditionTrue, "Uploading", fmt.Sprintf("backups are uploaded to bucket %s", s.Valheim.Spec.Backups.Bucket))
+	}
+}
+
+// uploadFailed reports whether the last scheduled upload finished without
+// succeeding. Uploads that are still running are given the benefit of the doubt.
+func uploadFailed(cronJob *batchv1.CronJob) bool {
+	if cronJob.Status.LastScheduleTime == nil || len(cronJob.Status.Active) > 0 {
+		return false
+	}
+	lastSuccess := cronJob.Status.LastSuccessfulTime
+	return lastSuccess == nil || lastSuccess.Before(cronJob.Status.LastScheduleTime)
+}
+
+// setDegradedCondition rolls up the failure reasons of the other conditions.
+// Conditions that are merely waiting on progress do not count as degraded.
+func (s *Scope) setDegradedCondition() {
+	failures := map[string]map[string]bool{
+		v1alpha2.ConditionStorageReady:    {"ClaimLost": true},
+		v1alpha2.ConditionStorageResized:  {"ShrinkRejected": true, "ExpansionNotSupported": true},
+		v1alpha2.ConditionModsReady:       {"DownloadFailed": true, "ResolutionFailed": true, "InvalidConfig": true},
+		v1alpha2.ConditionServerListening: {"CrashLoopBackOff": true},
+		v1alpha2.ConditionBackupHealthy:   {"StorageUnavailable": true, "UploadFailed": true, reasonSnapshotsNotSupported: true},
+		v1alpha2.ConditionPasswordReady:   {"SecretNotFound": true, "KeyNotFound": true, "InvalidPassword": true, "NotAllowed": true},
+	}
+
+	messages := []string{}
+	for _, conditionType := range []string{
+		v1alpha2.ConditionStorageReady,
+		v1alpha2.ConditionStorageResized,
+		v1alpha2.ConditionModsReady,
+		v1alpha2.ConditionServerListening,
+		v1alpha2.ConditionBackupHealthy,
+		v1alpha2.ConditionPasswordReady,
+	} {
+		condition := meta.FindStatusCondition(s.Valheim.Status.Conditions, conditionType)
+		if condition == nil || condition.Status != metav1.ConditionFalse {
+			continue
+		}
+		if failures[conditionType][condition.Reason] {
+			messages = append(messages, fmt.Sprintf("%s: %s", conditionType, condition.Message))
+		}
+	}
+
+	if len(messages) > 0 {
+		s.setCondition(v1alpha2.ConditionDegraded, metav1.ConditionTrue, "ComponentsFailing", strings.Join(messages, "; "))
+		return
+	}
+	s.setCondition(v1alpha2.ConditionDegraded, metav1.ConditionFalse, "AsExpected", "no failures detected")
+}
+
+func (s *Scope) phase() v1alpha2.ValheimPhase {
+	conditions := s.Valheim.Status.Conditions
+	switch {
+	case s.Valheim.GetDeletionTimestamp() != nil:
+		return v1alpha2.ValheimPhaseTerminating
+	case s.Valheim.Restoring() != "":
+		return v1alpha2.ValheimPhaseRestoring
+	case s.Valheim.Spec.Paused:
+		return v1alpha2.ValheimPhasePaused
+	case meta.IsStatusConditionTrue(conditions, v1alpha2.ConditionDegraded):
+		return v1alpha2.ValheimPhaseDegraded
+	case meta.IsStatusConditionTrue(conditions, v1alpha2.ConditionServerListening):
+		return v1alpha2.ValheimPhaseRunning
+	case meta.IsStatusConditionTrue(conditions, v1alpha2.ConditionStorageReady):
+		return v1alpha2.ValheimPhaseStarting
+	default:
+		return v1alpha2.ValheimPhasePending
+	}
+}
+
+// serviceAddress is the address players should connect to, if one is known yet
+func serviceAddress(service *v1.Service) string {
+	var gamePort v1.ServicePort
+	for _, port := range service.Spec.Ports {
+		if port.Name == "game" {
+			gamePort = port
+		}
+	}
+
+	switch service.Spec.Type {
+	case v1.ServiceTypeLoadBalancer:
+		for _, ingress := range service.Status.LoadBalancer.Ingress {
+			if ingress.IP != "" {
+				return fmt.Sprintf("%s:%d", ingress.IP, gamePort.Port)
+			}
+			if ingress.Hostname != "" {
+				return fmt.Sprintf("%s:%d", ingress.Hostname, gamePort.Port)
+			}
+		}
+		return ""
+	case v1.ServiceTypeNodePort:
+		if gamePort.NodePort == 0 {
+			return ""
+		}
+		return fmt.Sprintf("*:%d", gamePort.NodePort)
+	default:
+		if service.Spec.ClusterIP == "" || service.Spec.ClusterIP == v1.ClusterIPNone {
+			return ""
+		}
+		return fmt.Sprintf("%s:%d", service.Spec.ClusterIP, gamePort.Port)
+	}
+}
+
+func terminatedMessage(terminated *v1.ContainerStateTerminated) string {
+	message := fmt.Sprintf("exited with code %d", terminated.ExitCode)
+	if terminated.Reason != "" {
+		message += ": " + terminated.Reason
+	}
+	if terminated.Message != "" {
+		message += ": " + strings.TrimSpace(terminated.Message)
+	}
+	return message
+}
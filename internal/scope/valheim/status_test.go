@@ -0,0 +1,242 @@
+package valheim
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/robwittman/gamely/api/v1alpha2"
+)
+
+// statusScope is a scope for the server named world, with mods enabled
+func statusScope(objects ...client.Object) *Scope {
+	scheme := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	Expect(v1alpha2.AddToScheme(scheme)).To(Succeed())
+	return &Scope{
+		Logger:   logr.Discard(),
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		Recorder: record.NewFakeRecorder(10),
+		Valheim: &v1alpha2.Valheim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world", Generation: 1},
+			Spec:       v1alpha2.ValheimSpec{Mods: v1alpha2.ValheimModsSpec{Enabled: true}},
+		},
+		labels: map[string]string{"app": "world"},
+	}
+}
+
+func claim(name string, phase v1.PersistentVolumeClaimPhase) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Status:     v1.PersistentVolumeClaimStatus{Phase: phase},
+	}
+}
+
+func conditionReason(s *Scope, conditionType string) string {
+	condition := meta.FindStatusCondition(s.Valheim.Status.Conditions, conditionType)
+	Expect(condition).NotTo(BeNil(), "condition %s", conditionType)
+	return condition.Reason
+}
+
+var _ = ginkgo.Describe("setStorageCondition", func() {
+	ginkgo.DescribeTable("reports the bind state of the claims",
+		func(claims []*v1.PersistentVolumeClaim, reason string) {
+			s := statusScope()
+			s.setStorageCondition(claims)
+			Expect(conditionReason(s, v1alpha2.ConditionStorageReady)).To(Equal(reason))
+		},
+		ginkgo.Entry("all bound", []*v1.PersistentVolumeClaim{claim("world", v1.ClaimBound), claim("world-backups", v1.ClaimBound)}, "ClaimsBound"),
+		ginkgo.Entry("one pending", []*v1.PersistentVolumeClaim{claim("world", v1.ClaimBound), claim("world-backups", v1.ClaimPending)}, "ClaimPending"),
+		ginkgo.Entry("one lost before one pending", []*v1.PersistentVolumeClaim{claim("world", v1.ClaimPending), claim("world-backups", v1.ClaimLost)}, "ClaimLost"),
+	)
+})
+
+var _ = ginkgo.Describe("setModsCondition", func() {
+	downloader := func(state v1.ContainerState, last v1.ContainerState) v1.Pod {
+		return v1.Pod{Status: v1.PodStatus{InitContainerStatuses: []v1.ContainerStatus{
+			{Name: modDownloaderContainer, State: state, LastTerminationState: last},
+		}}}
+	}
+	exited := func(code int32) v1.ContainerState {
+		return v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: code, Reason: "Error"}}
+	}
+	running := v1.ContainerState{Running: &v1.ContainerStateRunning{}}
+
+	ginkgo.DescribeTable("reports the mod downloader of the server pods",
+		func(pods []v1.Pod, reason string, status metav1.ConditionStatus) {
+			s := statusScope()
+			s.setModsCondition(pods)
+			condition := meta.FindStatusCondition(s.Valheim.Status.Conditions, v1alpha2.ConditionModsReady)
+			Expect(condition.Reason).To(Equal(reason))
+			Expect(condition.Status).To(Equal(status))
+		},
+		ginkgo.Entry("without pods", nil, "NoPods", metav1.ConditionUnknown),
+		ginkgo.Entry("while downloading", []v1.Pod{downloader(running, v1.ContainerState{})}, "Downloading", metav1.ConditionFalse),
+		ginkgo.Entry("once installed", []v1.Pod{downloader(exited(0), v1.ContainerState{})}, "Installed", metav1.ConditionTrue),
+		ginkgo.Entry("when the download failed", []v1.Pod{downloader(exited(1), v1.ContainerState{})}, "DownloadFailed", metav1.ConditionFalse),
+		ginkgo.Entry("when the download is retried after failing", []v1.Pod{downloader(running, exited(1))}, "DownloadFailed", metav1.ConditionFalse),
+	)
+
+	ginkgo.It("reports config and resolution errors before the pods", func() {
+		s := statusScope()
+		s.modsErr = errors.New("no such package")
+		s.setModsCondition(nil)
+		Expect(conditionReason(s, v1alpha2.ConditionModsReady)).To(Equal("ResolutionFailed"))
+
+		s.configErr = errors.New("invalid plugin config")
+		s.setModsCondition(nil)
+		Expect(conditionReason(s, v1alpha2.ConditionModsReady)).To(Equal("InvalidConfig"))
+	})
+
+	ginkgo.It("drops the condition when mods are disabled", func() {
+		s := statusScope()
+		s.setModsCondition(nil)
+		s.Valheim.Spec.Mods.Enabled = false
+		s.setModsCondition(nil)
+		Expect(meta.FindStatusCondition(s.Valheim.Status.Conditions, v1alpha2.ConditionModsReady)).To(BeNil())
+	})
+})
+
+var _ = ginkgo.Describe("setBackupCondition", func() {
+	lastSchedule := metav1.NewTime(time.Now().Add(-time.Hour))
+	earlier := metav1.NewTime(time.Now().Add(-time.Hour * 2))
+	later := metav1.NewTime(time.Now().Add(-time.Minute))
+	cronJob := func(lastSuccess *metav1.Time, active int) *batchv1.CronJob {
+		cronJob := &batchv1.CronJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "world-backup-upload"},
+			Status:     batchv1.CronJobStatus{LastScheduleTime: &lastSchedule, LastSuccessfulTime: lastSuccess},
+		}
+		for i := 0; i < active; i++ {
+			cronJob.Status.Active = append(cronJob.Status.Active, v1.ObjectReference{Name: "upload"})
+		}
+		return cronJob
+	}
+
+	ginkgo.DescribeTable("reports the backup storage and uploads",
+		func(backups *v1.PersistentVolumeClaim, uploadCronJob *batchv1.CronJob, reason string) {
+			s := statusScope()
+			claims := []*v1.PersistentVolumeClaim{claim("world", v1.ClaimBound)}
+			if backups != nil {
+				claims = append(claims, backups)
+			}
+			s.setBackupCondition(claims, uploadCronJob)
+			Expect(conditionReason(s, v1alpha2.ConditionBackupHealthy)).To(Equal(reason))
+		},
+		ginkgo.Entry("without a backup claim", nil, nil, "StorageUnknown"),
+		ginkgo.Entry("with a pending backup claim", claim("world-backups", v1.ClaimPending), nil, "StorageUnavailable"),
+		ginkgo.Entry("without a bucket", claim("world-backups", v1.ClaimBound), nil, "StorageBound"),
+		ginkgo.Entry("after a successful upload", claim("world-backups", v1.ClaimBound), cronJob(&later, 0), "Uploading"),
+		ginkgo.Entry("while an upload is running", claim("world-backups", v1.ClaimBound), cronJob(&earlier, 1), "Uploading"),
+		ginkgo.Entry("after a failed upload", claim("world-backups", v1.ClaimBound), cronJob(&earlier, 0), "UploadFailed"),
+		ginkgo.Entry("before any upload succeeded", claim("world-backups", v1.ClaimBound), cronJob(nil, 0), "UploadFailed"),
+	)
+})
+
+var _ = ginkgo.Describe("setDegradedCondition", func() {
+	ginkgo.It("rolls up failures but not progress", func() {
+		s := statusScope()
+		s.setCondition(v1alpha2.ConditionStorageReady, metav1.ConditionFalse, "ClaimPending", "waiting")
+		s.setCondition(v1alpha2.ConditionModsReady, metav1.ConditionFalse, "Downloading", "installing")
+		s.setDegradedCondition()
+		Expect(meta.IsStatusConditionFalse(s.Valheim.Status.Conditions, v1alpha2.ConditionDegraded)).To(BeTrue())
+
+		s.setCondition(v1alpha2.ConditionModsReady, metav1.ConditionFalse, "DownloadFailed", "exited with code 1")
+		s.setCondition(v1alpha2.ConditionBackupHealthy, metav1.ConditionFalse, "UploadFailed", "upload failed")
+		s.setDegradedCondition()
+		condition := meta.FindStatusCondition(s.Valheim.Status.Conditions, v1alpha2.ConditionDegraded)
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Message).To(Equal("ModsReady: exited with code 1; BackupHealthy: upload failed"))
+	})
+})
+
+var _ = ginkgo.Describe("phase", func() {
+	ginkgo.DescribeTable("derives the phase from the conditions",
+		func(prepare func(s *Scope), phase v1alpha2.ValheimPhase) {
+			s := statusScope()
+			prepare(s)
+			Expect(s.phase()).To(Equal(phase))
+		},
+		ginkgo.Entry("pending without storage", func(s *Scope) {
+			s.setCondition(v1alpha2.ConditionStorageReady, metav1.ConditionFalse, "ClaimPending", "waiting")
+		}, v1alpha2.ValheimPhasePending),
+		ginkgo.Entry("starting once storage is bound", func(s *Scope) {
+			s.setCondition(v1alpha2.ConditionStorageReady, metav1.ConditionTrue, "ClaimsBound", "bound")
+		}, v1alpha2.ValheimPhaseStarting),
+		ginkgo.Entry("running once the server listens", func(s *Scope) {
+			s.setCondition(v1alpha2.ConditionStorageReady, metav1.ConditionTrue, "ClaimsBound", "bound")
+			s.setCondition(v1alpha2.ConditionServerListening, metav1.ConditionTrue, "Responding", "answering")
+		}, v1alpha2.ValheimPhaseRunning),
+		ginkgo.Entry("degraded before running", func(s *Scope) {
+			s.setCondition(v1alpha2.ConditionServerListening, metav1.ConditionTrue, "Responding", "answering")
+			s.setCondition(v1alpha2.ConditionDegraded, metav1.ConditionTrue, "ComponentsFailing", "failing")
+		}, v1alpha2.ValheimPhaseDegraded),
+		ginkgo.Entry("paused before degraded", func(s *Scope) {
+			s.Valheim.Spec.Paused = true
+			s.setCondition(v1alpha2.ConditionDegraded, metav1.ConditionTrue, "ComponentsFailing", "failing")
+		}, v1alpha2.ValheimPhasePaused),
+		ginkgo.Entry("restoring before paused", func(s *Scope) {
+			s.Valheim.Spec.Paused = true
+			s.Valheim.Annotations = map[string]string{v1alpha2.AnnotationRestoring: "rollback"}
+		}, v1alpha2.ValheimPhaseRestoring),
+		ginkgo.Entry("terminating before anything else", func(s *Scope) {
+			now := metav1.Now()
+			s.Valheim.DeletionTimestamp = &now
+			s.Valheim.Annotations = map[string]string{v1alpha2.AnnotationRestoring: "rollback"}
+		}, v1alpha2.ValheimPhaseTerminating),
+	)
+})
+
+var _ = ginkgo.Describe("reconcileStatus", func() {
+	ginkgo.It("reports a server whose pod is ready as running", func() {
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world-0", Labels: map[string]string{"app": "world"}},
+			Status: v1.PodStatus{InitContainerStatuses: []v1.ContainerStatus{{
+				Name:  modDownloaderContainer,
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 0}},
+			}}},
+		}
+		s := statusScope(pod)
+		statefulSet := &appsv1.StatefulSet{Status: appsv1.StatefulSetStatus{Replicas: 1, ReadyReplicas: 1}}
+		service := &v1.Service{Spec: v1.ServiceSpec{
+			ClusterIP: "10.0.0.10",
+			Ports:     []v1.ServicePort{{Name: "game", Port: 2456}},
+		}}
+		claims := []*v1.PersistentVolumeClaim{claim("world", v1.ClaimBound), claim("world-backups", v1.ClaimBound)}
+
+		requeueAfter, err := s.reconcileStatus(context.Background(), statefulSet, service, claims, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(BeZero())
+		Expect(s.Valheim.Status.Phase).To(Equal(v1alpha2.ValheimPhaseRunning))
+		Expect(s.Valheim.Status.Ready).To(BeTrue())
+		Expect(s.Valheim.Status.Address).To(Equal("10.0.0.10:2456"))
+		Expect(conditionReason(s, v1alpha2.ConditionModsReady)).To(Equal("Installed"))
+		Expect(conditionReason(s, v1alpha2.ConditionDegraded)).To(Equal("AsExpected"))
+	})
+
+	ginkgo.It("checks on a server that is still starting", func() {
+		s := statusScope()
+		statefulSet := &appsv1.StatefulSet{Status: appsv1.StatefulSetStatus{Replicas: 1}}
+		claims := []*v1.PersistentVolumeClaim{claim("world", v1.ClaimBound)}
+
+		requeueAfter, err := s.reconcileStatus(context.Background(), statefulSet, &v1.Service{}, claims, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(Equal(statusRequeueInterval))
+		Expect(s.Valheim.Status.Phase).To(Equal(v1alpha2.ValheimPhaseStarting))
+		Expect(s.Valheim.Status.Ready).To(BeFalse())
+	})
+})
@@ -0,0 +1,228 @@
+package valheim
+
+import (
+	"context"
+	"fmt"
+	"github.com/robwittman/gamely/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"time"
+)
+
+const (
+	// EventReasonResizing is emitted when a persistent volume claim is expanded
+	EventReasonResizing = "Resizing"
+	// EventReasonResizeRejected is emitted when a persistent volume claim cannot be resized
+	EventReasonResizeRejected = "ResizeRejected"
+	// EventReasonResizeRestart is emitted when the server is restarted to finish a resize
+	EventReasonResizeRestart = "ResizeRestart"
+
+	// fileSystemResizeGracePeriod is how long the kubelet gets to grow the file
+	// system of a mounted volume before we restart the pod using it. Drivers
+	// that can only expand offline finish the resize when the volume is mounted again.
+	fileSystemResizeGracePeriod = time.Minute * 2
+
+	// resizeRequeueInterval is how often we check on a claim being resized
+	resizeRequeueInterval = time.Second * 30
+
+	// worldLayoutContainer is the init container moving world volumes of
+	// servers created before the volume was split into sub paths
+	worldLayoutContainer = "world-layout"
+	// worldLayoutPath is where worldLayoutContainer mounts the world volume
+	worldLayoutPath = "/world"
+)
+
+// worldLayoutScript moves a world volume into the config and server sub paths.
+// Servers created before the split had the volume mounted at /opt/valheim,
+// so everything at its root is the server installation and moves into the
+// server sub path, apart from world directories, which move into the config
+// sub path the server now reads them from. The marker keeps the move from
+// running twice, so nothing added to the root later is touched.
+const worldLayoutScript = `
+set -eu
+cd "${WORLD_VOLUME}"
+[ -f .gamely-layout ] && exit 0
+mkdir -p config server
+for entry in * .[!.]* ..?*; do
+  [ -e "${entry}" ] || continue
+  case "${entry}" in
+    config|server|lost+found|.gamely-layout) continue ;;
+    worlds|worlds_local) target=config ;;
+    *) target=server ;;
+  esac
+  if [ -e "${target}/${entry}" ]; then
+    echo "Not moving ${entry}, ${target}/${entry} already exists"
+    continue
+  fi
+  echo "Moving ${entry} into ${target}"
+  mv "${entry}" "${target}/"
+done
+touch .gamely-layout
+`
+
+// worldLayout is the init container moving the world volume into its sub
+// paths before the server mounts them
+func worldLayout() v1.Container {
+	return v1.Container{
+		Name:         worldLayoutContainer,
+		Image:        "busybox",
+		Env:          []v1.EnvVar{{Name: "WORLD_VOLUME", Value: worldLayoutPath}},
+		Command:      []string{"sh", "-c"},
+		Args:         []string{worldLayoutScript},
+		VolumeMounts: []v1.VolumeMount{{Name: "worlddata", MountPath: worldLayoutPath}},
+	}
+}
+
+// claimResize is the state of a claim whose size does not match the spec
+type claimResize struct {
+	reason  string
+	message string
+}
+
+// reconcileClaimSize grows claim to size when the spec asks for more storage
+// and its storage class allows expansion. Claims are never shrunk. Progress
+// and anything stopping the resize is recorded for the StorageResized condition.
+func (s *Scope) reconcileClaimSize(ctx context.Context, claim *v1.PersistentVolumeClaim, size string) error {
+	desired, err := resource.ParseQuantity(size)
+	if err != nil {
+		return err
+	}
+	requested := claim.Spec.Resources.Requests[v1.ResourceStorage]
+
+	switch requested.Cmp(desired) {
+	case 1:
+		s.resize("ShrinkRejected", fmt.Sprintf("claim %s requests %s, volumes cannot shrink to %s", claim.Name, requested.String(), desired.String()))
+		return nil
+	case -1:
+		expandable, err := s.expandable(ctx, claim)
+		if err != nil {
+			return err
+		}
+		if !expandable {
+			s.resize("ExpansionNotSupported", fmt.Sprintf("storage class of claim %s does not allow volume expansion", claim.Name))
+			return nil
+		}
+
+		s.Logger.Info("expanding persistent volume claim", "claim", claim.Name, "from", requested.String(), "to", desired.String())
+		patch := client.MergeFrom(claim.DeepCopy())
+		claim.Spec.Resources.Requests[v1.ResourceStorage] = desired
+		if err := s.Client.Patch(ctx, claim, patch, client.FieldOwner(FieldManager)); err != nil {
+			return err
+		}
+		s.Recorder.Eventf(s.Valheim, v1.EventTypeNormal, EventReasonResizing, "expanding persistent volume claim %s from %s to %s", claim.Name, requested.String(), desired.String())
+		s.resize("Resizing", fmt.Sprintf("claim %s is being expanded to %s", claim.Name, desired.String()))
+		return nil
+	}
+
+	for _, condition := range claim.Status.Conditions {
+		if condition.Status != v1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case v1.PersistentVolumeClaimFileSystemResizePending:
+			s.resize("FileSystemResizePending", fmt.Sprintf("waiting for the file system of claim %s to be expanded", claim.Name))
+			return s.restartForResize(ctx, claim, condition.LastTransitionTime)
+		case v1.PersistentVolumeClaimResizing:
+			s.resize("Resizing", fmt.Sprintf("claim %s is being expanded to %s", claim.Name, desired.String()))
+			return nil
+		}
+	}
+
+	if capacity, ok := claim.Status.Capacity[v1.ResourceStorage]; ok && capacity.Cmp(desired) < 0 {
+		s.resize("Resizing", fmt.Sprintf("claim %s is being expanded to %s", claim.Name, desired.String()))
+	}
+	return nil
+}
+
+// expandable reports whether the storage class of claim allows volume expansion
+func (s *Scope) expandable(ctx context.Context, claim *v1.PersistentVolumeClaim) (bool, error) {
+	if claim.Spec.StorageClassName == nil || *claim.Spec.StorageClassName == "" {
+		return false, nil
+	}
+	class := &storagev1.StorageClass{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Name: *claim.Spec.StorageClassName}, class); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return class.AllowVolumeExpansion != nil && *class.AllowVolumeExpansion, nil
+}
+
+// restartForResize deletes the server pods that have had claim mounted since
+// before its file system resize became pending, once the kubelet has had time
+// to grow it online. The volume is expanded when the new pod mounts it.
+func (s *Scope) restartForResize(ctx context.Context, claim *v1.PersistentVolumeClaim, pendingSince metav1.Time) error {
+	if time.Since(pendingSince.Time) < fileSystemResizeGracePeriod {
+		return nil
+	}
+
+	pods := &v1.PodList{}
+	if err := s.Client.List(ctx, pods, client.InNamespace(s.Valheim.Namespace), client.MatchingLabels(s.labels)); err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.GetDeletionTimestamp() != nil || !pod.CreationTimestamp.Before(&pendingSince) || !mountsClaim(pod, claim.Name) {
+			continue
+		}
+		s.Logger.Info("restarting server to finish file system resize", "pod", pod.Name, "claim", claim.Name)
+		if err := s.Client.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		s.Recorder.Eventf(s.Valheim, v1.EventTypeNormal, EventReasonResizeRestart, "restarted pod %s to finish expanding the file system of claim %s", pod.Name, claim.Name)
+	}
+	return nil
+}
+
+func mountsClaim(pod *v1.Pod, claim string) bool {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == claim {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scope) resize(reason string, message string) {
+	s.resizes = append(s.resizes, claimResize{reason: reason, message: message})
+	if reason != "ShrinkRejected" && reason != "ExpansionNotSupported" {
+		return
+	}
+	// Only tell about a rejected resize once, rather than on every reconcile
+	condition := meta.FindStatusCondition(s.Valheim.Status.Conditions, v1alpha2.ConditionStorageResized)
+	if condition == nil || condition.Reason != reason {
+		s.Recorder.Event(s.Valheim, v1.EventTypeWarning, EventReasonResizeRejected, message)
+	}
+}
+
+// setStorageResizedCondition reports claims whose size does not match the
+// spec yet, returning how long to wait before checking on them again.
+// Rejected resizes take precedence over those still in progress.
+func (s *Scope) setStorageResizedCondition() time.Duration {
+	if len(s.resizes) == 0 {
+		s.setCondition(v1alpha2.ConditionStorageResized, metav1.ConditionTrue, "SizesMatch", "all persistent volume claims match the requested sizes")
+		return 0
+	}
+
+	reason := s.resizes[0].reason
+	messages := []string{}
+	for _, resize := range s.resizes {
+		if resize.reason == "ShrinkRejected" || resize.reason == "ExpansionNotSupported" {
+			reason = resize.reason
+		}
+		messages = append(messages, resize.message)
+	}
+	s.setCondition(v1alpha2.ConditionStorageResized, metav1.ConditionFalse, reason, strings.Join(messages, "; "))
+	if reason == "ShrinkRejected" || reason == "ExpansionNotSupported" {
+		return 0
+	}
+	return resizeRequeueInterval
+}
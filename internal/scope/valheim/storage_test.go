@@ -0,0 +1,171 @@
+package valheim
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/robwittman/gamely/api/v1alpha2"
+	"github.com/robwittman/gamely/internal/util"
+)
+
+var _ = ginkgo.Describe("reconcileClaimSize", func() {
+	var recorder *record.FakeRecorder
+
+	ginkgo.BeforeEach(func() {
+		recorder = record.NewFakeRecorder(10)
+	})
+
+	expandable := func(allow bool) *storagev1.StorageClass {
+		return &storagev1.StorageClass{
+			ObjectMeta:           metav1.ObjectMeta{Name: "standard"},
+			Provisioner:          "example.com/csi",
+			AllowVolumeExpansion: util.BoolAddr(allow),
+		}
+	}
+
+	// worldClaim is the world claim requesting size, provisioned with the standard class
+	worldClaim := func(size string, conditions ...v1.PersistentVolumeClaimCondition) *v1.PersistentVolumeClaim {
+		class := "standard"
+		return &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world"},
+			Spec: v1.PersistentVolumeClaimSpec{
+				StorageClassName: &class,
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+				},
+			},
+			Status: v1.PersistentVolumeClaimStatus{
+				Phase:      v1.ClaimBound,
+				Capacity:   v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+				Conditions: conditions,
+			},
+		}
+	}
+
+	scope := func(objects ...client.Object) *Scope {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(v1alpha2.AddToScheme(scheme)).To(Succeed())
+		return &Scope{
+			Logger:   logr.Discard(),
+			Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+			Recorder: recorder,
+			Valheim: &v1alpha2.Valheim{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world", Generation: 1},
+			},
+			labels: map[string]string{"app": "world"},
+		}
+	}
+
+	requested := func(s *Scope) string {
+		stored := &v1.PersistentVolumeClaim{}
+		Expect(s.Client.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "world"}, stored)).To(Succeed())
+		return stored.Spec.Resources.Requests.Storage().String()
+	}
+
+	ginkgo.DescribeTable("resizes claims to the spec",
+		func(class *storagev1.StorageClass, claim *v1.PersistentVolumeClaim, size string, reason string, stored string, requeue time.Duration) {
+			objects := []client.Object{claim}
+			if class != nil {
+				objects = append(objects, class)
+			}
+			s := scope(objects...)
+			Expect(s.reconcileClaimSize(context.Background(), claim, size)).To(Succeed())
+			Expect(s.setStorageResizedCondition()).To(Equal(requeue))
+			Expect(conditionReason(s, v1alpha2.ConditionStorageResized)).To(Equal(reason))
+			Expect(requested(s)).To(Equal(stored))
+		},
+		ginkgo.Entry("leaving a claim of the requested size", expandable(true), worldClaim("5Gi"), "5Gi", "SizesMatch", "5Gi", time.Duration(0)),
+		ginkgo.Entry("expanding a claim whose class allows it", expandable(true), worldClaim("5Gi"), "10Gi", "Resizing", "10Gi", resizeRequeueInterval),
+		ginkgo.Entry("rejecting a claim whose class does not allow it", expandable(false), worldClaim("5Gi"), "10Gi", "ExpansionNotSupported", "5Gi", time.Duration(0)),
+		ginkgo.Entry("rejecting a claim whose class is gone", nil, worldClaim("5Gi"), "10Gi", "ExpansionNotSupported", "5Gi", time.Duration(0)),
+		ginkgo.Entry("rejecting shrinking a claim", expandable(true), worldClaim("10Gi"), "5Gi", "ShrinkRejected", "10Gi", time.Duration(0)),
+		ginkgo.Entry("waiting on a claim being resized", expandable(true), worldClaim("10Gi", v1.PersistentVolumeClaimCondition{
+			Type:   v1.PersistentVolumeClaimResizing,
+			Status: v1.ConditionTrue,
+		}), "10Gi", "Resizing", "10Gi", resizeRequeueInterval),
+		ginkgo.Entry("waiting on the file system of a claim", expandable(true), worldClaim("10Gi", v1.PersistentVolumeClaimCondition{
+			Type:               v1.PersistentVolumeClaimFileSystemResizePending,
+			Status:             v1.ConditionTrue,
+			LastTransitionTime: metav1.Now(),
+		}), "10Gi", "FileSystemResizePending", "10Gi", resizeRequeueInterval),
+	)
+
+	ginkgo.It("waits on a claim whose capacity has not caught up", func() {
+		claim := worldClaim("10Gi")
+		claim.Status.Capacity[v1.ResourceStorage] = resource.MustParse("5Gi")
+		s := scope(claim, expandable(true))
+		Expect(s.reconcileClaimSize(context.Background(), claim, "10Gi")).To(Succeed())
+		s.setStorageResizedCondition()
+		Expect(conditionReason(s, v1alpha2.ConditionStorageResized)).To(Equal("Resizing"))
+	})
+
+	ginkgo.It("warns about a rejected resize once", func() {
+		claim := worldClaim("10Gi")
+		s := scope(claim, expandable(true))
+		for i := 0; i < 2; i++ {
+			s.resizes = nil
+			Expect(s.reconcileClaimSize(context.Background(), claim, "5Gi")).To(Succeed())
+			s.setStorageResizedCondition()
+		}
+		Expect(recorder.Events).To(Receive(ContainSubstring(EventReasonResizeRejected)))
+		Expect(recorder.Events).NotTo(Receive())
+		Expect(meta.IsStatusConditionFalse(s.Valheim.Status.Conditions, v1alpha2.ConditionStorageResized)).To(BeTrue())
+	})
+
+	ginkgo.It("restarts the pods that mounted a claim before its file system resize became pending", func() {
+		pendingSince := metav1.NewTime(time.Now().Add(-fileSystemResizeGracePeriod * 2))
+		claim := worldClaim("10Gi", v1.PersistentVolumeClaimCondition{
+			Type:               v1.PersistentVolumeClaimFileSystemResizePending,
+			Status:             v1.ConditionTrue,
+			LastTransitionTime: pendingSince,
+		})
+		pod := func(name string, created time.Time, claimName string) *v1.Pod {
+			return &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:         "default",
+					Name:              name,
+					Labels:            map[string]string{"app": "world"},
+					CreationTimestamp: metav1.NewTime(created),
+				},
+				Spec: v1.PodSpec{Volumes: []v1.Volume{{
+					Name:         "worlddata",
+					VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claimName}},
+				}}},
+			}
+		}
+		s := scope(
+			claim,
+			expandable(true),
+			pod("world-0", pendingSince.Add(-time.Hour), "world"),
+			pod("world-1", time.Now(), "world"),
+			pod("other-0", pendingSince.Add(-time.Hour), "other"),
+		)
+		Expect(s.reconcileClaimSize(context.Background(), claim, "10Gi")).To(Succeed())
+
+		pods := &v1.PodList{}
+		Expect(s.Client.List(context.Background(), pods)).To(Succeed())
+		names := []string{}
+		for _, pod := range pods.Items {
+			names = append(names, pod.Name)
+		}
+		Expect(names).To(ConsistOf("world-1", "other-0"))
+		Expect(recorder.Events).To(Receive(ContainSubstring(EventReasonResizeRestart)))
+	})
+})
@@ -3,19 +3,26 @@ package valheim
 import (
 	"context"
 	"github.com/go-logr/logr"
-	"github.com/robwittman/gamely/api/v1alpha1"
+	"github.com/robwittman/gamely/api/v1alpha2"
+	"github.com/robwittman/gamely/internal/a2s"
+	"github.com/robwittman/gamely/internal/thunderstore"
 	"github.com/robwittman/gamely/internal/util"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -25,13 +32,13 @@ const (
 	EnvVarServerArgs       = "SERVER_ARGS"
 	EnvVarServerPublic     = "SERVER_PUBLIC"
 	EnvVarUpdateCron       = "UPDATE_CRON"
+	EnvVarBackups          = "BACKUPS"
 	EnvVarBackupCron       = "BACKUPS_CRON"
 	EnvVarBackupsIdle      = "BACKUPS_IF_IDLE"
 	EnvVarBackupsMax       = "BACKUPS_MAX_COUNT"
+	EnvVarBackupsMaxAge    = "BACKUPS_MAX_AGE"
+	EnvVarBackupsZip       = "BACKUPS_ZIP"
 	EnvVarBackupsDirectory = "BACKUPS_DIRECTORY"
-	EnvVarAdminList        = "ADMINLIST_IDS"
-	EnvVarBannedList       = "BANNEDLIST_IDS"
-	EnvVarPermittedList    = "PERMITTEDLIST_IDS"
 
 	EnvVarPreSupervisorHook       = "PRE_SUPERVISOR_HOOK"
 	EnvVarPreBootstrapHook        = "PRE_BOOTSTRAP_HOOK"
@@ -55,14 +62,66 @@ const (
 
 	EnvVarBepinEx     = "BEPINEX"
 	EnvVarValheimPlus = "VALHEIM_PLUS"
+
+	// AnnotationPausedReplicas records the replica count a paused statefulset
+	// should be scaled back to once the server is unpaused
+	AnnotationPausedReplicas = "gamely.io/paused-replicas"
+
+	// EventReasonDrifted is emitted when an owned resource no longer matched
+	// the desired state and had to be repaired
+	EventReasonDrifted = "Drifted"
+	// EventReasonRetained is emitted for storage kept around by the Retain deletion policy
+	EventReasonRetained = "Retained"
+	// EventReasonFinalBackup and EventReasonFinalBackupFailed track the backup
+	// taken by the BackupThenDelete deletion policy
+	EventReasonFinalBackup       = "FinalBackup"
+	EventReasonFinalBackupFailed = "FinalBackupFailed"
+
+	// AnnotationReportedFailures records how many failures of a final backup
+	// job have been reported, so each is only reported once
+	AnnotationReportedFailures = "gamely.io/reported-failures"
+
+	// Finalizer blocks deletion of a Valheim until its deletion policy has been carried out
+	Finalizer = "gamely.io/finalizer"
+
+	// ServerTerminationGracePeriod gives the server time to save the world
+	// before it is killed, matching the --stop-timeout recommended upstream
+	ServerTerminationGracePeriod int64 = 120
 )
 
 type Scope struct {
-	Logger  logr.Logger
-	Client  client.Client
-	Valheim *v1alpha1.Valheim
-
-	labels map[string]string
+	Logger   logr.Logger
+	Client   client.Client
+	Recorder record.EventRecorder
+	Valheim  *v1alpha2.Valheim
+	// Executor runs the backup hooks in the server around volume snapshots.
+	// Hooks are skipped when it is nil.
+	Executor util.PodExecutor
+	// Thunderstore resolves the dependencies of mod packages. Without it the
+	// requested packages are installed as listed.
+	Thunderstore *thunderstore.Client
+	// A2S queries the running server for its players. The server is taken to
+	// be listening once its pod is ready when it is nil.
+	A2S *a2s.Client
+
+	labels  map[string]string
+	resizes []claimResize
+	modsErr error
+	// snapshotErr is why volume snapshots cannot be taken
+	snapshotErr error
+	// modsRetryAfter is when resolving mods that failed is retried
+	modsRetryAfter time.Duration
+	// valheimPlusConfig is the valheim_plus.cfg the server is started with,
+	// and pluginConfig its plugin config files keyed by file name. configErr
+	// is why config of the spec could not be rendered.
+	valheimPlusConfig *string
+	pluginConfig      map[string]string
+	configErr         error
+	passwordErr       *passwordError
+	// passwordRotatedAt is when the server password was last rotated
+	passwordRotatedAt string
+	// queryErr is why the running server did not answer its last query
+	queryErr error
 }
 
 func (s *Scope) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -70,31 +129,188 @@ func (s *Scope) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, e
 		return s.reconcileDelete(ctx, req)
 	}
 
-	// If generation has changed, re-apply
-	if s.Valheim.Generation > s.Valheim.Status.ObservedGeneration {
-		return s.reconcileUpdate(ctx, req)
+	if !controllerutil.ContainsFinalizer(s.Valheim, Finalizer) {
+		patch := client.MergeFrom(s.Valheim.DeepCopy())
+		controllerutil.AddFinalizer(s.Valheim, Finalizer)
+		if err := s.Client.Patch(ctx, s.Valheim, patch); err != nil {
+			s.Logger.Error(err, "failed adding finalizer")
+			return ctrl.Result{}, err
+		}
 	}
-	return ctrl.Result{}, nil
+
+	// Always compare the desired state against what is running, so that
+	// owned resources edited or deleted out from under us get repaired
+	return s.reconcileUpdate(ctx, req)
 }
 
 func (s *Scope) reconcileDelete(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(s.Valheim, Finalizer) {
+		return ctrl.Result{}, nil
+	}
+	s.labels = Labels(s.Valheim)
+
+	if s.Valheim.Status.Phase != v1alpha2.ValheimPhaseTerminating {
+		s.Valheim.Status.Phase = v1alpha2.ValheimPhaseTerminating
+		if err := s.Client.Status().Update(ctx, s.Valheim); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	switch s.Valheim.Spec.DeletionPolicy {
+	case v1alpha2.DeletionPolicyRetain:
+		if err := s.orphanWorldStorage(ctx, req); err != nil {
+			s.Logger.Error(err, "failed orphaning world storage")
+			return ctrl.Result{}, err
+		}
+	case v1alpha2.DeletionPolicyBackupThenDelete:
+		done, err := s.reconcileFinalBackup(ctx, req)
+		if err != nil {
+			s.Logger.Error(err, "failed taking final backup")
+			return ctrl.Result{}, err
+		}
+		if !done {
+			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+		}
+	}
+
+	patch := client.MergeFrom(s.Valheim.DeepCopy())
+	controllerutil.RemoveFinalizer(s.Valheim, Finalizer)
+	if err := s.Client.Patch(ctx, s.Valheim, patch); err != nil {
+		s.Logger.Error(err, "failed removing finalizer")
+		return ctrl.Result{}, err
+	}
 	return ctrl.Result{}, nil
 }
 
-func (s *Scope) reconcileUpdate(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	s.labels = s.makeLabels()
+// orphanWorldStorage drops our owner reference from the world and backup
+// volume claims and the volume snapshots, so they are left behind when the
+// Valheim is garbage collected
+func (s *Scope) orphanWorldStorage(ctx context.Context, req ctrl.Request) error {
+	for _, name := range []string{req.Name, req.Name + "-backups"} {
+		if err := s.orphanClaim(ctx, req, name); err != nil {
+			return err
+		}
+	}
+	return s.orphanSnapshots(ctx, req)
+}
 
-	serviceaccount := &v1.ServiceAccount{}
-	if err := s.Client.Get(ctx, req.NamespacedName, serviceaccount); err != nil {
+// orphanClaim drops our owner reference from a volume claim
+func (s *Scope) orphanClaim(ctx context.Context, req ctrl.Request, name string) error {
+	claim := &v1.PersistentVolumeClaim{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: name}, claim); err != nil {
 		if errors.IsNotFound(err) {
-			return s.reconcileServiceAccount(ctx, req)
+			return nil
+		}
+		return err
+	}
+
+	owners := []metav1.OwnerReference{}
+	for _, owner := range claim.OwnerReferences {
+		if owner.UID != s.Valheim.UID {
+			owners = append(owners, owner)
+		}
+	}
+	if len(owners) == len(claim.OwnerReferences) {
+		return nil
+	}
+
+	patch := client.MergeFrom(claim.DeepCopy())
+	claim.OwnerReferences = owners
+	if err := s.Client.Patch(ctx, claim, patch); err != nil {
+		return err
+	}
+	s.Recorder.Eventf(s.Valheim, v1.EventTypeNormal, EventReasonRetained, "retained persistent volume claim %s", name)
+	return nil
+}
+
+// reconcileFinalBackup shuts the server down and archives its world with a
+// job, reporting whether the backup has finished and deletion can continue.
+// A failed backup blocks deletion until the policy is changed to Delete.
+// Without a bucket the archive only exists on the backups volume, so its
+// claim is retained.
+func (s *Scope) reconcileFinalBackup(ctx context.Context, req ctrl.Request) (bool, error) {
+	statefulSet := &appsv1.StatefulSet{}
+	if err := s.Client.Get(ctx, req.NamespacedName, statefulSet); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+	} else {
+		if statefulSet.Spec.Replicas == nil || *statefulSet.Spec.Replicas > 0 {
+			patch := client.MergeFrom(statefulSet.DeepCopy())
+			statefulSet.Spec.Replicas = util.Int32Addr(0)
+			if err := s.Client.Patch(ctx, statefulSet, patch, client.FieldOwner(FieldManager)); err != nil {
+				return false, err
+			}
 		}
+		if statefulSet.Status.Replicas > 0 {
+			s.Logger.Info("waiting for server to shut down before final backup")
+			return false, nil
+		}
+	}
 
-		s.Logger.Error(err, "failed querying service account")
+	job := &batchv1.Job{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name + "-final-backup"}, job); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		job = NewBackupJob(s.Valheim, req.Name+"-final-backup", BackupJobOptions{Upload: true})
+		if _, err := s.apply(ctx, job); err != nil {
+			return false, err
+		}
+		s.Recorder.Event(s.Valheim, v1.EventTypeNormal, EventReasonFinalBackup, "started final world backup")
+		return false, nil
+	}
+
+	finished, result := JobFinished(job)
+	if !finished {
+		return false, nil
+	}
+	if result == batchv1.JobFailed {
+		failures := strconv.Itoa(int(job.Status.Failed))
+		if job.Annotations[AnnotationReportedFailures] == failures {
+			return false, nil
+		}
+		s.Recorder.Event(s.Valheim, v1.EventTypeWarning, EventReasonFinalBackupFailed, "final world backup failed, set spec.deletionPolicy to Delete to delete without a backup")
+		patch := client.MergeFrom(job.DeepCopy())
+		if job.Annotations == nil {
+			job.Annotations = map[string]string{}
+		}
+		job.Annotations[AnnotationReportedFailures] = failures
+		return false, s.Client.Patch(ctx, job, patch)
+	}
+
+	if s.Valheim.Spec.Backups.Bucket == "" {
+		if err := s.orphanClaim(ctx, req, req.Name+"-backups"); err != nil {
+			return false, err
+		}
+	}
+	s.Recorder.Event(s.Valheim, v1.EventTypeNormal, EventReasonFinalBackup, "final world backup completed")
+	return true, nil
+}
+
+func (s *Scope) reconcileUpdate(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	s.labels = Labels(s.Valheim)
+	status := s.Valheim.Status.DeepCopy()
+
+	if err := s.reconcileServiceAccount(ctx, req); err != nil {
+		s.Logger.Error(err, "failed reconciling service account")
+		return ctrl.Result{}, err
+	}
+
+	if err := s.reconcilePassword(ctx, req); err != nil {
+		s.Logger.Error(err, "failed reconciling server password")
+		return ctrl.Result{}, err
+	}
+	rotationAfter, err := s.reconcilePasswordRotation(ctx, req)
+	if err != nil {
+		s.Logger.Error(err, "failed rotating server password")
 		return ctrl.Result{}, err
 	}
 
-	// TODO: Store secret information in secrets. duh
+	if err := s.reconcileAccess(ctx, req); err != nil {
+		s.Logger.Error(err, "failed reconciling access lists")
+		return ctrl.Result{}, err
+	}
 
 	// Ensure our storage PVC(s) exist
 	_, pvc, err := s.reconcileStorage(ctx, req)
@@ -103,23 +319,41 @@ func (s *Scope) reconcileUpdate(ctx context.Context, req ctrl.Request) (ctrl.Res
 		return ctrl.Result{}, err
 	}
 
-	_, err = s.reconcileBackupVolume(ctx, req)
+	backupPvc, err := s.reconcileBackupVolume(ctx, req)
 	if err != nil {
 		s.Logger.Error(err, "failed reconciling backup volume")
 		return ctrl.Result{}, err
 	}
+	claims := []*v1.PersistentVolumeClaim{pvc, backupPvc}
 
 	if s.Valheim.Spec.Mods.Enabled {
-		_, err = s.reconcileModStorage(ctx, req)
+		modPvc, err := s.reconcileModStorage(ctx, req)
 		if err != nil {
 			s.Logger.Error(err, "failed reconciling mod storage")
 			return ctrl.Result{}, err
 		}
+		claims = append(claims, modPvc)
+		if s.Valheim.Spec.Mods.Cache != nil {
+			cachePvc, err := s.reconcileModCache(ctx, req)
+			if err != nil {
+				s.Logger.Error(err, "failed reconciling mod cache")
+				return ctrl.Result{}, err
+			}
+			claims = append(claims, cachePvc)
+		}
 		_, err = s.reconcileMods(ctx, req)
 		if err != nil {
 			s.Logger.Error(err, "failed reconciling mod configuration")
 			return ctrl.Result{}, err
 		}
+		if s.usesValheimPlus() {
+			if err := s.reconcileValheimPlusConfig(ctx, req); err != nil {
+				s.Logger.Error(err, "failed reconciling valheim plus configuration")
+				return ctrl.Result{}, err
+			}
+		}
+	} else {
+		s.Valheim.Status.Mods = v1alpha2.ValheimModsStatus{}
 	}
 
 	// Reconcile our statefulset
@@ -129,48 +363,110 @@ func (s *Scope) reconcileUpdate(ctx context.Context, req ctrl.Request) (ctrl.Res
 		return ctrl.Result{}, err
 	}
 
-	_, _, err = s.reconcileService(ctx, statefulset)
+	if err := s.reconcileReplicas(ctx, statefulset); err != nil {
+		s.Logger.Error(err, "failed scaling statefulset")
+		return ctrl.Result{}, err
+	}
+
+	_, service, err := s.reconcileService(ctx, statefulset)
 	if err != nil {
 		s.Logger.Error(err, "failed reconciling service")
 		return ctrl.Result{}, err
 	}
 
-	s.Valheim.Status.Ready = true
-	s.Valheim.Status.ObservedGeneration = s.Valheim.Generation
-	if err := s.Client.Status().Update(ctx, s.Valheim); err != nil {
+	uploadCronJob, err := s.reconcileBackupUpload(ctx, req)
+	if err != nil {
+		s.Logger.Error(err, "failed reconciling backup upload")
 		return ctrl.Result{}, err
 	}
-	return ctrl.Result{}, nil
+
+	running := !s.Valheim.Stopped() && statefulset.Status.ReadyReplicas > 0
+	indexAfter, err := s.reconcileBackupIndex(ctx, req, running)
+	if err != nil {
+		s.Logger.Error(err, "failed recording scheduled backups")
+		return ctrl.Result{}, err
+	}
+
+	snapshotAfter, err := s.reconcileSnapshots(ctx, req, running)
+	if err != nil {
+		s.Logger.Error(err, "failed reconciling volume snapshots")
+		return ctrl.Result{}, err
+	}
+
+	requeueAfter, err := s.reconcileStatus(ctx, statefulset, service, claims, uploadCronJob)
+	if err != nil {
+		s.Logger.Error(err, "failed reconciling status")
+		return ctrl.Result{}, err
+	}
+	for _, after := range []time.Duration{indexAfter, snapshotAfter, rotationAfter, s.modsRetryAfter} {
+		if after > 0 && (requeueAfter == 0 || after < requeueAfter) {
+			requeueAfter = after
+		}
+	}
+
+	s.Valheim.Status.WorldStorage = pvc.Name
+	s.Valheim.Status.ObservedGeneration = s.Valheim.Generation
+	if !equality.Semantic.DeepEqual(status, &s.Valheim.Status) {
+		if err := s.Client.Status().Update(ctx, s.Valheim); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// recordDrift emits a Drifted event when an owned object had to be repaired
+// even though the Valheim spec has not changed since it was last applied
+func (s *Scope) recordDrift(kind string, name string, action string) {
+	if s.Valheim.Status.ObservedGeneration == 0 || s.Valheim.Status.ObservedGeneration != s.Valheim.Generation {
+		return
+	}
+	s.Recorder.Eventf(s.Valheim, v1.EventTypeWarning, EventReasonDrifted, "%s %s %s to match the desired state", action, kind, name)
 }
 
-func (s *Scope) reconcileServiceAccount(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (s *Scope) reconcileServiceAccount(ctx context.Context, req ctrl.Request) error {
 	serviceAccount := &v1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      req.Name,
 			Namespace: req.Namespace,
 		},
 	}
-	if err := controllerutil.SetOwnerReference(s.Valheim, serviceAccount, s.Client.Scheme()); err != nil {
-		s.Logger.Error(err, "failed setting owner reference on serviceaccount")
-	}
-	err := s.Client.Create(ctx, serviceAccount)
-	if err != nil {
-		return ctrl.Result{}, err
-	}
-	return ctrl.Result{Requeue: true}, nil
+	_, err := s.apply(ctx, serviceAccount)
+	return err
 }
 
 func (s *Scope) reconcileMods(ctx context.Context, req ctrl.Request) (*v1.ConfigMap, error) {
-	configMapData := map[string]string{}
-	registry := []string{}
-	for pkg, conf := range s.Valheim.Spec.Mods.Packages {
-		if conf.Config != "" {
-			configMapData[strings.Replace(pkg, "/", ".", -1)] = conf.Config
+	s.resolveMods(ctx)
+	registry := s.modRegistry()
+	sort.Strings(registry)
+
+	existing := &v1.ConfigMap{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name + "-mods"}, existing); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
 		}
-		registry = append(registry, pkg+":"+conf.Version)
+	} else if diff := modRegistryDiff(strings.Split(existing.Data["registry.txt"], "\n"), registry); diff != "" {
+		s.Recorder.Eventf(s.Valheim, v1.EventTypeNormal, EventReasonModsChanged, "mods changed, restarting the server to sync them: %s", diff)
+	}
+
+	plans, err := s.modSourcePlans()
+	if err != nil {
+		s.Logger.Error(err, "failed planning mod sources")
+		s.modsErr = err
+	}
+
+	pluginConfig, err := pluginConfigFiles(s.Valheim.Spec.Mods.Packages, existing.Data)
+	if err != nil {
+		s.Logger.Error(err, "invalid plugin config")
+		s.reportInvalidConfig(EventReasonPluginConfigInvalid, err)
 	}
+	s.pluginConfig = pluginConfig
 
+	configMapData := map[string]string{}
+	for file, contents := range pluginConfig {
+		configMapData[file] = contents
+	}
 	configMapData["registry.txt"] = strings.Join(registry, "\n")
+	configMapData["sources.txt"] = modSourceList(plans)
 	configMap := &v1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: req.Namespace,
@@ -178,24 +474,10 @@ func (s *Scope) reconcileMods(ctx context.Context, req ctrl.Request) (*v1.Config
 		},
 		Data: configMapData,
 	}
-	if err := s.Client.Get(ctx, types.NamespacedName{
-		Namespace: req.Namespace,
-		Name:      req.Name + "-mods",
-	}, &v1.ConfigMap{}); err != nil {
-		if errors.IsNotFound(err) {
-			s.Logger.Info("creating configmap")
-			err := s.Client.Create(ctx, configMap)
-			if err != nil {
-				return nil, err
-			}
-			return configMap, nil
-		}
+	if _, err := s.apply(ctx, configMap); err != nil {
 		return nil, err
 	}
-
-	s.Logger.Info("updating configmap")
-	err := s.Client.Update(ctx, configMap)
-	return configMap, err
+	return configMap, nil
 }
 
 func (s *Scope) reconcileModStorage(ctx context.Context, req ctrl.Request) (*v1.PersistentVolumeClaim, error) {
@@ -207,19 +489,13 @@ func (s *Scope) reconcileModStorage(ctx context.Context, req ctrl.Request) (*v1.
 	if err != nil {
 		return nil, err
 	}
-	if err := controllerutil.SetOwnerReference(s.Valheim, storage, s.Client.Scheme()); err != nil {
-		s.Logger.Error(err, "failed setting controller reference on persistentvolumeclaim")
-	}
-
 	existingPvc := &v1.PersistentVolumeClaim{}
 	if err := s.Client.Get(ctx, types.NamespacedName{
 		Namespace: req.Namespace,
 		Name:      req.Name + "-mods",
 	}, existingPvc); err != nil {
 		if errors.IsNotFound(err) {
-			s.Logger.Info("creating worlddata pvc")
-			err := s.Client.Create(ctx, storage)
-			if err != nil {
+			if _, err := s.apply(ctx, storage); err != nil {
 				return nil, err
 			}
 			return storage, nil
@@ -227,40 +503,66 @@ func (s *Scope) reconcileModStorage(ctx context.Context, req ctrl.Request) (*v1.
 		return nil, err
 	}
 
-	if existingPvc.Spec.Resources.Requests[v1.ResourceStorage] != resource.MustParse(s.Valheim.Spec.Mods.Storage.Size) {
-		s.Logger.Info("mods pvc needs to be resized...")
+	if err := s.reconcileClaimSize(ctx, existingPvc, s.Valheim.Spec.Mods.Storage.Size); err != nil {
+		return nil, err
 	}
 
 	return existingPvc, nil
 }
 
 func (s *Scope) reconcileStatefulSet(ctx context.Context, req ctrl.Request, claim *v1.PersistentVolumeClaim) (bool, *appsv1.StatefulSet, error) {
-
 	desiredStatefulSet, _ := s.makeStatefulSet(req)
-	if err := controllerutil.SetOwnerReference(s.Valheim, desiredStatefulSet, s.Client.Scheme()); err != nil {
-		s.Logger.Error(err, "failed setting controller reference on statefulset")
+	changed, err := s.apply(ctx, desiredStatefulSet)
+	if err != nil {
+		return false, nil, err
 	}
+	return changed, desiredStatefulSet, nil
+}
 
-	existingStatefulSet := &appsv1.StatefulSet{}
-	if err := s.Client.Get(ctx, req.NamespacedName, existingStatefulSet); err != nil {
-		if errors.IsNotFound(err) {
-			s.Logger.Info("creating new statefulset")
-			err := s.Client.Create(ctx, desiredStatefulSet)
-			if err != nil {
-				return false, nil, err
+// reconcileReplicas scales the statefulset to zero while the server is paused,
+// and back to its previous size once unpaused. Replicas are deliberately left
+// out of the applied statefulset so autoscalers can own them, which is why
+// this is a regular patch instead.
+func (s *Scope) reconcileReplicas(ctx context.Context, statefulSet *appsv1.StatefulSet) error {
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	previous, paused := statefulSet.Annotations[AnnotationPausedReplicas]
+
+	patched := statefulSet.DeepCopy()
+	if s.Valheim.Stopped() {
+		if paused && replicas == 0 {
+			return nil
+		}
+		if paused {
+			s.recordDrift("StatefulSet", statefulSet.Name, "scaled down")
+		} else {
+			if patched.Annotations == nil {
+				patched.Annotations = map[string]string{}
 			}
-			return true, desiredStatefulSet, nil
+			patched.Annotations[AnnotationPausedReplicas] = strconv.Itoa(int(replicas))
 		}
-
-		return false, nil, err
+		patched.Spec.Replicas = util.Int32Addr(0)
+	} else {
+		if !paused {
+			return nil
+		}
+		restored, err := strconv.Atoi(previous)
+		if err != nil {
+			s.Logger.Error(err, "failed parsing paused replica count", "annotation", previous)
+			restored = 1
+		}
+		delete(patched.Annotations, AnnotationPausedReplicas)
+		patched.Spec.Replicas = util.Int32Addr(int32(restored))
 	}
 
-	s.Logger.Info("updating statefulset")
-	err := s.Client.Update(ctx, desiredStatefulSet)
-	if err != nil {
-		return false, nil, err
+	s.Logger.Info("scaling statefulset", "replicas", *patched.Spec.Replicas)
+	if err := s.Client.Patch(ctx, patched, client.MergeFrom(statefulSet), client.FieldOwner(FieldManager)); err != nil {
+		return err
 	}
-	return true, desiredStatefulSet, nil
+	*statefulSet = *patched
+	return nil
 }
 
 func (s *Scope) reconcileStorage(ctx context.Context, req ctrl.Request) (bool, *v1.PersistentVolumeClaim, error) {
@@ -272,16 +574,15 @@ func (s *Scope) reconcileStorage(ctx context.Context, req ctrl.Request) (bool, *
 	if err != nil {
 		return false, nil, err
 	}
-	if err := controllerutil.SetOwnerReference(s.Valheim, storage, s.Client.Scheme()); err != nil {
-		s.Logger.Error(err, "failed setting controller reference on persistentvolumeclaim")
-	}
-
 	existingPvc := &v1.PersistentVolumeClaim{}
 	if err := s.Client.Get(ctx, req.NamespacedName, existingPvc); err != nil {
 		if errors.IsNotFound(err) {
-			s.Logger.Info("creating worlddata pvc")
-			err := s.Client.Create(ctx, storage)
-			if err != nil {
+			// A restore from a volume snapshot replaces the world volume
+			// itself, so leave creating it to the restore
+			if s.Valheim.Restoring() != "" {
+				return false, storage, nil
+			}
+			if _, err := s.apply(ctx, storage); err != nil {
 				return false, nil, err
 			}
 			return true, storage, nil
@@ -289,8 +590,8 @@ func (s *Scope) reconcileStorage(ctx context.Context, req ctrl.Request) (bool, *
 		return false, nil, err
 	}
 
-	if existingPvc.Spec.Resources.Requests[v1.ResourceStorage] != resource.MustParse(s.Valheim.Spec.Storage.Size) {
-		s.Logger.Info("pvc needs to be resized...")
+	if err := s.reconcileClaimSize(ctx, existingPvc, s.Valheim.Spec.Storage.Size); err != nil {
+		return false, nil, err
 	}
 
 	return false, existingPvc, nil
@@ -305,19 +606,13 @@ func (s *Scope) reconcileBackupVolume(ctx context.Context, req ctrl.Request) (*v
 	if err != nil {
 		return nil, err
 	}
-	if err := controllerutil.SetOwnerReference(s.Valheim, storage, s.Client.Scheme()); err != nil {
-		s.Logger.Error(err, "failed setting controller reference on persistentvolumeclaim")
-	}
-
 	existingPvc := &v1.PersistentVolumeClaim{}
 	if err := s.Client.Get(ctx, types.NamespacedName{
 		Namespace: req.Namespace,
 		Name:      req.Name + "-backups",
 	}, existingPvc); err != nil {
 		if errors.IsNotFound(err) {
-			s.Logger.Info("creating worlddata pvc")
-			err := s.Client.Create(ctx, storage)
-			if err != nil {
+			if _, err := s.apply(ctx, storage); err != nil {
 				return nil, err
 			}
 			return storage, nil
@@ -325,8 +620,8 @@ func (s *Scope) reconcileBackupVolume(ctx context.Context, req ctrl.Request) (*v
 		return nil, err
 	}
 
-	if existingPvc.Spec.Resources.Requests[v1.ResourceStorage] != resource.MustParse(s.Valheim.Spec.Backups.Storage.Size) {
-		s.Logger.Info("pvc needs to be resized...")
+	if err := s.reconcileClaimSize(ctx, existingPvc, s.Valheim.Spec.Backups.Storage.Size); err != nil {
+		return nil, err
 	}
 
 	return existingPvc, nil
@@ -334,34 +629,11 @@ func (s *Scope) reconcileBackupVolume(ctx context.Context, req ctrl.Request) (*v
 
 func (s *Scope) reconcileService(ctx context.Context, statefulSet *appsv1.StatefulSet) (bool, *v1.Service, error) {
 	desiredService, _ := s.makeService()
-	if err := controllerutil.SetOwnerReference(s.Valheim, desiredService, s.Client.Scheme()); err != nil {
-		s.Logger.Error(err, "failed setting owner reference on service")
-	}
-	existingService := &v1.Service{}
-	if err := s.Client.Get(ctx, s.Valheim.NamespacedName(), existingService); err != nil {
-		if errors.IsNotFound(err) {
-
-			if err := s.Client.Create(ctx, desiredService); err != nil {
-				return false, nil, err
-			}
-			return true, desiredService, nil
-		}
-		return false, nil, err
-	}
-
-	s.Logger.Info("updating service")
-	err := s.Client.Update(ctx, desiredService)
+	changed, err := s.apply(ctx, desiredService)
 	if err != nil {
 		return false, nil, err
 	}
-	return true, desiredService, nil
-}
-
-func (s *Scope) makeLabels() map[string]string {
-	return map[string]string{
-		"gamely.io": "valheim",
-		"server":    s.Valheim.Name,
-	}
+	return changed, desiredService, nil
 }
 
 func (s *Scope) makeEnvVars() []v1.EnvVar {
@@ -377,14 +649,8 @@ func (s *Scope) makeEnvVars() []v1.EnvVar {
 		},
 		{
 			Name: EnvVarServerPass,
-			// TODO: Source this from the spec
 			ValueFrom: &v1.EnvVarSource{
-				SecretKeyRef: &v1.SecretKeySelector{
-					Key: "password",
-					LocalObjectReference: v1.LocalObjectReference{
-						Name: s.Valheim.Name,
-					},
-				},
+				SecretKeyRef: s.passwordSelector(),
 			},
 		},
 		//{
@@ -393,10 +659,10 @@ func (s *Scope) makeEnvVars() []v1.EnvVar {
 		//},
 	}
 
-	if len(valSpec.Server.AdditionalArgs) > 0 {
+	if args := s.serverArgs(); len(args) > 0 {
 		envVars = append(envVars, v1.EnvVar{
 			Name:  EnvVarServerArgs,
-			Value: strings.Join(valSpec.Server.AdditionalArgs, " "),
+			Value: strings.Join(args, " "),
 		})
 	}
 
@@ -407,39 +673,21 @@ func (s *Scope) makeEnvVars() []v1.EnvVar {
 		})
 	}
 
-	if valSpec.Backups.Schedule != "" {
+	// In volumeSnapshot mode the operator takes the backups instead of the server
+	if valSpec.Backups.Schedule != "" && valSpec.Backups.GetMode() == v1alpha2.BackupModeArchive {
 		envVars = append(envVars, v1.EnvVar{
 			Name:  EnvVarBackupCron,
 			Value: valSpec.Backups.Schedule,
 		}, v1.EnvVar{
 			Name:  EnvVarBackupsIdle,
-			Value: "true",
-		}, v1.EnvVar{
-			Name:  EnvVarBackupsMax,
-			Value: "5",
-		})
-	}
-
-	// TODO: We'll probably want to move these access settings
-	// to a config map, and then mount that to the pods
-	if len(valSpec.Access.Admins) > 0 {
-		envVars = append(envVars, v1.EnvVar{
-			Name:  EnvVarAdminList,
-			Value: strings.Join(valSpec.Access.Admins, " "),
+			Value: strconv.FormatBool(valSpec.Backups.GetIfIdle()),
 		})
+		envVars = append(envVars, backupRetentionEnv(s.Valheim)...)
 	}
-
-	if len(valSpec.Access.Banned) > 0 {
+	if valSpec.Backups.GetMode() == v1alpha2.BackupModeVolumeSnapshot {
 		envVars = append(envVars, v1.EnvVar{
-			Name:  EnvVarBannedList,
-			Value: strings.Join(valSpec.Access.Banned, " "),
-		})
-	}
-
-	if len(valSpec.Access.Permitted) > 0 {
-		envVars = append(envVars, v1.EnvVar{
-			Name:  EnvVarPermittedList,
-			Value: strings.Join(valSpec.Access.Permitted, " "),
+			Name:  EnvVarBackups,
+			Value: "false",
 		})
 	}
 
@@ -457,27 +705,72 @@ func (s *Scope) makeEnvVars() []v1.EnvVar {
 		}
 	}
 
-	for env, value := range s.Valheim.FilteredHooksMap() {
-		envVars = append(envVars, v1.EnvVar{
-			Name:  env,
-			Value: value,
-		})
+	// Map iteration order is random, so sort these to keep the pod template
+	// stable between reconciles
+	envVars = appendSortedEnvVars(envVars, s.Valheim.FilteredHooksMap())
+	envVars = appendSortedEnvVars(envVars, valSpec.Server.AdditionalEnv)
+
+	return envVars
+}
+
+// serverArgs are the arguments the server is started with, the world modifiers
+// followed by spec.server.additionalArgs
+func (s *Scope) serverArgs() []string {
+	modifiers := s.Valheim.Spec.WorldModifiers
+	args := []string{}
+	if modifiers.Preset != "" {
+		args = append(args, "-preset", string(modifiers.Preset))
 	}
 
-	for envKey, envValue := range valSpec.Server.AdditionalEnv {
-		envVars = append(envVars, v1.EnvVar{
-			Name:  envKey,
-			Value: envValue,
-		})
+	values := modifiers.Modifiers()
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-modifier", name, values[name])
 	}
 
+	for _, key := range modifiers.Keys {
+		args = append(args, "-setkey", string(key))
+	}
+	return append(args, s.Valheim.Spec.Server.AdditionalArgs...)
+}
+
+// appendSortedEnvVars adds values to envVars in key order. Env vars are keyed
+// by name when applied, so a value for an existing name replaces it in place.
+func appendSortedEnvVars(envVars []v1.EnvVar, values map[string]string) []v1.EnvVar {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		envVar := v1.EnvVar{
+			Name:  key,
+			Value: values[key],
+		}
+		replaced := false
+		for i := range envVars {
+			if envVars[i].Name == key {
+				envVars[i] = envVar
+				replaced = true
+			}
+		}
+		if !replaced {
+			envVars = append(envVars, envVar)
+		}
+	}
 	return envVars
 }
 
 func (s *Scope) makeStatefulSet(req ctrl.Request) (*appsv1.StatefulSet, error) {
 	envVars := s.makeEnvVars()
 
-	initContainers := []v1.Container{}
+	initContainers := []v1.Container{worldLayout()}
+	var podAnnotations map[string]string
 	volumes := []v1.Volume{
 		{
 			Name: "worlddata",
@@ -497,13 +790,19 @@ func (s *Scope) makeStatefulSet(req ctrl.Request) (*appsv1.StatefulSet, error) {
 		},
 	}
 	volumeMounts := []v1.VolumeMount{
+		{
+			Name:      "worlddata",
+			MountPath: ConfigDirectory,
+			SubPath:   worldDataConfigPath,
+		},
 		{
 			Name:      "worlddata",
 			MountPath: "/opt/valheim",
+			SubPath:   worldDataServerPath,
 		},
 		{
 			Name:      "backups",
-			MountPath: "/config/backups",
+			MountPath: s.Valheim.Spec.Backups.GetDirectory(),
 		},
 	}
 	if s.Valheim.Spec.Mods.Enabled {
@@ -524,7 +823,7 @@ func (s *Scope) makeStatefulSet(req ctrl.Request) (*appsv1.StatefulSet, error) {
 				},
 			},
 		})
-		modPath := "/config/valheimplus"
+		modPath := valheimPlusPath
 		if s.Valheim.Spec.Mods.Framework == "bepinex" {
 			modPath = "/config/bepinex"
 		}
@@ -532,32 +831,77 @@ func (s *Scope) makeStatefulSet(req ctrl.Request) (*appsv1.StatefulSet, error) {
 			Name:      "mods",
 			MountPath: modPath,
 		})
+		configMounts, configChecksum := s.pluginConfigMounts(modPath)
+		volumeMounts = append(volumeMounts, configMounts...)
+
+		cacheVolume := v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}
+		if s.Valheim.Spec.Mods.Cache != nil {
+			cacheVolume = v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+					ClaimName: ModCacheClaim,
+				},
+			}
+		}
+		volumes = append(volumes, v1.Volume{Name: "mod-cache", VolumeSource: cacheVolume})
+
+		downloaderMounts := []v1.VolumeMount{
+			{Name: "mods", MountPath: modPath},
+			{Name: "mod-config", MountPath: "/config/mods"},
+			{Name: "mod-cache", MountPath: modCacheDirectory},
+		}
+		// Packages from other sources are planned in reconcileMods, which
+		// reports the ones that cannot be installed
+		plans, _ := s.modSourcePlans()
+		if len(plans) > 0 {
+			volumes = append(volumes, v1.Volume{
+				Name:         "mod-sources",
+				VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}},
+			})
+			downloaderMounts = append(downloaderMounts, v1.VolumeMount{Name: "mod-sources", MountPath: ociModsDirectory})
+		}
+		for _, p := range plans {
+			volumes = append(volumes, p.plan.Volumes...)
+			downloaderMounts = append(downloaderMounts, p.plan.VolumeMounts...)
+			initContainers = append(initContainers, p.plan.InitContainers...)
+		}
+
+		podAnnotations = map[string]string{AnnotationModSet: modSetHash(s.modRegistry())}
+		if configChecksum != "" {
+			podAnnotations[AnnotationPluginConfig] = configChecksum
+		}
+		if volume, mount, checksum := s.valheimPlusConfigVolume(); volume != nil {
+			volumes = append(volumes, *volume)
+			volumeMounts = append(volumeMounts, *mount)
+			podAnnotations[AnnotationValheimPlusConfig] = checksum
+		}
+
+		thunderstoreURL := thunderstore.DefaultBaseURL
+		if s.Thunderstore != nil {
+			thunderstoreURL = s.Thunderstore.BaseURL
+		}
 
 		initContainers = append(initContainers, v1.Container{
-			Name:  "mod-downloader",
-			Image: "busybox",
-			VolumeMounts: []v1.VolumeMount{
-				{Name: "mods", MountPath: modPath},
-				{Name: "mod-config", MountPath: "/config/mods"},
-			},
+			Name:         modDownloaderContainer,
+			Image:        "busybox",
+			VolumeMounts: downloaderMounts,
 			Env: []v1.EnvVar{
 				{Name: "MOD_PATH", Value: modPath},
+				{Name: "CACHE_PATH", Value: modCacheDirectory},
+				{Name: "THUNDERSTORE_URL", Value: thunderstoreURL},
 			},
 			Command: []string{"sh", "-c"},
-			Args: []string{`
-for pkg in $(cat "/config/mods/registry.txt")
-do
-  package=$(echo $pkg | cut -d ":" -f 1)
-  version=$(echo $pkg | cut -d ":" -f 2)
-  echo "Downloading ${package} at version ${version}"
-  output=$(echo $pkg | sed 's/\///g')
-  wget -O "${output}.zip" "https://thunderstore.io/package/download/${package}/${version}"
-  unzip "${output}.zip" -n -d "${MOD_PATH}/"
-  rm "${output}.zip"
-done
-`},
+			Args:    []string{modDownloadScript},
 		})
 	}
+	if s.passwordRotatedAt != "" {
+		if podAnnotations == nil {
+			podAnnotations = map[string]string{}
+		}
+		podAnnotations[AnnotationPasswordRotatedAt] = s.passwordRotatedAt
+	}
+	accessVolume, accessContainer := s.accessSync()
+	volumes = append(volumes, accessVolume)
+
 	statefulSet := &appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      req.Name,
@@ -570,11 +914,13 @@ done
 			},
 			Template: v1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: s.labels,
+					Labels:      s.labels,
+					Annotations: podAnnotations,
 				},
 				Spec: v1.PodSpec{
-					ShareProcessNamespace: util.BoolAddr(true),
-					InitContainers:        initContainers,
+					ShareProcessNamespace:         util.BoolAddr(true),
+					TerminationGracePeriodSeconds: util.Int64Addr(ServerTerminationGracePeriod),
+					InitContainers:                initContainers,
 					Containers: []v1.Container{
 						{
 							Name:  "server",
@@ -605,35 +951,7 @@ done
 							},
 							VolumeMounts: volumeMounts,
 						},
-						//						{
-						//							Name:    "backup-manager",
-						//							Image:   "amazon/aws-cli",
-						//							Command: []string{"sh", "-c"},
-						//							Args: []string{`
-						//aws configure set default.s3.signature_version s3v4
-						//while true do
-						//  aws s3 sync /config/backups s3://$BACKUP_PREFIX/
-						//done
-						//`},
-						//							Env: []v1.EnvVar{
-						//								{
-						//									Name:  "BACKUP_PREFIX",
-						//									Value: s.Valheim.Name + "." + s.Valheim.Namespace,
-						//								}, {
-						//									Name:  "AWS_ENDPOINT_URL",
-						//									Value: s.Valheim.Spec.Backups.Endpoint,
-						//								},
-						//							},
-						//							EnvFrom: []v1.EnvFromSource{
-						//								{
-						//									SecretRef: &v1.SecretEnvSource{
-						//										LocalObjectReference: v1.LocalObjectReference{
-						//											Name: s.Valheim.Spec.Backups.SecretKeyRef.Name,
-						//										},
-						//									},
-						//								},
-						//							},
-						//						},
+						accessContainer,
 					},
 					Volumes: volumes,
 				},
@@ -0,0 +1,17 @@
+package valheim
+
+import (
+	"testing"
+
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// The specs of this package do not dot import ginkgo, whose Labels clashes
+// with the Labels of this package
+
+func TestValheim(t *testing.T) {
+	RegisterFailHandler(ginkgo.Fail)
+
+	ginkgo.RunSpecs(t, "Valheim Scope Suite")
+}
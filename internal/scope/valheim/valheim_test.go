@@ -0,0 +1,275 @@
+package valheim
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/robwittman/gamely/api/v1alpha2"
+	"github.com/robwittman/gamely/internal/util"
+)
+
+var _ = ginkgo.Describe("reconcileReplicas", func() {
+	var (
+		server   *v1alpha2.Valheim
+		recorder *record.FakeRecorder
+	)
+
+	ginkgo.BeforeEach(func() {
+		server = &v1alpha2.Valheim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world", Generation: 2},
+			Status:     v1alpha2.ValheimStatus{ObservedGeneration: 2},
+		}
+		recorder = record.NewFakeRecorder(10)
+	})
+
+	// statefulSet is the live statefulset of the server, paused at pausedReplicas if set
+	statefulSet := func(replicas int32, pausedReplicas string) *appsv1.StatefulSet {
+		statefulSet := &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: util.Int32Addr(replicas)},
+		}
+		if pausedReplicas != "" {
+			statefulSet.Annotations = map[string]string{AnnotationPausedReplicas: pausedReplicas}
+		}
+		return statefulSet
+	}
+
+	ginkgo.DescribeTable("scales the statefulset with spec.paused",
+		func(paused bool, live *appsv1.StatefulSet, replicas int32, pausedReplicas string, drifted bool) {
+			server.Spec.Paused = paused
+			scheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			Expect(v1alpha2.AddToScheme(scheme)).To(Succeed())
+			s := &Scope{
+				Logger:   logr.Discard(),
+				Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(live).Build(),
+				Recorder: recorder,
+				Valheim:  server,
+			}
+			Expect(s.reconcileReplicas(context.Background(), live)).To(Succeed())
+
+			stored := &appsv1.StatefulSet{}
+			Expect(s.Client.Get(context.Background(), client.ObjectKeyFromObject(live), stored)).To(Succeed())
+			for _, statefulSet := range []*appsv1.StatefulSet{live, stored} {
+				Expect(*statefulSet.Spec.Replicas).To(Equal(replicas))
+				if pausedReplicas == "" {
+					Expect(statefulSet.Annotations).NotTo(HaveKey(AnnotationPausedReplicas))
+				} else {
+					Expect(statefulSet.Annotations).To(HaveKeyWithValue(AnnotationPausedReplicas, pausedReplicas))
+				}
+			}
+			if drifted {
+				Expect(recorder.Events).To(Receive(ContainSubstring(EventReasonDrifted)))
+			} else {
+				Expect(recorder.Events).NotTo(Receive())
+			}
+		},
+		ginkgo.Entry("records the replicas of a server that is paused", true, statefulSet(3, ""), int32(0), "3", false),
+		ginkgo.Entry("leaves a paused server scaled down", true, statefulSet(0, "3"), int32(0), "3", false),
+		ginkgo.Entry("scales a paused server that was scaled up back down", true, statefulSet(2, "3"), int32(0), "3", true),
+		ginkgo.Entry("restores the replicas of a server that is unpaused", false, statefulSet(0, "3"), int32(3), "", false),
+		ginkgo.Entry("restores one replica when the recorded count is unreadable", false, statefulSet(0, "three"), int32(1), "", false),
+		ginkgo.Entry("leaves the replicas of a running server to autoscalers", false, statefulSet(4, ""), int32(4), "", false),
+	)
+
+	ginkgo.It("round-trips the replicas through a pause", func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		live := statefulSet(2, "")
+		s := &Scope{
+			Logger:   logr.Discard(),
+			Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(live).Build(),
+			Recorder: recorder,
+			Valheim:  server,
+		}
+
+		for _, paused := range []bool{true, false} {
+			server.Spec.Paused = paused
+			Expect(s.reconcileReplicas(context.Background(), live)).To(Succeed())
+		}
+		Expect(*live.Spec.Replicas).To(BeEquivalentTo(2))
+		Expect(live.Annotations).NotTo(HaveKey(AnnotationPausedReplicas))
+	})
+})
+
+var _ = ginkgo.Describe("setPausedCondition", func() {
+	ginkgo.DescribeTable("reports the shutdown of a paused server",
+		func(paused bool, restoring string, replicas int, reason string, status metav1.ConditionStatus, requeue bool) {
+			s := &Scope{
+				Logger: logr.Discard(),
+				Valheim: &v1alpha2.Valheim{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world"},
+					Spec:       v1alpha2.ValheimSpec{Paused: paused},
+				},
+			}
+			if restoring != "" {
+				s.Valheim.Annotations = map[string]string{v1alpha2.AnnotationRestoring: restoring}
+			}
+			statefulSet := &appsv1.StatefulSet{Status: appsv1.StatefulSetStatus{Replicas: int32(replicas)}}
+
+			after := s.setPausedCondition(statefulSet)
+			Expect(after > 0).To(Equal(requeue), "requeue after %s", after)
+			condition := meta.FindStatusCondition(s.Valheim.Status.Conditions, v1alpha2.ConditionPaused)
+			Expect(condition.Reason).To(Equal(reason))
+			Expect(condition.Status).To(Equal(status))
+		},
+		ginkgo.Entry("running", false, "", 1, "Running", metav1.ConditionFalse, false),
+		ginkgo.Entry("shutting down", true, "", 1, "ShuttingDown", metav1.ConditionTrue, true),
+		ginkgo.Entry("stopped", true, "", 0, "ServerStopped", metav1.ConditionTrue, false),
+		ginkgo.Entry("stopped for a restore", false, "rollback", 0, "Restoring", metav1.ConditionTrue, false),
+		ginkgo.Entry("shutting down for a restore", false, "rollback", 1, "Restoring", metav1.ConditionTrue, true),
+	)
+})
+
+var _ = ginkgo.Describe("reconcileDelete", func() {
+	var (
+		server   *v1alpha2.Valheim
+		recorder *record.FakeRecorder
+		req      ctrl.Request
+	)
+
+	ginkgo.BeforeEach(func() {
+		deleted := metav1.Now()
+		server = &v1alpha2.Valheim{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "default",
+				Name:              "world",
+				UID:               "world-uid",
+				Finalizers:        []string{Finalizer},
+				DeletionTimestamp: &deleted,
+			},
+			Spec: v1alpha2.ValheimSpec{
+				Backups: v1alpha2.ValheimBackupSpec{Storage: v1alpha2.ValheimStorageSpec{Size: "1Gi"}},
+			},
+		}
+		recorder = record.NewFakeRecorder(10)
+		req = ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "world"}}
+	})
+
+	// owned is a volume claim of the server that is also owned by another object
+	owned := func(name string) *v1.PersistentVolumeClaim {
+		return &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      name,
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: v1alpha2.GroupVersion.String(), Kind: "Valheim", Name: "world", UID: server.UID},
+				{APIVersion: "v1", Kind: "ConfigMap", Name: "other", UID: "other-uid"},
+			},
+		}}
+	}
+
+	scope := func(objects ...client.Object) *Scope {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(v1alpha2.AddToScheme(scheme)).To(Succeed())
+		objects = append(objects, server, owned("world"), owned("world-backups"))
+		return &Scope{
+			Logger:   logr.Discard(),
+			Client:   applyClient{fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()},
+			Recorder: recorder,
+			Valheim:  server,
+		}
+	}
+
+	owners := func(s *Scope, name string) []string {
+		claim := &v1.PersistentVolumeClaim{}
+		Expect(s.Client.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: name}, claim)).To(Succeed())
+		uids := []string{}
+		for _, owner := range claim.OwnerReferences {
+			uids = append(uids, string(owner.UID))
+		}
+		return uids
+	}
+
+	finalBackup := func(condition batchv1.JobConditionType) *batchv1.Job {
+		job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world-final-backup"}}
+		job.Status.Conditions = []batchv1.JobCondition{{Type: condition, Status: v1.ConditionTrue}}
+		if condition == batchv1.JobFailed {
+			job.Status.Failed = 1
+		}
+		return job
+	}
+
+	ginkgo.DescribeTable("applies the deletion policy before removing the finalizer",
+		func(policy v1alpha2.ValheimDeletionPolicy, objects []client.Object, finalized bool, worldOwners []string, backupOwners []string) {
+			server.Spec.DeletionPolicy = policy
+			s := scope(objects...)
+			result, err := s.reconcileDelete(context.Background(), req)
+			Expect(err).NotTo(HaveOccurred())
+
+			if finalized {
+				Expect(server.Finalizers).NotTo(ContainElement(Finalizer))
+				Expect(result.RequeueAfter).To(BeZero())
+			} else {
+				Expect(server.Finalizers).To(ContainElement(Finalizer))
+				Expect(result.RequeueAfter).NotTo(BeZero())
+			}
+			Expect(server.Status.Phase).To(Equal(v1alpha2.ValheimPhaseTerminating))
+			Expect(owners(s, "world")).To(Equal(worldOwners))
+			Expect(owners(s, "world-backups")).To(Equal(backupOwners))
+		},
+		ginkgo.Entry("deleting the storage with the server", v1alpha2.DeletionPolicyDelete, nil,
+			true, []string{"world-uid", "other-uid"}, []string{"world-uid", "other-uid"}),
+		ginkgo.Entry("retaining the world and backup claims", v1alpha2.DeletionPolicyRetain, nil,
+			true, []string{"other-uid"}, []string{"other-uid"}),
+		ginkgo.Entry("waiting on the final backup", v1alpha2.DeletionPolicyBackupThenDelete, []client.Object{finalBackup("")},
+			false, []string{"world-uid", "other-uid"}, []string{"world-uid", "other-uid"}),
+		ginkgo.Entry("retaining the backup claim holding the final backup", v1alpha2.DeletionPolicyBackupThenDelete, []client.Object{finalBackup(batchv1.JobComplete)},
+			true, []string{"world-uid", "other-uid"}, []string{"other-uid"}),
+		ginkgo.Entry("blocking deletion on a failed final backup", v1alpha2.DeletionPolicyBackupThenDelete, []client.Object{finalBackup(batchv1.JobFailed)},
+			false, []string{"world-uid", "other-uid"}, []string{"world-uid", "other-uid"}),
+	)
+
+	ginkgo.It("shuts the server down before taking the final backup", func() {
+		server.Spec.DeletionPolicy = v1alpha2.DeletionPolicyBackupThenDelete
+		live := &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: util.Int32Addr(1)},
+			Status:     appsv1.StatefulSetStatus{Replicas: 1},
+		}
+		s := scope(live)
+		_, err := s.reconcileDelete(context.Background(), req)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(s.Client.Get(context.Background(), req.NamespacedName, live)).To(Succeed())
+		Expect(*live.Spec.Replicas).To(BeZero())
+		Expect(s.Client.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "world-final-backup"}, &batchv1.Job{})).NotTo(Succeed())
+
+		live.Status.Replicas = 0
+		Expect(s.Client.Status().Update(context.Background(), live)).To(Succeed())
+		_, err = s.reconcileDelete(context.Background(), req)
+		Expect(err).NotTo(HaveOccurred())
+		job := &batchv1.Job{}
+		Expect(s.Client.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "world-final-backup"}, job)).To(Succeed())
+		Expect(job.OwnerReferences).To(ConsistOf(HaveField("UID", server.UID)))
+		Expect(recorder.Events).To(Receive(ContainSubstring("started final world backup")))
+		Expect(server.Finalizers).To(ContainElement(Finalizer))
+	})
+
+	ginkgo.It("reports a failed final backup once", func() {
+		server.Spec.DeletionPolicy = v1alpha2.DeletionPolicyBackupThenDelete
+		s := scope(finalBackup(batchv1.JobFailed))
+		for i := 0; i < 2; i++ {
+			_, err := s.reconcileDelete(context.Background(), req)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(recorder.Events).To(Receive(ContainSubstring(EventReasonFinalBackupFailed)))
+		Expect(recorder.Events).NotTo(Receive())
+	})
+})
@@ -0,0 +1,95 @@
+package valheim
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"github.com/robwittman/gamely/internal/valheimplus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"path"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// EventReasonValheimPlusConfigInvalid is emitted when the ValheimPlus
+	// config of the spec names sections or settings ValheimPlus does not know
+	EventReasonValheimPlusConfigInvalid = "ValheimPlusConfigInvalid"
+
+	// AnnotationValheimPlusConfig records the checksum of the valheim_plus.cfg
+	// a server pod was started with. ValheimPlus only reads its config on
+	// start, so the server restarts when the config changes.
+	AnnotationValheimPlusConfig = "gamely.io/valheimplus-config"
+
+	// valheimPlusPath is where the server image reads ValheimPlus files from
+	valheimPlusPath = "/config/valheimplus"
+)
+
+// usesValheimPlus reports whether the operator renders the ValheimPlus config
+func (s *Scope) usesValheimPlus() bool {
+	mods := s.Valheim.Spec.Mods
+	return mods.Enabled && mods.Framework != "bepinex" && mods.ValheimPlus != nil
+}
+
+// reconcileValheimPlusConfig renders valheim_plus.cfg into a ConfigMap. An
+// invalid config is reported through the ModsReady condition and leaves the
+// config the server runs with as it is.
+func (s *Scope) reconcileValheimPlusConfig(ctx context.Context, req ctrl.Request) error {
+	name := req.Name + "-valheimplus"
+	rendered, err := valheimplus.Render(s.Valheim.Spec.Mods.ValheimPlus.Config)
+	if err != nil {
+		s.Logger.Error(err, "invalid valheim plus config")
+		s.reportInvalidConfig(EventReasonValheimPlusConfigInvalid, err)
+
+		existing := &v1.ConfigMap{}
+		if err := s.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: name}, existing); err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		previous := existing.Data[valheimplus.ConfigFile]
+		s.valheimPlusConfig = &previous
+		return nil
+	}
+
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: req.Namespace,
+			Name:      name,
+		},
+		Data: map[string]string{valheimplus.ConfigFile: rendered},
+	}
+	if _, err := s.apply(ctx, configMap); err != nil {
+		return err
+	}
+	s.valheimPlusConfig = &rendered
+	return nil
+}
+
+// valheimPlusConfigVolume mounts the rendered valheim_plus.cfg over the one on
+// the mod volume, returning nothing when there is no config to mount
+func (s *Scope) valheimPlusConfigVolume() (*v1.Volume, *v1.VolumeMount, string) {
+	if s.valheimPlusConfig == nil {
+		return nil, nil, ""
+	}
+	volume := &v1.Volume{
+		Name: "valheimplus-config",
+		VolumeSource: v1.VolumeSource{
+			ConfigMap: &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: s.Valheim.Name + "-valheimplus",
+				},
+			},
+		},
+	}
+	mount := &v1.VolumeMount{
+		Name:      volume.Name,
+		MountPath: path.Join(valheimPlusPath, valheimplus.ConfigFile),
+		SubPath:   valheimplus.ConfigFile,
+		ReadOnly:  true,
+	}
+	return volume, mount, fmt.Sprintf("%x", sha256.Sum256([]byte(*s.valheimPlusConfig)))
+}
@@ -0,0 +1,85 @@
+package valheim
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-logr/logr"
+	"github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/robwittman/gamely/api/v1alpha2"
+	"github.com/robwittman/gamely/internal/valheimplus"
+)
+
+var _ = ginkgo.Describe("reconcileValheimPlusConfig", func() {
+	var (
+		recorder *record.FakeRecorder
+		s        *Scope
+		req      ctrl.Request
+	)
+
+	ginkgo.BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(v1alpha2.AddToScheme(scheme)).To(Succeed())
+		recorder = record.NewFakeRecorder(10)
+		s = &Scope{
+			Logger: logr.Discard(),
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(&v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world-valheimplus"},
+				Data:       map[string]string{valheimplus.ConfigFile: "[Server]\nenabled=true\n"},
+			}).Build(),
+			Recorder: recorder,
+			Valheim: &v1alpha2.Valheim{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "world", Generation: 1},
+				Spec: v1alpha2.ValheimSpec{
+					Mods: v1alpha2.ValheimModsSpec{
+						Enabled:     true,
+						ValheimPlus: &v1alpha2.ValheimPlusSpec{Config: map[string]map[string]string{"Valhalla": {"enabled": "true"}}},
+					},
+				},
+			},
+		}
+		req = ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "world"}}
+	})
+
+	reconcile := func() {
+		s.configErr = nil
+		Expect(s.reconcileValheimPlusConfig(context.Background(), req)).To(Succeed())
+		s.setModsCondition(nil)
+	}
+
+	ginkgo.It("keeps the previous config and reports an invalid one once for every generation", func() {
+		reconcile()
+		Expect(*s.valheimPlusConfig).To(Equal("[Server]\nenabled=true\n"))
+		Expect(recorder.Events).To(Receive(ContainSubstring(EventReasonValheimPlusConfigInvalid)))
+
+		condition := meta.FindStatusCondition(s.Valheim.Status.Conditions, v1alpha2.ConditionModsReady)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("InvalidConfig"))
+
+		reconcile()
+		Expect(recorder.Events).NotTo(Receive())
+
+		s.Valheim.Generation = 2
+		reconcile()
+		Expect(recorder.Events).To(Receive(ContainSubstring(EventReasonValheimPlusConfigInvalid)))
+	})
+
+	ginkgo.It("reports invalid plugin config next to it", func() {
+		s.reportInvalidConfig(EventReasonPluginConfigInvalid, errors.New("invalid plugin config"))
+		Expect(s.reconcileValheimPlusConfig(context.Background(), req)).To(Succeed())
+		Expect(s.configErr).To(MatchError(HavePrefix("invalid plugin config, ")))
+	})
+})
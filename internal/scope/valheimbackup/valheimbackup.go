@@ -0,0 +1,184 @@
+package valheimbackup
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	"github.com/robwittman/gamely/api/v1alpha1"
+	"github.com/robwittman/gamely/api/v1alpha2"
+	"github.com/robwittman/gamely/internal/scope/valheim"
+	"github.com/robwittman/gamely/internal/util"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"time"
+)
+
+const (
+	// EventReasonBackupStarted, EventReasonBackupCompleted and
+	// EventReasonBackupFailed track the progress of a ValheimBackup
+	EventReasonBackupStarted   = "BackupStarted"
+	EventReasonBackupCompleted = "BackupCompleted"
+	EventReasonBackupFailed    = "BackupFailed"
+	// EventReasonBackupHookFailed reports a backup hook failing in the server.
+	// The backup is taken regardless.
+	EventReasonBackupHookFailed = "BackupHookFailed"
+
+	// pendingRequeueInterval is how often we look for a Valheim that does not exist yet
+	pendingRequeueInterval = time.Second * 30
+)
+
+type Scope struct {
+	Logger   logr.Logger
+	Client   client.Client
+	Recorder record.EventRecorder
+	// Executor runs the backup hooks in the server around the backup job.
+	// Hooks are skipped when it is nil.
+	Executor util.PodExecutor
+	Backup   *v1alpha1.ValheimBackup
+}
+
+// Reconcile runs a job that takes the backup, or inspects the archive being
+// recorded, and copies what it reports into status. Finished backups are left alone.
+func (s *Scope) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if s.Backup.Finished() {
+		return ctrl.Result{}, nil
+	}
+	status := s.Backup.Status.DeepCopy()
+
+	result, err := s.reconcileJob(ctx, req)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !equality.Semantic.DeepEqual(status, &s.Backup.Status) {
+		if err := s.Client.Status().Update(ctx, s.Backup); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return result, nil
+}
+
+func (s *Scope) reconcileJob(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	server := &v1alpha2.Valheim{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: s.Backup.Spec.ValheimRef.Name}, server); err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		s.Backup.Status.Phase = v1alpha1.ValheimBackupPhasePending
+		s.Backup.Status.Message = fmt.Sprintf("waiting for valheim %s", s.Backup.Spec.ValheimRef.Name)
+		return ctrl.Result{RequeueAfter: pendingRequeueInterval}, nil
+	}
+
+	job := &batchv1.Job{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name + "-backup"}, job); err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if server.GetDeletionTimestamp() != nil {
+			s.fail(fmt.Sprintf("valheim %s is being deleted", server.Name))
+			return ctrl.Result{}, nil
+		}
+		if restore := server.Restoring(); restore != "" {
+			s.Backup.Status.Phase = v1alpha1.ValheimBackupPhasePending
+			s.Backup.Status.Message = fmt.Sprintf("waiting for restore %s to finish", restore)
+			return ctrl.Result{RequeueAfter: pendingRequeueInterval}, nil
+		}
+
+		// A running server holds the ReadWriteOnce world volume, so the job has
+		// to share its node. A stopped server leaves the volume free to mount anywhere.
+		opts := valheim.BackupJobOptions{
+			Archive:  s.Backup.Spec.Archive,
+			Colocate: !server.Stopped(),
+		}
+		if opts.Archive == "" {
+			opts.File = valheim.NewBackupFile(server, time.Now())
+			s.runBackupHook(ctx, server, valheim.EnvVarPreBackupHook, opts.File)
+		}
+		job = valheim.NewBackupJob(server, req.Name+"-backup", opts)
+		if opts.File != "" {
+			job.SetAnnotations(map[string]string{valheim.AnnotationPostBackupHook: opts.File})
+		}
+		if err := controllerutil.SetControllerReference(s.Backup, job, s.Client.Scheme()); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := s.Client.Create(ctx, job); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		now := metav1.Now()
+		s.Backup.Status.Phase = v1alpha1.ValheimBackupPhaseRunning
+		s.Backup.Status.Message = ""
+		s.Backup.Status.StartTime = &now
+		s.Recorder.Eventf(s.Backup, v1.EventTypeNormal, EventReasonBackupStarted, "started backup job %s", job.Name)
+		return ctrl.Result{}, nil
+	}
+
+	s.Backup.Status.Phase = v1alpha1.ValheimBackupPhaseRunning
+	finished, _ := valheim.JobFinished(job)
+	if !finished {
+		return ctrl.Result{}, nil
+	}
+	if err := s.reconcilePostBackupHook(ctx, server, job); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	result, err := valheim.BackupJobResult(ctx, s.Client, job)
+	if err != nil {
+		s.Logger.Error(err, "backup job did not succeed")
+		s.fail(err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	s.Backup.Status.Phase = v1alpha1.ValheimBackupPhaseCompleted
+	s.Backup.Status.Message = ""
+	s.Backup.Status.Archive = result.Archive
+	s.Backup.Status.Location = valheim.BackupLocation(server, result.Archive)
+	s.Backup.Status.Size = result.Size
+	s.Backup.Status.Checksum = "sha256:" + result.SHA256
+	s.Backup.Status.CompletionTime = &result.Time
+	s.Recorder.Eventf(s.Backup, v1.EventTypeNormal, EventReasonBackupCompleted, "backup %s completed", result.Archive)
+	return ctrl.Result{}, nil
+}
+
+// reconcilePostBackupHook runs the post backup hook in the server once the job
+// that took a new archive has finished, whether or not it succeeded. The job
+// is annotated with the archive until then, so the hook runs once.
+func (s *Scope) reconcilePostBackupHook(ctx context.Context, server *v1alpha2.Valheim, job *batchv1.Job) error {
+	file, pending := job.GetAnnotations()[valheim.AnnotationPostBackupHook]
+	if !pending {
+		return nil
+	}
+	s.runBackupHook(ctx, server, valheim.EnvVarPostBackupHook, file)
+
+	patch := client.MergeFrom(job.DeepCopy())
+	annotations := job.GetAnnotations()
+	delete(annotations, valheim.AnnotationPostBackupHook)
+	job.SetAnnotations(annotations)
+	return s.Client.Patch(ctx, job, patch)
+}
+
+// runBackupHook runs a backup hook inside the server, so it can quiesce the
+// world around the backup job. Failing hooks are reported but do not stop the backup.
+func (s *Scope) runBackupHook(ctx context.Context, server *v1alpha2.Valheim, hook string, backupFile string) {
+	if server.Stopped() {
+		return
+	}
+	if err := valheim.RunBackupHook(ctx, s.Client, s.Executor, server, hook, backupFile); err != nil {
+		s.Logger.Error(err, "backup hook failed", "hook", hook)
+		s.Recorder.Event(s.Backup, v1.EventTypeWarning, EventReasonBackupHookFailed, err.Error())
+	}
+}
+
+func (s *Scope) fail(message string) {
+	s.Backup.Status.Phase = v1alpha1.ValheimBackupPhaseFailed
+	s.Backup.Status.Message = message
+	s.Recorder.Event(s.Backup, v1.EventTypeWarning, EventReasonBackupFailed, message)
+}
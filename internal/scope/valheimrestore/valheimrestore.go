@@ -0,0 +1,451 @@
+package valheimrestore
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	"github.com/robwittman/gamely/api/v1alpha1"
+	"github.com/robwittman/gamely/api/v1alpha2"
+	"github.com/robwittman/gamely/internal/scope/valheim"
+	"github.com/robwittman/gamely/internal/util"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"time"
+)
+
+const (
+	// EventReasonRestoreStarted, EventReasonRestoreCompleted and
+	// EventReasonRestoreFailed track the progress of a ValheimRestore
+	EventReasonRestoreStarted   = "RestoreStarted"
+	EventReasonRestoreCompleted = "RestoreCompleted"
+	EventReasonRestoreFailed    = "RestoreFailed"
+
+	// progressRequeueInterval is how often we check on the server while it is
+	// stopping or starting, since its pods do not trigger a reconcile
+	progressRequeueInterval = time.Second * 10
+	// pendingRequeueInterval is how often we check whether a pending restore can start
+	pendingRequeueInterval = time.Second * 30
+)
+
+type Scope struct {
+	Logger   logr.Logger
+	Client   client.Client
+	Recorder record.EventRecorder
+	Restore  *v1alpha1.ValheimRestore
+}
+
+// Reconcile walks a restore through stopping the server, replacing its world
+// with a job or a volume provisioned from a snapshot, and starting it again. While the restore runs the Valheim is
+// annotated with its name, which keeps the server scaled down.
+func (s *Scope) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if s.Restore.GetDeletionTimestamp() != nil {
+		return s.reconcileDelete(ctx, req)
+	}
+	if s.Restore.Finished() {
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(s.Restore, valheim.Finalizer) {
+		patch := client.MergeFrom(s.Restore.DeepCopy())
+		controllerutil.AddFinalizer(s.Restore, valheim.Finalizer)
+		if err := s.Client.Patch(ctx, s.Restore, patch); err != nil {
+			s.Logger.Error(err, "failed adding finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	status := s.Restore.Status.DeepCopy()
+	result, err := s.reconcilePhase(ctx, req)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !equality.Semantic.DeepEqual(status, &s.Restore.Status) {
+		if err := s.Client.Status().Update(ctx, s.Restore); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return result, nil
+}
+
+// reconcileDelete lets the server start again if the restore is deleted part way through
+func (s *Scope) reconcileDelete(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(s.Restore, valheim.Finalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	server := &v1alpha2.Valheim{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: s.Restore.Spec.ValheimRef.Name}, server); err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+	} else if err := s.release(ctx, server); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	patch := client.MergeFrom(s.Restore.DeepCopy())
+	controllerutil.RemoveFinalizer(s.Restore, valheim.Finalizer)
+	if err := s.Client.Patch(ctx, s.Restore, patch); err != nil {
+		s.Logger.Error(err, "failed removing finalizer")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+func (s *Scope) reconcilePhase(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	server := &v1alpha2.Valheim{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: s.Restore.Spec.ValheimRef.Name}, server); err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if s.Restore.Status.Phase != "" && s.Restore.Status.Phase != v1alpha1.ValheimRestorePhasePending {
+			s.fail(fmt.Sprintf("valheim %s was deleted during the restore", s.Restore.Spec.ValheimRef.Name))
+			return ctrl.Result{}, nil
+		}
+		s.pending(fmt.Sprintf("waiting for valheim %s", s.Restore.Spec.ValheimRef.Name))
+		return ctrl.Result{RequeueAfter: pendingRequeueInterval}, nil
+	}
+
+	switch s.Restore.Status.Phase {
+	case v1alpha1.ValheimRestorePhaseStopping:
+		return s.reconcileStopping(ctx, req, server)
+	case v1alpha1.ValheimRestorePhaseRestoring:
+		return s.reconcileRestoring(ctx, req, server)
+	case v1alpha1.ValheimRestorePhaseStarting:
+		return s.reconcileStarting(ctx, server)
+	default:
+		return s.reconcilePending(ctx, server)
+	}
+}
+
+// reconcilePending works out which archive to restore, then takes the server
+// over by annotating it, unless another restore already has
+func (s *Scope) reconcilePending(ctx context.Context, server *v1alpha2.Valheim) (ctrl.Result, error) {
+	if server.GetDeletionTimestamp() != nil {
+		s.fail(fmt.Sprintf("valheim %s is being deleted", server.Name))
+		return ctrl.Result{}, nil
+	}
+
+	archive, ready, err := s.archive(ctx)
+	if err != nil || !ready {
+		return ctrl.Result{RequeueAfter: pendingRequeueInterval}, err
+	}
+	if archive == "" {
+		return ctrl.Result{}, nil
+	}
+	if s.Restore.Spec.Source == v1alpha1.RestoreSourceBucket && server.Spec.Backups.Bucket == "" {
+		s.fail(fmt.Sprintf("valheim %s has no backup bucket configured", server.Name))
+		return ctrl.Result{}, nil
+	}
+
+	if restore := server.Restoring(); restore != "" && restore != s.Restore.Name {
+		s.pending(fmt.Sprintf("waiting for restore %s to finish", restore))
+		return ctrl.Result{RequeueAfter: pendingRequeueInterval}, nil
+	}
+
+	if server.Restoring() == "" {
+		patch := client.MergeFrom(server.DeepCopy())
+		if server.Annotations == nil {
+			server.Annotations = map[string]string{}
+		}
+		server.Annotations[v1alpha2.AnnotationRestoring] = s.Restore.Name
+		if err := s.Client.Patch(ctx, server, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	now := metav1.Now()
+	s.Restore.Status.Phase = v1alpha1.ValheimRestorePhaseStopping
+	s.Restore.Status.Message = "waiting for the server to shut down"
+	s.Restore.Status.Archive = archive
+	s.Restore.Status.StartTime = &now
+	s.Recorder.Eventf(s.Restore, v1.EventTypeNormal, EventReasonRestoreStarted, "stopping valheim %s to restore %s", server.Name, archive)
+	return ctrl.Result{RequeueAfter: progressRequeueInterval}, nil
+}
+
+// archive resolves the archive or volume snapshot to restore, reporting
+// whether it is ready to be restored. An empty archive means the restore has failed.
+func (s *Scope) archive(ctx context.Context) (string, bool, error) {
+	if s.Restore.Spec.VolumeSnapshot != "" {
+		return s.volumeSnapshot(ctx)
+	}
+
+	ref := s.Restore.Spec.BackupRef
+	if ref == nil {
+		if s.Restore.Spec.Archive == "" {
+			s.fail("one of backupRef or archive is required")
+		}
+		return s.Restore.Spec.Archive, true, nil
+	}
+
+	backup := &v1alpha1.ValheimBackup{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: s.Restore.Namespace, Name: ref.Name}, backup); err != nil {
+		if !errors.IsNotFound(err) {
+			return "", false, err
+		}
+		s.fail(fmt.Sprintf("valheim backup %s does not exist", ref.Name))
+		return "", true, nil
+	}
+
+	switch {
+	case backup.Spec.ValheimRef.Name != s.Restore.Spec.ValheimRef.Name:
+		s.fail(fmt.Sprintf("valheim backup %s belongs to valheim %s", backup.Name, backup.Spec.ValheimRef.Name))
+		return "", true, nil
+	case backup.Status.Phase == v1alpha1.ValheimBackupPhaseFailed:
+		s.fail(fmt.Sprintf("valheim backup %s failed", backup.Name))
+		return "", true, nil
+	case backup.Status.Phase != v1alpha1.ValheimBackupPhaseCompleted:
+		s.pending(fmt.Sprintf("waiting for valheim backup %s to complete", backup.Name))
+		return "", false, nil
+	}
+	return backup.Status.Archive, true, nil
+}
+
+// volumeSnapshot checks that the volume snapshot to restore can be provisioned from
+func (s *Scope) volumeSnapshot(ctx context.Context) (string, bool, error) {
+	name := s.Restore.Spec.VolumeSnapshot
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(valheim.VolumeSnapshotGroupVersionKind)
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: s.Restore.Namespace, Name: name}, snapshot); err != nil {
+		if meta.IsNoMatchError(err) {
+			s.fail("volume snapshots are not supported by this cluster")
+			return "", true, nil
+		}
+		if !errors.IsNotFound(err) {
+			return "", false, err
+		}
+		s.fail(fmt.Sprintf("volume snapshot %s does not exist", name))
+		return "", true, nil
+	}
+	if !valheim.VolumeSnapshotReady(snapshot) {
+		s.pending(fmt.Sprintf("waiting for volume snapshot %s to be ready", name))
+		return "", false, nil
+	}
+	return name, true, nil
+}
+
+// reconcileStopping starts the restore job once the server has shut down
+func (s *Scope) reconcileStopping(ctx context.Context, req ctrl.Request, server *v1alpha2.Valheim) (ctrl.Result, error) {
+	statefulSet := &appsv1.StatefulSet{}
+	if err := s.Client.Get(ctx, server.NamespacedName(), statefulSet); err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+	} else if statefulSet.Status.Replicas > 0 {
+		return ctrl.Result{RequeueAfter: progressRequeueInterval}, nil
+	}
+
+	if s.Restore.Spec.VolumeSnapshot != "" {
+		s.Restore.Status.Phase = v1alpha1.ValheimRestorePhaseRestoring
+		s.Restore.Status.Message = fmt.Sprintf("replacing the world volume with volume snapshot %s", s.Restore.Spec.VolumeSnapshot)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	job := &batchv1.Job{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name + "-restore"}, job); err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		job = valheim.NewRestoreJob(server, req.Name+"-restore", s.Restore.Status.Archive, s.Restore.Spec.Source)
+		if err := controllerutil.SetControllerReference(s.Restore, job, s.Client.Scheme()); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := s.Client.Create(ctx, job); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	s.Restore.Status.Phase = v1alpha1.ValheimRestorePhaseRestoring
+	s.Restore.Status.Message = fmt.Sprintf("restore job %s is replacing the world", job.Name)
+	return ctrl.Result{}, nil
+}
+
+// reconcileRestoring lets the server start again once the restore job has finished
+func (s *Scope) reconcileRestoring(ctx context.Context, req ctrl.Request, server *v1alpha2.Valheim) (ctrl.Result, error) {
+	if s.Restore.Spec.VolumeSnapshot != "" {
+		return s.reconcileSnapshotRestoring(ctx, req, server)
+	}
+
+	job := &batchv1.Job{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name + "-restore"}, job); err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		s.Restore.Status.Phase = v1alpha1.ValheimRestorePhaseStopping
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if finished, _ := valheim.JobFinished(job); !finished {
+		return ctrl.Result{}, nil
+	}
+
+	if err := s.release(ctx, server); err != nil {
+		return ctrl.Result{}, err
+	}
+	result, err := valheim.RestoreJobResult(ctx, s.Client, job)
+	if err != nil {
+		s.Logger.Error(err, "restore job did not succeed")
+		s.fail(err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	s.Restore.Status.SafetyArchive = result.SafetyArchive
+	s.Restore.Status.Phase = v1alpha1.ValheimRestorePhaseStarting
+	s.Restore.Status.Message = "waiting for the server to start"
+	return ctrl.Result{RequeueAfter: progressRequeueInterval}, nil
+}
+
+// reconcileSnapshotRestoring snapshots the current world volume, then replaces
+// it with a volume provisioned from the snapshot being restored. The new claim
+// is annotated with our name, so we can tell it apart from the one it replaced.
+func (s *Scope) reconcileSnapshotRestoring(ctx context.Context, req ctrl.Request, server *v1alpha2.Valheim) (ctrl.Result, error) {
+	claim := &v1.PersistentVolumeClaim{}
+	if err := s.Client.Get(ctx, server.NamespacedName(), claim); err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if err := s.provisionWorldVolume(ctx, server); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: progressRequeueInterval}, nil
+	}
+
+	if claim.Annotations[v1alpha2.AnnotationRestoring] != s.Restore.Name {
+		if claim.GetDeletionTimestamp() != nil {
+			return ctrl.Result{RequeueAfter: progressRequeueInterval}, nil
+		}
+
+		ready, err := s.reconcileSafetySnapshot(ctx, req, server)
+		if err != nil || !ready {
+			return ctrl.Result{RequeueAfter: progressRequeueInterval}, err
+		}
+		s.Logger.Info("deleting world volume to replace it", "claim", claim.Name)
+		if err := s.Client.Delete(ctx, claim); err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: progressRequeueInterval}, nil
+	}
+
+	if err := s.release(ctx, server); err != nil {
+		return ctrl.Result{}, err
+	}
+	s.Restore.Status.Phase = v1alpha1.ValheimRestorePhaseStarting
+	s.Restore.Status.Message = "waiting for the server to start"
+	return ctrl.Result{RequeueAfter: progressRequeueInterval}, nil
+}
+
+// reconcileSafetySnapshot snapshots the world volume before it is replaced,
+// reporting whether the snapshot is ready. The snapshot is not owned by the
+// restore, so it is kept after the restore is deleted.
+func (s *Scope) reconcileSafetySnapshot(ctx context.Context, req ctrl.Request, server *v1alpha2.Valheim) (bool, error) {
+	name := req.Name + "-pre-restore"
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(valheim.VolumeSnapshotGroupVersionKind)
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: name}, snapshot); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		snapshot = valheim.NewVolumeSnapshot(req.Namespace, name, server.Name, server.Spec.Backups.VolumeSnapshotClassName, map[string]string{
+			"gamely.io": "valheim-restore-snapshot",
+			"server":    server.Name,
+		})
+		if err := s.Client.Create(ctx, snapshot); err != nil {
+			return false, err
+		}
+		s.Restore.Status.SafetySnapshot = name
+		return false, nil
+	}
+	s.Restore.Status.SafetySnapshot = name
+	return valheim.VolumeSnapshotReady(snapshot), nil
+}
+
+// provisionWorldVolume creates the world volume of server from the volume
+// snapshot being restored, at least as large as the snapshot needs
+func (s *Scope) provisionWorldVolume(ctx context.Context, server *v1alpha2.Valheim) error {
+	claim, err := util.StorageVolume(server.Namespace, server.Name, &util.StorageVolumeOpts{
+		AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+		StorageClassName: server.Spec.Storage.Class,
+		Size:             server.Spec.Storage.Size,
+	})
+	if err != nil {
+		return err
+	}
+
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(valheim.VolumeSnapshotGroupVersionKind)
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: server.Namespace, Name: s.Restore.Spec.VolumeSnapshot}, snapshot); err != nil {
+		return err
+	}
+	if size, ok := valheim.VolumeSnapshotRestoreSize(snapshot); ok && size.Cmp(claim.Spec.Resources.Requests[v1.ResourceStorage]) > 0 {
+		claim.Spec.Resources.Requests[v1.ResourceStorage] = size
+	}
+
+	apiGroup := valheim.VolumeSnapshotGroupVersionKind.Group
+	claim.Spec.DataSource = &v1.TypedLocalObjectReference{
+		APIGroup: &apiGroup,
+		Kind:     valheim.VolumeSnapshotGroupVersionKind.Kind,
+		Name:     s.Restore.Spec.VolumeSnapshot,
+	}
+	claim.Annotations = map[string]string{v1alpha2.AnnotationRestoring: s.Restore.Name}
+	if err := controllerutil.SetOwnerReference(server, claim, s.Client.Scheme()); err != nil {
+		return err
+	}
+	s.Logger.Info("provisioning world volume from volume snapshot", "snapshot", s.Restore.Spec.VolumeSnapshot)
+	if err := s.Client.Create(ctx, claim); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// reconcileStarting completes the restore once the server is back up. A paused
+// server is left paused.
+func (s *Scope) reconcileStarting(ctx context.Context, server *v1alpha2.Valheim) (ctrl.Result, error) {
+	if !server.Spec.Paused {
+		statefulSet := &appsv1.StatefulSet{}
+		if err := s.Client.Get(ctx, server.NamespacedName(), statefulSet); err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if statefulSet.Status.ReadyReplicas == 0 {
+			return ctrl.Result{RequeueAfter: progressRequeueInterval}, nil
+		}
+	}
+
+	now := metav1.Now()
+	s.Restore.Status.Phase = v1alpha1.ValheimRestorePhaseCompleted
+	s.Restore.Status.Message = ""
+	s.Restore.Status.CompletionTime = &now
+	s.Recorder.Eventf(s.Restore, v1.EventTypeNormal, EventReasonRestoreCompleted, "restored %s to valheim %s", s.Restore.Status.Archive, server.Name)
+	return ctrl.Result{}, nil
+}
+
+// release removes our annotation from the server so it is scaled back up
+func (s *Scope) release(ctx context.Context, server *v1alpha2.Valheim) error {
+	if server.Restoring() != s.Restore.Name {
+		return nil
+	}
+	patch := client.MergeFrom(server.DeepCopy())
+	delete(server.Annotations, v1alpha2.AnnotationRestoring)
+	return s.Client.Patch(ctx, server, patch)
+}
+
+func (s *Scope) pending(message string) {
+	s.Restore.Status.Phase = v1alpha1.ValheimRestorePhasePending
+	s.Restore.Status.Message = message
+}
+
+func (s *Scope) fail(message string) {
+	s.Restore.Status.Phase = v1alpha1.ValheimRestorePhaseFailed
+	s.Restore.Status.Message = message
+	s.Recorder.Event(s.Restore, v1.EventTypeWarning, EventReasonRestoreFailed, message)
+}
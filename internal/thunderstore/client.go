@@ -0,0 +1,110 @@
+package thunderstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the public Thunderstore the mod downloader installs from
+const DefaultBaseURL = "https://thunderstore.io"
+
+// Client reads package metadata from the Thunderstore API
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a Client for the Thunderstore at baseURL
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: time.Second * 30},
+	}
+}
+
+// Package is a package with its latest version
+type Package struct {
+	Namespace string         `json:"namespace"`
+	Name      string         `json:"name"`
+	FullName  string         `json:"full_name"`
+	Latest    PackageVersion `json:"latest"`
+}
+
+// PackageVersion is a released version of a package
+type PackageVersion struct {
+	Namespace     string `json:"namespace"`
+	Name          string `json:"name"`
+	VersionNumber string `json:"version_number"`
+	FullName      string `json:"full_name"`
+	DownloadURL   string `json:"download_url"`
+	// Dependencies are the full names of the package versions this one
+	// requires, such as denikson-BepInExPack_Valheim-5.4.2202
+	Dependencies []string `json:"dependencies"`
+}
+
+// NotFoundError is returned for packages or versions Thunderstore does not know
+type NotFoundError struct {
+	Package string
+	Version string
+}
+
+func (e *NotFoundError) Error() string {
+	if e.Version == "" {
+		return fmt.Sprintf("package %s was not found on thunderstore", e.Package)
+	}
+	return fmt.Sprintf("package %s has no version %s on thunderstore", e.Package, e.Version)
+}
+
+// GetPackage looks up a package by namespace and name
+func (c *Client) GetPackage(ctx context.Context, namespace string, name string) (*Package, error) {
+	pkg := &Package{}
+	path := fmt.Sprintf("/api/experimental/package/%s/%s/", url.PathEscape(namespace), url.PathEscape(name))
+	if err := c.get(ctx, path, pkg, &NotFoundError{Package: namespace + "/" + name}); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// GetPackageVersion looks up one version of a package
+func (c *Client) GetPackageVersion(ctx context.Context, namespace string, name string, version string) (*PackageVersion, error) {
+	pkg := &PackageVersion{}
+	path := fmt.Sprintf("/api/experimental/package/%s/%s/%s/", url.PathEscape(namespace), url.PathEscape(name), url.PathEscape(version))
+	if err := c.get(ctx, path, pkg, &NotFoundError{Package: namespace + "/" + name, Version: version}); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, into interface{}, notFound error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return notFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("thunderstore returned %s for %s", resp.Status, path)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+		return fmt.Errorf("failed decoding thunderstore response for %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package thunderstore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LockedPackage is one package of a resolved mod set
+type LockedPackage struct {
+	// Package is the namespace and name of the package, as in Owner/Name
+	Package     string
+	Version     string
+	DownloadURL string
+}
+
+// ConflictError is returned when the requirements on a package cannot all be met
+type ConflictError struct {
+	Package      string
+	Requirements []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflicting versions of %s required: %s", e.Package, strings.Join(e.Requirements, ", "))
+}
+
+// Resolver works out the full set of packages to install for a set of
+// requested packages, following their declared dependencies
+type Resolver struct {
+	Client *Client
+	// Provided are packages, as in Owner/Name, the server already ships with.
+	// They are neither installed nor followed.
+	Provided map[string]bool
+}
+
+// requirement is a version of a package asked for by a request or a dependency
+type requirement struct {
+	version string
+	from    string
+	// pinned requirements come from the spec and must be installed as is
+	pinned bool
+}
+
+// maxResolveRounds bounds how often the dependency graph is walked while
+// versions are still being raised
+const maxResolveRounds = 20
+
+// Resolve locks the requested packages, keyed Owner/Name with an optional
+// version, and their dependencies to exact versions. Requests without a
+// version get the latest one. Dependencies on Thunderstore name an exact
+// version, but like mod managers do we treat them as minimums within the same
+// major version, and install the newest version anything asks for. A pinned
+// request below what a dependency needs, or dependencies on different major
+// versions, are conflicts. The lock is sorted by package.
+func (r *Resolver) Resolve(ctx context.Context, requested map[string]string) ([]LockedPackage, error) {
+	roots := []string{}
+	wanted := map[string][]requirement{}
+	for pkg, version := range requested {
+		namespace, name, err := splitPackage(pkg)
+		if err != nil {
+			return nil, err
+		}
+		pinned := version != ""
+		if !pinned {
+			latest, err := r.Client.GetPackage(ctx, namespace, name)
+			if err != nil {
+				return nil, err
+			}
+			version = latest.Latest.VersionNumber
+		}
+		wanted[pkg] = []requirement{{version: version, from: "spec", pinned: pinned}}
+		roots = append(roots, pkg)
+	}
+	sort.Strings(roots)
+
+	// Raising the version of one package can change what its dependencies
+	// need, so walk the graph until the chosen versions settle
+	fetched := map[string]*PackageVersion{}
+	chosen := map[string]string{}
+	for round := 0; round < maxResolveRounds; round++ {
+		requirements := map[string][]requirement{}
+		for pkg, reqs := range wanted {
+			requirements[pkg] = append([]requirement{}, reqs...)
+		}
+
+		seen := map[string]bool{}
+		queue := append([]string{}, roots...)
+		for len(queue) > 0 {
+			pkg := queue[0]
+			queue = queue[1:]
+			if seen[pkg] {
+				continue
+			}
+			seen[pkg] = true
+
+			version, ok := chosen[pkg]
+			if !ok {
+				var err error
+				if version, err = pick(pkg, requirements[pkg]); err != nil {
+					return nil, err
+				}
+			}
+			packageVersion, err := r.fetch(ctx, fetched, pkg, version)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, dependency := range packageVersion.Dependencies {
+				dependencyPackage, dependencyVersion, err := ParseDependency(dependency)
+				if err != nil {
+					return nil, fmt.Errorf("package %s %s: %w", pkg, version, err)
+				}
+				if r.Provided[dependencyPackage] {
+					continue
+				}
+				requirements[dependencyPackage] = append(requirements[dependencyPackage], requirement{
+					version: dependencyVersion,
+					from:    pkg + " " + version,
+				})
+				queue = append(queue, dependencyPackage)
+			}
+		}
+
+		next := map[string]string{}
+		for pkg := range seen {
+			version, err := pick(pkg, requirements[pkg])
+			if err != nil {
+				return nil, err
+			}
+			next[pkg] = version
+		}
+		if reflect.DeepEqual(next, chosen) {
+			return r.lock(ctx, fetched, chosen)
+		}
+		chosen = next
+	}
+	return nil, fmt.Errorf("package versions did not settle after %d rounds of resolution", maxResolveRounds)
+}
+
+// fetch looks up a package version, remembering it for the rest of the resolution
+func (r *Resolver) fetch(ctx context.Context, fetched map[string]*PackageVersion, pkg string, version string) (*PackageVersion, error) {
+	key := pkg + ":" + version
+	if packageVersion, ok := fetched[key]; ok {
+		return packageVersion, nil
+	}
+	namespace, name, err := splitPackage(pkg)
+	if err != nil {
+		return nil, err
+	}
+	packageVersion, err := r.Client.GetPackageVersion(ctx, namespace, name, version)
+	if err != nil {
+		return nil, err
+	}
+	fetched[key] = packageVersion
+	return packageVersion, nil
+}
+
+func (r *Resolver) lock(ctx context.Context, fetched map[string]*PackageVersion, chosen map[string]string) ([]LockedPackage, error) {
+	lock := make([]LockedPackage, 0, len(chosen))
+	for pkg, version := range chosen {
+		packageVersion, err := r.fetch(ctx, fetched, pkg, version)
+		if err != nil {
+			return nil, err
+		}
+		lock = append(lock, LockedPackage{
+			Package:     pkg,
+			Version:     version,
+			DownloadURL: packageVersion.DownloadURL,
+		})
+	}
+	sort.Slice(lock, func(i, j int) bool {
+		return lock[i].Package < lock[j].Package
+	})
+	return lock, nil
+}
+
+// pick chooses the version of pkg that satisfies every requirement on it
+func pick(pkg string, requirements []requirement) (string, error) {
+	conflict := func() error {
+		descriptions := []string{}
+		for _, req := range requirements {
+			descriptions = append(descriptions, fmt.Sprintf("%s by %s", req.version, req.from))
+		}
+		return &ConflictError{Package: pkg, Requirements: descriptions}
+	}
+
+	var highest *version
+	var pinned *version
+	for _, req := range requirements {
+		v, err := parseVersion(req.version)
+		if err != nil {
+			return "", fmt.Errorf("package %s: %w", pkg, err)
+		}
+		if highest != nil && v.major != highest.major {
+			return "", conflict()
+		}
+		if highest == nil || v.compare(*highest) > 0 {
+			highest = &v
+		}
+		if req.pinned {
+			pinned = &v
+		}
+	}
+	if pinned != nil {
+		if pinned.compare(*highest) < 0 {
+			return "", conflict()
+		}
+		return pinned.String(), nil
+	}
+	return highest.String(), nil
+}
+
+// splitPackage splits an Owner/Name package into its namespace and name
+func splitPackage(pkg string) (string, string, error) {
+	namespace, name, found := strings.Cut(pkg, "/")
+	if !found || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("package %q is not of the form Owner/Name", pkg)
+	}
+	return namespace, name, nil
+}
+
+// ParseDependency splits a dependency such as denikson-BepInExPack_Valheim-5.4.2202
+// into its Owner/Name package and version
+func ParseDependency(dependency string) (string, string, error) {
+	parts := strings.Split(dependency, "-")
+	if len(parts) < 3 {
+		return "", "", fmt.Errorf("dependency %q is not of the form Owner-Name-Version", dependency)
+	}
+	namespace := strings.Join(parts[:len(parts)-2], "-")
+	return namespace + "/" + parts[len(parts)-2], parts[len(parts)-1], nil
+}
+
+// version is a Thunderstore version number, which are always major.minor.patch
+type version struct {
+	major, minor, patch int
+}
+
+func parseVersion(s string) (version, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return version{}, fmt.Errorf("version %q is not of the form major.minor.patch", s)
+	}
+	numbers := [3]int{}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return version{}, fmt.Errorf("version %q is not of the form major.minor.patch", s)
+		}
+		numbers[i] = n
+	}
+	return version{major: numbers[0], minor: numbers[1], patch: numbers[2]}, nil
+}
+
+func (v version) compare(other version) int {
+	for _, diff := range []int{v.major - other.major, v.minor - other.minor, v.patch - other.patch} {
+		if diff != 0 {
+			return diff
+		}
+	}
+	return 0
+}
+
+func (v version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+}
@@ -0,0 +1,190 @@
+package thunderstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// stubThunderstore serves the experimental package API for a fixed set of
+// package versions, keyed Owner/Name and listed oldest first
+func stubThunderstore(packages map[string][]PackageVersion) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/experimental/package/"), "/"), "/")
+		if len(parts) < 2 {
+			http.NotFound(w, r)
+			return
+		}
+		versions, ok := packages[parts[0]+"/"+parts[1]]
+		if !ok || len(versions) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+
+		if len(parts) == 2 {
+			latest := versions[len(versions)-1]
+			_ = json.NewEncoder(w).Encode(Package{
+				Namespace: latest.Namespace,
+				Name:      latest.Name,
+				FullName:  latest.Namespace + "-" + latest.Name,
+				Latest:    latest,
+			})
+			return
+		}
+		for _, version := range versions {
+			if version.VersionNumber == parts[2] {
+				_ = json.NewEncoder(w).Encode(version)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+}
+
+func packageVersion(pkg string, version string, dependencies ...string) PackageVersion {
+	namespace, name, _ := strings.Cut(pkg, "/")
+	return PackageVersion{
+		Namespace:     namespace,
+		Name:          name,
+		VersionNumber: version,
+		FullName:      fmt.Sprintf("%s-%s-%s", namespace, name, version),
+		DownloadURL:   fmt.Sprintf("https://thunderstore.io/package/download/%s/%s/%s/", namespace, name, version),
+		Dependencies:  dependencies,
+	}
+}
+
+var _ = Describe("Resolver", func() {
+	var (
+		server   *httptest.Server
+		resolver *Resolver
+		ctx      context.Context
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		server = stubThunderstore(map[string][]PackageVersion{
+			"denikson/BepInExPack_Valheim": {
+				packageVersion("denikson/BepInExPack_Valheim", "5.4.2105"),
+				packageVersion("denikson/BepInExPack_Valheim", "5.4.2202"),
+			},
+			"ValheimModding/Jotunn": {
+				packageVersion("ValheimModding/Jotunn", "2.10.0", "denikson-BepInExPack_Valheim-5.4.2105"),
+				packageVersion("ValheimModding/Jotunn", "2.12.1", "denikson-BepInExPack_Valheim-5.4.2202"),
+				packageVersion("ValheimModding/Jotunn", "3.0.0", "denikson-BepInExPack_Valheim-5.4.2202"),
+			},
+			"ValheimModding/HookGenPatcher": {
+				packageVersion("ValheimModding/HookGenPatcher", "0.0.3"),
+				packageVersion("ValheimModding/HookGenPatcher", "0.0.4"),
+			},
+			"RandyKnapp/EpicLoot": {
+				packageVersion("RandyKnapp/EpicLoot", "0.9.30", "ValheimModding-Jotunn-2.10.0", "denikson-BepInExPack_Valheim-5.4.2105"),
+			},
+			"Azumatt/AzuCraftyBoxes": {
+				packageVersion("Azumatt/AzuCraftyBoxes", "1.2.0", "ValheimModding-Jotunn-2.12.1", "ValheimModding-HookGenPatcher-0.0.3"),
+			},
+			"Smoothbrain/Legacy": {
+				packageVersion("Smoothbrain/Legacy", "1.0.0", "ValheimModding-Jotunn-3.0.0"),
+			},
+		})
+		resolver = &Resolver{Client: NewClient(server.URL)}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("locks transitive dependencies", func() {
+		lock, err := resolver.Resolve(ctx, map[string]string{"RandyKnapp/EpicLoot": "0.9.30"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lock).To(Equal([]LockedPackage{
+			{Package: "RandyKnapp/EpicLoot", Version: "0.9.30", DownloadURL: "https://thunderstore.io/package/download/RandyKnapp/EpicLoot/0.9.30/"},
+			{Package: "ValheimModding/Jotunn", Version: "2.10.0", DownloadURL: "https://thunderstore.io/package/download/ValheimModding/Jotunn/2.10.0/"},
+			{Package: "denikson/BepInExPack_Valheim", Version: "5.4.2105", DownloadURL: "https://thunderstore.io/package/download/denikson/BepInExPack_Valheim/5.4.2105/"},
+		}))
+	})
+
+	It("resolves unpinned packages to their latest version", func() {
+		lock, err := resolver.Resolve(ctx, map[string]string{"ValheimModding/HookGenPatcher": ""})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lock).To(HaveLen(1))
+		Expect(lock[0].Version).To(Equal("0.0.4"))
+	})
+
+	It("installs the newest version any dependent asks for", func() {
+		lock, err := resolver.Resolve(ctx, map[string]string{
+			"RandyKnapp/EpicLoot":    "0.9.30",
+			"Azumatt/AzuCraftyBoxes": "1.2.0",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		versions := map[string]string{}
+		for _, locked := range lock {
+			versions[locked.Package] = locked.Version
+		}
+		Expect(versions).To(Equal(map[string]string{
+			"Azumatt/AzuCraftyBoxes":        "1.2.0",
+			"RandyKnapp/EpicLoot":           "0.9.30",
+			"ValheimModding/HookGenPatcher": "0.0.3",
+			"ValheimModding/Jotunn":         "2.12.1",
+			// raised by Jotunn 2.12.1, even though EpicLoot asks for 5.4.2105
+			"denikson/BepInExPack_Valheim": "5.4.2202",
+		}))
+	})
+
+	It("skips packages the server provides", func() {
+		resolver.Provided = map[string]bool{"denikson/BepInExPack_Valheim": true}
+		lock, err := resolver.Resolve(ctx, map[string]string{"ValheimModding/Jotunn": "2.12.1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lock).To(HaveLen(1))
+		Expect(lock[0].Package).To(Equal("ValheimModding/Jotunn"))
+	})
+
+	It("reports dependencies on different major versions as a conflict", func() {
+		_, err := resolver.Resolve(ctx, map[string]string{
+			"RandyKnapp/EpicLoot": "0.9.30",
+			"Smoothbrain/Legacy":  "1.0.0",
+		})
+		var conflict *ConflictError
+		Expect(err).To(BeAssignableToTypeOf(conflict))
+		Expect(err.(*ConflictError).Package).To(Equal("ValheimModding/Jotunn"))
+	})
+
+	It("reports a pin below what a dependency needs as a conflict", func() {
+		_, err := resolver.Resolve(ctx, map[string]string{
+			"Azumatt/AzuCraftyBoxes": "1.2.0",
+			"ValheimModding/Jotunn":  "2.10.0",
+		})
+		var conflict *ConflictError
+		Expect(err).To(BeAssignableToTypeOf(conflict))
+	})
+
+	It("reports packages thunderstore does not know", func() {
+		_, err := resolver.Resolve(ctx, map[string]string{"Nobody/Nothing": "1.0.0"})
+		var notFound *NotFoundError
+		Expect(err).To(BeAssignableToTypeOf(notFound))
+	})
+
+	It("rejects packages that are not Owner/Name", func() {
+		_, err := resolver.Resolve(ctx, map[string]string{"Jotunn": "2.12.1"})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("ParseDependency", func() {
+	It("splits a dependency into package and version", func() {
+		pkg, version, err := ParseDependency("denikson-BepInExPack_Valheim-5.4.2202")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pkg).To(Equal("denikson/BepInExPack_Valheim"))
+		Expect(version).To(Equal("5.4.2202"))
+	})
+
+	It("rejects dependencies without a version", func() {
+		_, _, err := ParseDependency("denikson-BepInExPack_Valheim")
+		Expect(err).To(HaveOccurred())
+	})
+})
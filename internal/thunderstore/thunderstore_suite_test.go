@@ -0,0 +1,14 @@
+package thunderstore
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestThunderstore(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Thunderstore Suite")
+}
@@ -4,3 +4,13 @@ func BoolAddr(b bool) *bool {
 	boolVar := b
 	return &boolVar
 }
+
+func Int32Addr(i int32) *int32 {
+	intVar := i
+	return &intVar
+}
+
+func Int64Addr(i int64) *int64 {
+	intVar := i
+	return &intVar
+}
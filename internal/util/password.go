@@ -0,0 +1,54 @@
+package util
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// maxPasswordAttempts bounds how often a password is generated again when it
+// happens to collide with the server name
+const maxPasswordAttempts = 10
+
+// GeneratePassword generates a password of length from characters with
+// crypto/rand, that Valheim accepts for a server named serverName
+func GeneratePassword(length int, characters string, minLength int, serverName string) (string, error) {
+	if length < minLength {
+		return "", fmt.Errorf("passwords must be at least %d characters", minLength)
+	}
+	if characters == "" {
+		return "", fmt.Errorf("no characters to generate a password from")
+	}
+
+	max := big.NewInt(int64(len(characters)))
+	for attempt := 0; attempt < maxPasswordAttempts; attempt++ {
+		b := make([]byte, length)
+		for i := range b {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return "", err
+			}
+			b[i] = characters[n.Int64()]
+		}
+		password := string(b)
+		if ValidatePassword(password, minLength, serverName) == nil {
+			return password, nil
+		}
+	}
+	return "", fmt.Errorf("could not generate a password that is not part of server name %q", serverName)
+}
+
+// ValidatePassword checks password against the rules of Valheim: it must be
+// at least minLength characters, and neither contain nor be part of the
+// server name
+func ValidatePassword(password string, minLength int, serverName string) error {
+	if len(password) < minLength {
+		return fmt.Errorf("password is shorter than %d characters", minLength)
+	}
+	lowerPassword, lowerName := strings.ToLower(password), strings.ToLower(serverName)
+	if serverName != "" && (strings.Contains(lowerName, lowerPassword) || strings.Contains(lowerPassword, lowerName)) {
+		return fmt.Errorf("password and server name %q must not contain each other", serverName)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package util
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GeneratePassword", func() {
+	DescribeTable("generates passwords of the requested length from the charset",
+		func(length int, characters string) {
+			for i := 0; i < 20; i++ {
+				password, err := GeneratePassword(length, characters, 5, "My Server")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(password).To(HaveLen(length))
+				for _, c := range password {
+					Expect(characters).To(ContainSubstring(string(c)))
+				}
+			}
+		},
+		Entry("at the minimum length", 5, "abcdefghijklmnopqrstuvwxyz"),
+		Entry("longer than the minimum", 32, "abcdefghijklmnopqrstuvwxyz0123456789"),
+		Entry("from a single character", 8, "x"),
+		Entry("from symbols", 16, "!#$%&*+-=?@^_~"),
+	)
+
+	DescribeTable("rejects policies it cannot satisfy",
+		func(length int, characters string, serverName string, message string) {
+			_, err := GeneratePassword(length, characters, 5, serverName)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("shorter than the minimum", 4, "abcdef", "", "at least 5 characters"),
+		Entry("without characters", 8, "", "", "no characters"),
+		Entry("always part of the server name", 5, "a", "aaaaaaaa", "not part of server name"),
+	)
+
+	It("never generates a password containing the server name", func() {
+		for i := 0; i < 200; i++ {
+			password, err := GeneratePassword(5, "abcd", 5, "ABC")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(password).NotTo(ContainSubstring("abc"))
+		}
+	})
+})
+
+var _ = Describe("ValidatePassword", func() {
+	DescribeTable("checks the rules of Valheim",
+		func(password string, serverName string, valid bool) {
+			err := ValidatePassword(password, 5, serverName)
+			if valid {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(HaveOccurred())
+			}
+		},
+		Entry("at the minimum length", "hunter", "My Server", true),
+		Entry("shorter than the minimum", "abcd", "My Server", false),
+		Entry("without a server name", "server", "", true),
+		Entry("containing the server name", "myvalheim2", "valheim", false),
+		Entry("part of the server name", "Valheim", "My Valheim Server", false),
+		Entry("differing from the server name only in case", "VALHEIM", "valheim", false),
+		Entry("sharing characters with the server name", "valley", "valheim", true),
+	)
+})
@@ -0,0 +1,98 @@
+package valheimplus
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ConfigFile is the name of the ValheimPlus config file
+const ConfigFile = "valheim_plus.cfg"
+
+// Settings are the sections of valheim_plus.cfg we know of and the settings
+// of each, as of ValheimPlus 0.9.9
+var Settings = map[string][]string{
+	"Beehive":             {"enabled", "honeyProductionSpeed", "maximumHoneyPerBeehive", "autoDeposit", "autoDepositRange", "showDuration"},
+	"Building":            {"enabled", "noInvalidPlacementRestriction", "noWeatherDamage", "maximumPlacementDistance", "pieceComfortRadius", "alwaysDropResources", "alwaysDropExcludedResources", "enableAreaRepair", "areaRepairRadius"},
+	"Fermenter":           {"enabled", "fermenterDuration", "fermenterItemsProduced", "showDuration", "autoDeposit", "autoFuel", "ignorePrivateAreaCheck", "autoRange"},
+	"FireSource":          {"enabled", "torches", "fires", "autoFuel", "ignorePrivateAreaCheck", "autoRange"},
+	"Food":                {"enabled", "foodDurationMultiplier", "disableFoodDegradation"},
+	"Furnace":             {"enabled", "maximumOre", "maximumCoal", "coalUsedPerProduct", "productionSpeed", "autoDeposit", "autoFuel", "ignorePrivateAreaCheck", "autoRange"},
+	"Game":                {"enabled", "gameDifficultyDamageScale", "gameDifficultyHealthScale", "extraPlayerCountNearby", "setFixedPlayerCountTo", "difficultyScaleRange", "disablePortals", "forceConsole", "disableFog"},
+	"Items":               {"enabled", "noTeleportPrevention", "baseItemWeightReduction", "itemStackMultiplier", "droppedItemOnGroundDurationInSeconds"},
+	"Kiln":                {"enabled", "maximumWood", "productionSpeed", "dontProcessFineWood", "dontProcessRoundLog", "autoDeposit", "autoFuel", "ignorePrivateAreaCheck", "autoRange"},
+	"Map":                 {"enabled", "shareMapProgression", "exploreRadius", "playerPositionPublicOnJoin", "preventPlayerFromTurningOffPublicPosition", "removeDeathPinOnTombstoneEntry", "shareAllPins", "displayCartsAndBoats"},
+	"Player":              {"enabled", "baseMaximumWeight", "baseMegingjordBuff", "baseUnarmedDamage", "baseHPRegen", "guardianBuffDuration", "guardianBuffCooldown", "disableGuardianBuffAnimation", "autoRepair", "autoUnequipAndEquipToolsOnDeath", "deathPenaltyMultiplier", "skipIntro", "queueWeaponChanges", "cropNotifier", "reequipItemsAfterSwimming", "idleAutoEquip"},
+	"Server":              {"enabled", "maxPlayers", "disableServerPassword", "enforceMod", "serverSyncsConfig", "serverSyncHotkeys", "dataRate", "autoSaveInterval"},
+	"Smelter":             {"enabled", "maximumOre", "maximumCoal", "coalUsedPerProduct", "productionSpeed", "autoDeposit", "autoFuel", "ignorePrivateAreaCheck", "autoRange"},
+	"Stamina":             {"enabled", "dodgeStaminaUsage", "encumberedStaminaDrain", "sneakStaminaDrain", "runStaminaDrain", "staminaRegenDelay", "staminaRegen", "swimStaminaDrain", "jumpStaminaUsage"},
+	"StructuralIntegrity": {"enabled", "wood", "stone", "iron", "hardWood", "marble", "disableStructuralIntegrity", "disableDamageToPlayerStructures", "disableDamageToPlayerBoats"},
+	"Time":                {"enabled", "totalDayTimeInSeconds", "nightTimeSpeedMultiplier"},
+	"Wagon":               {"enabled", "wagonExtraMassFromItems", "wagonBaseMass"},
+	"Ward":                {"enabled", "wardRange"},
+	"Workbench":           {"enabled", "workbenchRange", "workbenchAttachmentRange", "disableRoofCheck"},
+}
+
+// Validate checks that every section and setting of config is known to
+// ValheimPlus, and that values fit on one line
+func Validate(config map[string]map[string]string) error {
+	problems := []string{}
+	for _, section := range sortedKeys(config) {
+		known, ok := Settings[section]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown section [%s]", section))
+			continue
+		}
+		for _, setting := range sortedKeys(config[section]) {
+			if !contains(known, setting) {
+				problems = append(problems, fmt.Sprintf("unknown setting %s in section [%s]", setting, section))
+				continue
+			}
+			if strings.ContainsAny(config[section][setting], "\r\n") {
+				problems = append(problems, fmt.Sprintf("setting %s in section [%s] spans more than one line", setting, section))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid valheim plus config: %s", strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// Render writes config as valheim_plus.cfg, with sections and settings in
+// order. Settings left out keep their ValheimPlus defaults.
+func Render(config map[string]map[string]string) (string, error) {
+	if err := Validate(config); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for i, section := range sortedKeys(config) {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "[%s]\n", section)
+		for _, setting := range sortedKeys(config[section]) {
+			fmt.Fprintf(&b, "%s=%s\n", setting, config[section][setting])
+		}
+	}
+	return b.String(), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
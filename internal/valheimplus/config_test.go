@@ -0,0 +1,39 @@
+package valheimplus
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Render", func() {
+	It("renders sections and settings in order", func() {
+		config, err := Render(map[string]map[string]string{
+			"Server": {"maxPlayers": "20", "enabled": "true"},
+			"Map":    {"enabled": "true", "shareMapProgression": "true"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config).To(Equal("[Map]\nenabled=true\nshareMapProgression=true\n\n[Server]\nenabled=true\nmaxPlayers=20\n"))
+	})
+
+	It("renders nothing for an empty config", func() {
+		config, err := Render(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config).To(BeEmpty())
+	})
+
+	It("rejects unknown sections and settings", func() {
+		_, err := Render(map[string]map[string]string{
+			"Servr":  {"enabled": "true"},
+			"Server": {"maxPlayer": "20"},
+		})
+		Expect(err).To(MatchError(ContainSubstring("unknown section [Servr]")))
+		Expect(err).To(MatchError(ContainSubstring("unknown setting maxPlayer in section [Server]")))
+	})
+
+	It("rejects values that would inject settings", func() {
+		err := Validate(map[string]map[string]string{
+			"Server": {"maxPlayers": "20\nenforceMod=false"},
+		})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,14 @@
+package valheimplus
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestValheimPlus(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "ValheimPlus Suite")
+}